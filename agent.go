@@ -1,24 +1,39 @@
 package sapiens
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"sync"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	openai "github.com/sashabaranov/go-openai"
 	"github.com/sashabaranov/go-openai/jsonschema"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// ChatCompleter is the subset of the go-openai client used by the Agent.
+// *openai.Client satisfies it; tests can supply their own implementation.
+type ChatCompleter interface {
+	CreateChatCompletion(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error)
+	CreateChatCompletionStream(ctx context.Context, request openai.ChatCompletionRequest) (*openai.ChatCompletionStream, error)
+}
+
 type AgentFunc func(parameters map[string]string) string
 
 type AgentTool struct {
 	ToolDefinition openai.Tool
 	ToolFunction   AgentFunc
+	Cost           float64 // relative cost of a call, see SetToolCostOptions
 }
 
+// Deprecated: AToolCallResp is no longer used; tool results are reported as
+// ToolResult.
 type AToolCallResp struct {
 	Name     string
 	Id       string
@@ -28,7 +43,7 @@ type AToolCallResp struct {
 type Agent struct {
 	MessagesHistory          []openai.ChatCompletionMessage
 	Context                  context.Context
-	Llm                      *openai.Client
+	Llm                      ChatCompleter
 	Model                    string
 	SystemPrompt             string
 	StructuredResponseSchema *openai.ChatCompletionResponseFormat
@@ -36,25 +51,272 @@ type Agent struct {
 	McpClient                *McpClient
 	McpTools                 []mcp.Tool
 	Request                  openai.ChatCompletionRequest
-	mu                       sync.Mutex
+	mu                       sync.Mutex // guards the fields of the agent
+	askMu                    sync.Mutex // serializes conversation turns
 	maxToolCallDepth         int
 	currentDepth             int
+	maxToolsPerRound         int
+	maxTotalToolCalls        int
+	totalToolCalls           int
+	toolLoopLimit            int
+	toolCallCounts           map[string]int // executions of each distinct call in the current turn
+	toolCallTrace            []ToolCall
+	toolResultTrace          []ToolResult
+	stateless                bool
+	parallelToolCalls        *bool
+	candidateCount           int
+	metrics                  MetricsCollector
+	structuredRetries        int
+	turnMessages             []openai.ChatCompletionMessage
+	onContent                contentHandler // streams the current turn when set
+	contextText              string
+	redactor                 func(string) string
+	systemPromptPosition     SystemPromptPosition
+	turnStart                int // index of the current turn's first message in the conversation
+	maxToolResultSize        int
+	toolExecutionMode        ToolExecutionMode
+	pendingToolCalls         []openai.ToolCall
+	timeBudget               time.Duration
+	lastResponse             openai.ChatCompletionResponse // latest completion of the current turn
+	batchCheckpoint          string
+	toolCallFilter           ToolCallFilter
+	fewShotExamples          []FewShotExample
+	toolResultFormat         ToolResultFormat
+	documents                []document
+	documentLimit            int
+	documentSummarizer       DocumentSummarizer
+	maxResponseLength        int
+	lastResponseMeta         ResponseMeta
+	fileUploader             FileUploader
+	uploadedFiles            map[string]string // file IDs by path, see AttachFile
+	fallbackModels           []string
+	reasoning                string // reasoning returned during the last turn
+	auditSink                AuditSink
+	toolCostOptions          ToolCostOptions
+	shutdown                 bool // set by Shutdown, rejects new turns
+	seed                     *int
+	stopTurn                 context.CancelFunc // cancels the current turn, see Stop
+	temperature              *float32
+	maxTokens                int
+	toolChoice               string
+	retryPolicy              RetryPolicy
+	completionTimeout        time.Duration
+	promptRewriter           PromptRewriter
+	rewrittenPrompt          *rewrittenPrompt // of the current turn
+	promptBuilder            *SystemPromptBuilder
+	messageOptions           map[int]map[string]interface{} // by index in MessagesHistory
+	toolCallDiagnostics      *toolCallDiagnostics
+	grounding                *GroundingMetadata
+	citations                []Citation
+	wrappedResponseSchema    *openai.ChatCompletionResponseFormat // set by SetResponseSchema for an array root, see wrapArraySchema
+	responseSchemaErr        error                                // why the last SetResponseSchema failed, returned by the next turn
+}
+
+// ErrTimeBudgetExceeded is returned, wrapped, when a turn runs past the
+// budget set with SetTimeBudget.
+var ErrTimeBudgetExceeded = errors.New("time budget exceeded")
+
+// ErrToolCallNotApproved is the error of the ToolResult of a pending tool call
+// left out of SubmitToolResults.
+var ErrToolCallNotApproved = errors.New("tool call not approved")
+
+// ToolExecutionMode controls whether the agent runs the tool calls it receives.
+type ToolExecutionMode string
+
+const (
+	// ToolExecutionAuto executes tool calls and continues the conversation.
+	ToolExecutionAuto ToolExecutionMode = "auto"
+	// ToolExecutionManual stops at tool calls so the application can approve
+	// them and hand the results back with SubmitToolResults.
+	ToolExecutionManual ToolExecutionMode = "manual"
+)
+
+// ToolResultFormat describes how a provider expects tool results to be sent.
+type ToolResultFormat struct {
+	// Role is openai.ChatMessageRoleTool to send each result as a tool
+	// message, or openai.ChatMessageRoleUser to describe it in a user message
+	// for providers that reject tool messages.
+	Role string
+	// RequiresToolCallID sends tool messages with the ID of the call they
+	// answer, preceded by the assistant message holding the calls.
+	RequiresToolCallID bool
 }
 
-func NewAgent(ctx context.Context, llm *openai.Client, model string, systemPrompt string) *Agent {
+var (
+	// ToolResultsAsUser sends tool results as user messages. It is the
+	// default, and what Gemini's OpenAI-compatible endpoint needs.
+	ToolResultsAsUser = ToolResultFormat{Role: openai.ChatMessageRoleUser}
+	// ToolResultsAsTool sends tool results as tool messages keyed by
+	// tool_call_id, as OpenAI, Anthropic and Ollama expect.
+	ToolResultsAsTool = ToolResultFormat{Role: openai.ChatMessageRoleTool, RequiresToolCallID: true}
+)
+
+// SystemPromptPosition controls where the system prompt is placed in requests.
+type SystemPromptPosition string
+
+const (
+	// SystemPromptTop sends the system prompt before the messages of each turn.
+	SystemPromptTop SystemPromptPosition = "top"
+	// SystemPromptBottom sends the system prompt only right before the
+	// current turn's messages, at the end of the conversation.
+	SystemPromptBottom SystemPromptPosition = "bottom"
+	// SystemPromptBoth sends it at the top and repeats it right before the
+	// current turn's messages.
+	SystemPromptBoth SystemPromptPosition = "both"
+)
+
+func NewAgent(ctx context.Context, llm ChatCompleter, model string, systemPrompt string) *Agent {
 	instance_of_agent := &Agent{
-		Context:          ctx,
-		Llm:              llm,
-		Model:            model,
-		SystemPrompt:     systemPrompt,
-		maxToolCallDepth: 5, // Prevent infinite recursion
-		currentDepth:     0,
+		Context:           ctx,
+		Llm:               llm,
+		Model:             model,
+		SystemPrompt:      systemPrompt,
+		maxToolCallDepth:  5, // Prevent infinite recursion
+		currentDepth:      0,
+		maxToolsPerRound:  10, // Bound tool executions requested by a single response
+		maxTotalToolCalls: 25, // Bound tool executions across a whole Ask
+		toolLoopLimit:     3,  // Repeats of an identical call before it counts as a loop
 	}
 
 	return instance_of_agent
 }
 
+// cloneConfig returns a new agent with the configuration of a but none of its
+// conversation state. Fields added to Agent that configure it are copied
+// here too; TestAgentCloneConfig fails for those that are not.
+func (a *Agent) cloneConfig() *Agent {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	clone := NewAgent(a.Context, a.Llm, a.Model, a.SystemPrompt)
+	clone.StructuredResponseSchema = a.StructuredResponseSchema
+	clone.wrappedResponseSchema = a.wrappedResponseSchema
+	clone.responseSchemaErr = a.responseSchemaErr
+	clone.Tools = append([]AgentTool(nil), a.Tools...)
+	clone.McpClient = a.McpClient
+	clone.McpTools = append([]mcp.Tool(nil), a.McpTools...)
+	clone.maxToolCallDepth = a.maxToolCallDepth
+	clone.maxToolsPerRound = a.maxToolsPerRound
+	clone.maxTotalToolCalls = a.maxTotalToolCalls
+	clone.toolLoopLimit = a.toolLoopLimit
+	clone.parallelToolCalls = a.parallelToolCalls
+	clone.candidateCount = a.candidateCount
+	clone.metrics = a.metrics
+	clone.structuredRetries = a.structuredRetries
+	clone.contextText = a.contextText
+	clone.redactor = a.redactor
+	clone.systemPromptPosition = a.systemPromptPosition
+	clone.maxToolResultSize = a.maxToolResultSize
+	clone.toolExecutionMode = a.toolExecutionMode
+	clone.timeBudget = a.timeBudget
+	clone.toolCallFilter = a.toolCallFilter
+	clone.fewShotExamples = append([]FewShotExample(nil), a.fewShotExamples...)
+	clone.toolResultFormat = a.toolResultFormat
+	clone.documents = append([]document(nil), a.documents...)
+	clone.documentLimit = a.documentLimit
+	clone.documentSummarizer = a.documentSummarizer
+	clone.maxResponseLength = a.maxResponseLength
+	clone.fileUploader = a.fileUploader
+	if a.uploadedFiles != nil {
+		clone.uploadedFiles = make(map[string]string, len(a.uploadedFiles))
+		for path, id := range a.uploadedFiles {
+			clone.uploadedFiles[path] = id
+		}
+	}
+	clone.fallbackModels = append([]string(nil), a.fallbackModels...)
+	clone.auditSink = a.auditSink
+	clone.toolCostOptions = a.toolCostOptions
+	clone.seed = a.seed
+	clone.temperature = a.temperature
+	clone.maxTokens = a.maxTokens
+	clone.toolChoice = a.toolChoice
+	clone.retryPolicy = a.retryPolicy
+	clone.completionTimeout = a.completionTimeout
+	clone.promptRewriter = a.promptRewriter
+	clone.promptBuilder = a.promptBuilder // renders into a's prompt, already copied
+	clone.toolCallDiagnostics = a.toolCallDiagnostics
+
+	return clone
+}
+
 func (a *Agent) AddTool(name, description string, tool_parameters map[string]jsonschema.Definition, required_params []string, funx AgentFunc) error {
+	if err := validateToolParameters(name, tool_parameters, required_params); err != nil {
+		return err
+	}
+
+	agentTool := newAgentTool(name, description, tool_parameters, required_params, funx)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.hasToolLocked(name) {
+		return fmt.Errorf("tool '%s' is already registered", name)
+	}
+
+	a.Tools = append(a.Tools, agentTool)
+
+	return nil
+}
+
+// AddTools registers ready-made tools, such as the built-in tool helpers.
+// No tool is added if any name is already registered or repeated.
+func (a *Agent) AddTools(tools ...AgentTool) error {
+	for _, tool := range tools {
+		if parameters, ok := tool.ToolDefinition.Function.Parameters.(jsonschema.Definition); ok {
+			if err := validateToolParameters(tool.ToolDefinition.Function.Name, parameters.Properties, parameters.Required); err != nil {
+				return err
+			}
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	seen := make(map[string]bool)
+	for _, tool := range tools {
+		name := tool.ToolDefinition.Function.Name
+		if a.hasToolLocked(name) || seen[name] {
+			return fmt.Errorf("tool '%s' is already registered", name)
+		}
+		seen[name] = true
+	}
+
+	a.Tools = append(a.Tools, tools...)
+
+	return nil
+}
+
+// ReplaceTool registers a tool, overriding any regular tool with the same name.
+func (a *Agent) ReplaceTool(name, description string, tool_parameters map[string]jsonschema.Definition, required_params []string, funx AgentFunc) error {
+	if err := validateToolParameters(name, tool_parameters, required_params); err != nil {
+		return err
+	}
+
+	agentTool := newAgentTool(name, description, tool_parameters, required_params, funx)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, mcpTool := range a.McpTools {
+		if mcpTool.Name == name {
+			return fmt.Errorf("tool '%s' is provided by the MCP server and cannot be replaced", name)
+		}
+	}
+
+	for i, tool := range a.Tools {
+		if tool.ToolDefinition.Function.Name == name {
+			agentTool.Cost = tool.Cost
+			a.Tools[i] = agentTool
+			return nil
+		}
+	}
+
+	a.Tools = append(a.Tools, agentTool)
+
+	return nil
+}
+
+func newAgentTool(name, description string, tool_parameters map[string]jsonschema.Definition, required_params []string, funx AgentFunc) AgentTool {
 	tool_definition := openai.Tool{
 		Type: openai.ToolTypeFunction,
 		Function: &openai.FunctionDefinition{
@@ -68,20 +330,68 @@ func (a *Agent) AddTool(name, description string, tool_parameters map[string]jso
 		},
 	}
 
-	agentTool := AgentTool{
+	return AgentTool{
 		ToolDefinition: tool_definition,
 		ToolFunction:   funx,
 	}
+}
+
+// hasToolLocked reports whether a regular or MCP tool is registered under name.
+// The caller must hold a.mu.
+func (a *Agent) hasToolLocked(name string) bool {
+	for _, tool := range a.Tools {
+		if tool.ToolDefinition.Function.Name == name {
+			return true
+		}
+	}
+
+	for _, mcpTool := range a.McpTools {
+		if mcpTool.Name == name {
+			return true
+		}
+	}
 
+	return false
+}
+
+// SetMaxToolCallDepth limits how many rounds of tool calls a single Ask may
+// take before it fails.
+func (a *Agent) SetMaxToolCallDepth(depth int) {
 	a.mu.Lock()
-	a.Tools = append(a.Tools, agentTool)
+	a.maxToolCallDepth = depth
+	a.mu.Unlock()
+}
+
+// SetMaxToolsPerRound limits how many tool calls a single model response may
+// trigger; a response asking for more runs none of them and fails the turn.
+// Zero or less removes the limit.
+func (a *Agent) SetMaxToolsPerRound(max int) {
+	a.mu.Lock()
+	a.maxToolsPerRound = max
 	a.mu.Unlock()
+}
 
-	return nil
+// SetMaxTotalToolCalls limits how many tool calls may be executed during a
+// single Ask; a round that would go over it runs none of its calls and fails
+// the turn. Zero or less removes the limit.
+func (a *Agent) SetMaxTotalToolCalls(max int) {
+	a.mu.Lock()
+	a.maxTotalToolCalls = max
+	a.mu.Unlock()
+}
+
+// SetToolLoopLimit sets how many times the same tool may be called with the
+// same arguments during a single Ask. A model asking for that call again is
+// stuck in a loop: the turn ends with a response explaining so instead of
+// another tool round. Zero disables the check.
+func (a *Agent) SetToolLoopLimit(repeats int) {
+	a.mu.Lock()
+	a.toolLoopLimit = repeats
+	a.mu.Unlock()
 }
 
-func (a *Agent) AddMCP(url string, customHeaders map[string]string) error {
-	mcpClient, err := NewMcpClient(a.Context, url)
+func (a *Agent) AddMCP(url string, customHeaders map[string]string, opts ...McpOption) error {
+	mcpClient, err := NewMcpClient(a.Context, url, opts...)
 	if err != nil {
 		return fmt.Errorf("failed to create MCP client: %w", err)
 	}
@@ -93,29 +403,70 @@ func (a *Agent) AddMCP(url string, customHeaders map[string]string) error {
 	}
 
 	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, mcpTool := range toolsResult.Tools {
+		for _, tool := range a.Tools {
+			if tool.ToolDefinition.Function.Name == mcpTool.Name {
+				return fmt.Errorf("MCP tool '%s' conflicts with an already registered tool", mcpTool.Name)
+			}
+		}
+	}
+
+	mcpClient.Redact = a.redactor
 	a.McpClient = mcpClient
 	a.McpTools = toolsResult.Tools
-	a.mu.Unlock()
 
 	return nil
 }
 
+// SetResponseSchema makes the agent answer with JSON matching defined_schema,
+// which is either a jsonschema.Definition, e.g. from a SchemaBuilder, or a Go
+// value whose type the schema is generated from. A Go type the schema cannot
+// be generated from is fatal. A schema failing ValidateSchema is not set: it
+// returns nil and the turns asked with the agent's schema fail with the
+// validation error until a valid schema is set.
 func (a *Agent) SetResponseSchema(name, description string, strict bool, defined_schema interface{}) *openai.ChatCompletionResponseFormat {
-	schema, err := jsonschema.GenerateSchemaForType(defined_schema)
-	if err != nil {
-		log.Fatalf("GenerateSchemaForType error: %v", err)
+	var schema *jsonschema.Definition
+	switch defined := defined_schema.(type) {
+	case jsonschema.Definition:
+		schema = &defined
+	case *jsonschema.Definition:
+		schema = defined
+	default:
+		generated, err := generateSchema(defined_schema)
+		if err != nil {
+			log.Fatalf("GenerateSchemaForType error: %v", err)
+		}
+		schema = generated
+	}
+
+	if err := ValidateSchema(*schema); err != nil {
+		a.mu.Lock()
+		a.responseSchemaErr = fmt.Errorf("invalid response schema '%s': %w", name, err)
+		a.mu.Unlock()
+
+		return nil
 	}
+	wrapped := wrapArraySchema(schema)
 
 	msgSchema := &openai.ChatCompletionResponseFormat{
 		Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
 		JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
 			Name:   name, // Fixed: use parameter instead of hardcoded value
-			Schema: schema,
+			Schema: wrapped,
 			Strict: strict,
 		},
 	}
 
+	a.mu.Lock()
 	a.StructuredResponseSchema = msgSchema
+	a.responseSchemaErr = nil
+	a.wrappedResponseSchema = nil
+	if wrapped != schema {
+		a.wrappedResponseSchema = msgSchema
+	}
+	a.mu.Unlock()
 
 	return msgSchema
 }
@@ -126,205 +477,1161 @@ func (a *Agent) ParseResponse(agent_response openai.ChatCompletionResponse, defi
 		return fmt.Errorf("no choices in response")
 	}
 
-	return json.Unmarshal([]byte(agent_response.Choices[0].Message.Content), &defined_schema)
+	return unmarshalStructured(agent_response.Choices[0].Message.Content, &defined_schema)
 }
 
+// Ask sends the messages to the model, executing tool calls until a final
+// response is produced. Concurrent calls on the same agent are serialized so
+// that each turn sees a consistent conversation history.
 func (a *Agent) Ask(user_messages []openai.ChatCompletionMessage) (response openai.ChatCompletionResponse, err error) {
-	system_message := []openai.ChatCompletionMessage{
-		{
-			Role:    openai.ChatMessageRoleSystem,
-			Content: a.SystemPrompt,
-		},
-	}
+	a.askMu.Lock()
+	defer a.askMu.Unlock()
+
+	return a.ask(askOptions{}, user_messages)
+}
+
+// AskWithModel behaves like Ask but sends this turn, including every tool-call
+// round, to the given model without changing the agent's default model.
+func (a *Agent) AskWithModel(model string, user_messages []openai.ChatCompletionMessage) (openai.ChatCompletionResponse, error) {
+	a.askMu.Lock()
+	defer a.askMu.Unlock()
 
-	all_messages := append(system_message, user_messages...)
+	return a.ask(askOptions{model: model}, user_messages)
+}
 
+// SetModel changes the default model used for subsequent turns.
+func (a *Agent) SetModel(model string) {
 	a.mu.Lock()
-	a.MessagesHistory = append(a.MessagesHistory, all_messages...)
-	a.currentDepth = 0 // Reset depth for new conversation
+	a.Model = model
 	a.mu.Unlock()
+}
 
-	requestData := openai.ChatCompletionRequest{
-		Model:    a.Model,
-		Messages: a.MessagesHistory,
-	}
-
-	if a.StructuredResponseSchema != nil {
-		requestData.ResponseFormat = a.StructuredResponseSchema
-	}
+// SetParallelToolCalls controls whether the model may request several tool
+// calls in one response. Disable it when tools depend on each other's output.
+func (a *Agent) SetParallelToolCalls(enabled bool) {
+	a.mu.Lock()
+	a.parallelToolCalls = &enabled
+	a.mu.Unlock()
+}
 
-	if len(a.Tools) > 0 || len(a.McpTools) > 0 {
-		var openaiTools []openai.Tool
+// SetCandidateCount asks the model for n alternative answers per request.
+// AskStructured returns all of them in Response.Candidates. Tool calls
+// requested by any candidate are executed.
+func (a *Agent) SetCandidateCount(n int) {
+	a.mu.Lock()
+	a.candidateCount = n
+	a.mu.Unlock()
+}
 
-		a.mu.Lock()
-		// Add regular tools
-		for _, tool := range a.Tools {
-			openaiTools = append(openaiTools, tool.ToolDefinition)
-		}
+// SetSeed asks the model to sample deterministically, so that repeated
+// requests with the same seed and parameters return the same result where the
+// provider supports it. Compare LastResponseMeta().SystemFingerprint across
+// runs: a changed fingerprint means the backend changed and results may
+// differ despite the seed.
+func (a *Agent) SetSeed(seed int) {
+	a.mu.Lock()
+	a.seed = &seed
+	a.mu.Unlock()
+}
 
-		// Add MCP tools converted to OpenAI format
-		for _, mcpTool := range a.McpTools {
-			parsedProperties := a.McpClient.ParseToolDefinition(mcpTool.InputSchema)
+// SetTemperature sets the sampling temperature of every request, from 0 to
+// 2. Without it the provider's default applies. Zero is sent as the smallest
+// float32, which the providers' clients send as 0.
+func (a *Agent) SetTemperature(temperature float32) {
+	a.mu.Lock()
+	a.temperature = &temperature
+	a.mu.Unlock()
+}
 
-			// Extract required fields from the MCP tool schema
-			var requiredFields []string
-			if mcpTool.InputSchema.Required != nil {
-				for _, req := range mcpTool.InputSchema.Required {
-					requiredFields = append(requiredFields, req)
-				}
-			}
+// SetMaxTokens caps the tokens the model may generate per completion. Zero
+// removes the cap.
+func (a *Agent) SetMaxTokens(n int) {
+	a.mu.Lock()
+	a.maxTokens = n
+	a.mu.Unlock()
+}
 
-			openaiTool := openai.Tool{
-				Type: openai.ToolTypeFunction,
-				Function: &openai.FunctionDefinition{
-					Name:        mcpTool.Name,
-					Description: mcpTool.Description,
-					Parameters: jsonschema.Definition{
-						Type:       jsonschema.Object,
-						Properties: parsedProperties,
-						Required:   requiredFields,
-					},
-				},
-			}
-			openaiTools = append(openaiTools, openaiTool)
-		}
-		a.mu.Unlock()
+// SetToolChoice controls whether the model calls tools: "auto", "none",
+// "required", or the name of the one tool it must call. Empty leaves the
+// choice to the provider's default. It is only sent when tools are
+// registered.
+func (a *Agent) SetToolChoice(choice string) {
+	a.mu.Lock()
+	a.toolChoice = choice
+	a.mu.Unlock()
+}
 
-		requestData.Tools = openaiTools
+// toolChoiceParam encodes a tool choice set with SetToolChoice.
+func toolChoiceParam(choice string) any {
+	switch choice {
+	case "auto", "none", "required":
+		return choice
 	}
 
-	a.Request = requestData
+	return openai.ToolChoice{
+		Type:     openai.ToolTypeFunction,
+		Function: openai.ToolFunction{Name: choice},
+	}
+}
 
-	return a.AskAi(a.Context)
+// SetMaxToolResultSize truncates tool results larger than size bytes before
+// they are added to the conversation, so one chatty tool cannot exhaust the
+// context window. Zero, the default, disables truncation.
+func (a *Agent) SetMaxToolResultSize(size int) {
+	a.mu.Lock()
+	a.maxToolResultSize = size
+	a.mu.Unlock()
 }
 
-func (a *Agent) AskAi(ctx context.Context) (openai.ChatCompletionResponse, error) {
+// SetMetricsCollector installs a collector notified about every completion
+// request and tool execution. Pass nil to disable metrics.
+func (a *Agent) SetMetricsCollector(collector MetricsCollector) {
 	a.mu.Lock()
-	a.Request.Messages = a.MessagesHistory
+	a.metrics = collector
 	a.mu.Unlock()
+}
 
-	responseStr, responseErr := a.Llm.CreateChatCompletion(
-		ctx, // Fixed: Use the passed context parameter
-		a.Request,
-	)
+func (a *Agent) metricsCollector() MetricsCollector {
+	a.mu.Lock()
+	defer a.mu.Unlock()
 
-	if responseErr != nil {
-		return responseStr, responseErr
+	if a.metrics == nil {
+		return NoopMetrics{}
 	}
 
-	// Process tool calls if any and return the final response
-	finalResponse, err := a.ToolCalls(responseStr)
-	if err != nil {
-		return responseStr, fmt.Errorf("tool call processing error: %w", err)
+	return a.metrics
+}
+
+// SetStructuredRetries makes the agent re-ask up to n times, with a corrective
+// message, when a structured response is not valid JSON.
+func (a *Agent) SetStructuredRetries(n int) {
+	a.mu.Lock()
+	a.structuredRetries = n
+	a.mu.Unlock()
+}
+
+// SetRedactor installs a function that scrubs sensitive data, such as emails
+// or card numbers, from text the agent logs or hands out for recording:
+// debug output and RedactedHistory. Messages sent to the model are never
+// redacted. Pass nil to remove the redactor.
+func (a *Agent) SetRedactor(redactor func(string) string) {
+	a.mu.Lock()
+	a.redactor = redactor
+	if a.McpClient != nil {
+		a.McpClient.Redact = redactor
 	}
+	a.mu.Unlock()
+}
 
-	// Return the final response if tools were called, otherwise return original response
-	if finalResponse != nil {
-		return *finalResponse, nil
+func (a *Agent) redact(text string) string {
+	a.mu.Lock()
+	redactor := a.redactor
+	a.mu.Unlock()
+
+	if redactor == nil {
+		return text
 	}
 
-	return responseStr, responseErr
+	return redactor(text)
 }
 
-func (a *Agent) ToolCalls(response openai.ChatCompletionResponse) (*openai.ChatCompletionResponse, error) {
-	// Fixed: Add recursion depth check to prevent infinite loops
-	if a.currentDepth >= a.maxToolCallDepth {
-		return nil, fmt.Errorf("maximum tool call depth (%d) exceeded", a.maxToolCallDepth)
+// RedactedHistory returns a copy of the conversation history with the
+// redactor applied to message contents and tool call arguments, suitable for
+// compliance logging.
+func (a *Agent) RedactedHistory() []openai.ChatCompletionMessage {
+	a.mu.Lock()
+	redactor := a.redactor
+	history := append([]openai.ChatCompletionMessage(nil), a.conversationLocked()...)
+	a.mu.Unlock()
+
+	if redactor == nil {
+		return history
 	}
 
-	var toolResponses []AToolCallResp
-	var totalToolExecCount int = 0
+	for i, message := range history {
+		history[i].Content = redactor(message.Content)
 
-	// Check if response has function calls
-	for _, choice := range response.Choices {
-		if choice.Message.ToolCalls != nil && len(choice.Message.ToolCalls) > 0 {
-			// Don't add assistant message with tool calls for Gemini compatibility
-
-			for _, toolCall := range choice.Message.ToolCalls {
-				// First try to find regular tool
-				toolInst, toolInsErr := a.GetToolByName(toolCall.Function.Name)
-				if toolInsErr == nil {
-					// Regular tool found
-					var parsedParams map[string]string
-					if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &parsedParams); err != nil {
-						return nil, fmt.Errorf("failed to parse tool arguments for '%s': %w", toolCall.Function.Name, err)
-					}
-
-					toolResponse := toolInst.ToolFunction(parsedParams)
-
-					toolResponses = append(toolResponses, AToolCallResp{
-						Response: toolResponse,
-						Id:       toolCall.ID,
-						Name:     toolCall.Function.Name,
-					})
-				} else {
-					// Try MCP tool
-					mcpTool, mcpErr := a.GetMcpToolByName(toolCall.Function.Name)
-					if mcpErr != nil {
-						return nil, fmt.Errorf("tool '%s' not found in regular or MCP tools: %w", toolCall.Function.Name, mcpErr)
-					}
-
-					// Parse arguments as generic map for MCP
-					var parsedArgs map[string]interface{}
-					if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &parsedArgs); err != nil {
-						return nil, fmt.Errorf("failed to parse MCP tool arguments for '%s': %w", toolCall.Function.Name, err)
-					}
-
-					// Call MCP tool
-					mcpResult, mcpCallErr := a.McpClient.CallTool(mcp.CallToolParams{
-						Name:      mcpTool.Name,
-						Arguments: parsedArgs,
-					})
-
-					if mcpCallErr != nil {
-						return nil, fmt.Errorf("MCP tool call failed for '%s': %w", toolCall.Function.Name, mcpCallErr)
-					}
-
-					// Convert MCP result to string
-					var toolResponse string
-					if len(mcpResult.Content) > 0 {
-						toolResponse = fmt.Sprintf("%v", mcpResult.Content[0])
-					} else {
-						toolResponse = "MCP tool executed successfully"
-					}
-
-					toolResponses = append(toolResponses, AToolCallResp{
-						Response: toolResponse,
-						Id:       toolCall.ID,
-						Name:     toolCall.Function.Name,
-					})
+		if len(message.MultiContent) > 0 {
+			parts := append([]openai.ChatMessagePart(nil), message.MultiContent...)
+			for j := range parts {
+				if parts[j].Type == openai.ChatMessagePartTypeText {
+					parts[j].Text = redactor(parts[j].Text)
 				}
+			}
+			history[i].MultiContent = parts
+		}
 
-				totalToolExecCount++
+		if len(message.ToolCalls) > 0 {
+			toolCalls := append([]openai.ToolCall(nil), message.ToolCalls...)
+			for j := range toolCalls {
+				toolCalls[j].Function.Arguments = redactor(toolCalls[j].Function.Arguments)
 			}
+			history[i].ToolCalls = toolCalls
 		}
 	}
 
-	// Fixed: Add tool responses using user message format for Gemini compatibility
-	if len(toolResponses) > 0 {
-		a.mu.Lock()
-		for _, agentToolResp := range toolResponses {
-			// Use user message format instead of tool message for Gemini compatibility
-			toolMessage := NewMessages().UserMessage(
-				fmt.Sprintf("Tool '%s' returned: %s", agentToolResp.Name, agentToolResp.Response),
-			)
-			a.MessagesHistory = append(a.MessagesHistory, toolMessage)
-		}
-		a.currentDepth++ // Increment depth before recursive call
-		a.mu.Unlock()
+	return history
+}
 
-		// Fixed: Recursive call with proper termination condition and return final response
-		if totalToolExecCount > 0 {
-			finalResponse, err := a.AskAi(a.Context)
-			if err != nil {
-				return nil, err
-			}
-			return &finalResponse, nil
-		}
+// SetStateless disables conversation history. In stateless mode every turn is
+// built from the system prompt and the messages passed to Ask only, and
+// nothing is persisted to MessagesHistory.
+func (a *Agent) SetStateless(stateless bool) {
+	a.mu.Lock()
+	a.stateless = stateless
+	a.turnMessages = nil
+	a.mu.Unlock()
+}
+
+// appendHistoryLocked records messages for the current conversation.
+// The caller must hold a.mu.
+func (a *Agent) appendHistoryLocked(messages ...openai.ChatCompletionMessage) {
+	if a.stateless {
+		a.turnMessages = append(a.turnMessages, messages...)
+		return
 	}
 
-	// No tool calls found, return nil to indicate original response should be used
-	return nil, nil
+	a.MessagesHistory = append(a.MessagesHistory, messages...)
+}
+
+// conversationLocked returns the messages that make up the current conversation.
+// The caller must hold a.mu.
+func (a *Agent) conversationLocked() []openai.ChatCompletionMessage {
+	if a.stateless {
+		return a.turnMessages
+	}
+
+	return a.MessagesHistory
+}
+
+// SetContext sets reference material, such as a document or retrieved notes,
+// that is sent with every request right after the system prompt. It is not
+// stored in MessagesHistory. An empty text removes the context.
+func (a *Agent) SetContext(text string) {
+	a.mu.Lock()
+	a.contextText = text
+	a.mu.Unlock()
+}
+
+// FewShotExample is an example exchange shown to the model before the
+// conversation. With a ToolCall, the model is shown calling the tool and
+// getting ToolResult before answering, see AddToolExample.
+type FewShotExample struct {
+	User       string
+	Assistant  string
+	ToolCall   *ToolCall
+	ToolResult string
+}
+
+// SetFewShotExamples sets example exchanges sent with every request as
+// alternating user and assistant messages, right after the system prompt and
+// context. Many models follow examples given as messages more closely than
+// examples written into the system prompt. They are not stored in
+// MessagesHistory. No examples removes them.
+func (a *Agent) SetFewShotExamples(examples ...FewShotExample) {
+	a.mu.Lock()
+	a.fewShotExamples = append([]FewShotExample(nil), examples...)
+	a.mu.Unlock()
+}
+
+// UpdateContext appends text to the context set with SetContext.
+func (a *Agent) UpdateContext(text string) {
+	a.mu.Lock()
+	if a.contextText == "" {
+		a.contextText = text
+	} else {
+		a.contextText += "\n\n" + text
+	}
+	a.mu.Unlock()
+}
+
+// SetSystemPromptPosition places the system prompt at the top of each turn
+// (the default), right before the current turn's messages, or both. Placing
+// instructions near the end helps some models follow them in long contexts.
+func (a *Agent) SetSystemPromptPosition(position SystemPromptPosition) {
+	a.mu.Lock()
+	a.systemPromptPosition = position
+	a.mu.Unlock()
+}
+
+// requestMessagesLocked returns a copy of the conversation to send, with the
+// context block inserted after the leading system messages and the system
+// prompt repeated before the current turn when positioned at the bottom.
+// The caller must hold a.mu.
+func (a *Agent) requestMessagesLocked() []openai.ChatCompletionMessage {
+	conversation := a.conversationLocked()
+
+	// Context and examples follow the leading system messages
+	contextAt := -1
+	contextBlock := a.contextBlockLocked()
+	if contextBlock != "" || len(a.fewShotExamples) > 0 {
+		contextAt = 0
+		for contextAt < len(conversation) && conversation[contextAt].Role == openai.ChatMessageRoleSystem {
+			contextAt++
+		}
+	}
+
+	promptAt := -1
+	if a.SystemPrompt != "" && (a.systemPromptPosition == SystemPromptBottom || a.systemPromptPosition == SystemPromptBoth) {
+		promptAt = min(a.turnStart, len(conversation))
+	}
+
+	messages := make([]openai.ChatCompletionMessage, 0, len(conversation)+4*len(a.fewShotExamples)+2)
+	for i := 0; i <= len(conversation); i++ {
+		if i == contextAt && contextBlock != "" {
+			messages = append(messages, openai.ChatCompletionMessage{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: "Context:\n" + contextBlock,
+			})
+		}
+		if i == contextAt {
+			for i, example := range a.fewShotExamples {
+				messages = append(messages, a.exampleMessagesLocked(i, example)...)
+			}
+		}
+		if i == promptAt {
+			messages = append(messages, openai.ChatCompletionMessage{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: a.SystemPrompt,
+			})
+		}
+		if i < len(conversation) {
+			message := conversation[i]
+			if a.rewrittenPrompt != nil && a.rewrittenPrompt.index == i {
+				message.Content = a.rewrittenPrompt.content
+			}
+			messages = append(messages, message)
+		}
+	}
+
+	return messages
+}
+
+// askOptions carries per-turn overrides of the agent configuration.
+type askOptions struct {
+	model          string
+	responseFormat *openai.ChatCompletionResponseFormat
+	onContent      contentHandler           // stream every completion of the turn
+	ctx            context.Context          // overrides the agent context for this turn
+	messageOptions []map[string]interface{} // Options of the user messages, by position
+}
+
+// ask runs a single conversation turn. Zero-valued options use the agent defaults.
+func (a *Agent) ask(opts askOptions, user_messages []openai.ChatCompletionMessage) (openai.ChatCompletionResponse, error) {
+	rewriteCtx := a.Context
+	if opts.ctx != nil {
+		rewriteCtx = opts.ctx
+	}
+	rewriteAt, rewritten, err := a.rewriteLastUserMessage(rewriteCtx, user_messages)
+	if err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+
+	a.mu.Lock()
+
+	if a.shutdown {
+		a.mu.Unlock()
+		return openai.ChatCompletionResponse{}, ErrAgentShutdown
+	}
+
+	if opts.responseFormat == nil && a.responseSchemaErr != nil {
+		err := a.responseSchemaErr
+		a.mu.Unlock()
+		return openai.ChatCompletionResponse{}, err
+	}
+
+	model := opts.model
+	if model == "" {
+		model = a.Model
+	}
+
+	var all_messages []openai.ChatCompletionMessage
+
+	// Some providers reject an empty system message, so only send one when set
+	if a.SystemPrompt != "" && a.systemPromptPosition != SystemPromptBottom {
+		all_messages = append(all_messages, openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: a.SystemPrompt,
+		})
+	}
+
+	for _, message := range user_messages {
+		all_messages = append(all_messages, normalizeMultiContent(message))
+	}
+
+	if a.stateless {
+		a.turnMessages = nil
+	}
+	a.appendHistoryLocked(all_messages...)
+	a.turnStart = len(a.conversationLocked()) - len(user_messages)
+	if !a.stateless {
+		for i, options := range opts.messageOptions {
+			a.setMessageOptionsLocked(a.turnStart+i, options)
+		}
+	}
+	a.currentDepth = 0 // Reset depth for new conversation
+	a.totalToolCalls = 0
+	a.toolCallCounts = nil
+	a.toolCallTrace = nil
+	a.toolResultTrace = nil
+	a.pendingToolCalls = nil
+	a.reasoning = ""
+	a.grounding = nil
+	a.citations = nil
+	a.rewrittenPrompt = nil
+	if rewriteAt >= 0 {
+		a.rewrittenPrompt = &rewrittenPrompt{index: a.turnStart + rewriteAt, content: rewritten}
+	}
+
+	requestData := openai.ChatCompletionRequest{
+		Model: model,
+	}
+
+	if opts.responseFormat != nil {
+		requestData.ResponseFormat = opts.responseFormat
+	} else if a.StructuredResponseSchema != nil {
+		requestData.ResponseFormat = a.StructuredResponseSchema
+	}
+
+	if len(a.Tools) > 0 || len(a.McpTools) > 0 {
+		var openaiTools []openai.Tool
+
+		// Add regular tools
+		openaiTools = append(openaiTools, a.toolDefinitionsLocked()...)
+
+		// Add MCP tools converted to OpenAI format
+		for _, mcpTool := range a.McpTools {
+			parsedProperties := a.McpClient.ParseToolDefinition(mcpTool.InputSchema)
+
+			// Extract required fields from the MCP tool schema
+			var requiredFields []string
+			if mcpTool.InputSchema.Required != nil {
+				for _, req := range mcpTool.InputSchema.Required {
+					requiredFields = append(requiredFields, req)
+				}
+			}
+
+			openaiTool := openai.Tool{
+				Type: openai.ToolTypeFunction,
+				Function: &openai.FunctionDefinition{
+					Name:        mcpTool.Name,
+					Description: mcpTool.Description,
+					Parameters: jsonschema.Definition{
+						Type:       jsonschema.Object,
+						Properties: parsedProperties,
+						Required:   requiredFields,
+					},
+				},
+			}
+			openaiTools = append(openaiTools, openaiTool)
+		}
+
+		requestData.Tools = openaiTools
+
+		if a.parallelToolCalls != nil {
+			requestData.ParallelToolCalls = *a.parallelToolCalls
+		}
+
+		if a.toolChoice != "" {
+			requestData.ToolChoice = toolChoiceParam(a.toolChoice)
+		}
+	}
+
+	if a.temperature != nil {
+		requestData.Temperature = *a.temperature
+		if requestData.Temperature == 0 {
+			// go-openai omits a zero temperature, see restoreZeroTemperature
+			requestData.Temperature = math.SmallestNonzeroFloat32
+		}
+	}
+
+	if a.maxTokens > 0 {
+		requestData.MaxTokens = a.maxTokens
+	}
+
+	if a.candidateCount > 1 {
+		requestData.N = a.candidateCount
+	}
+
+	if a.seed != nil {
+		seed := *a.seed
+		requestData.Seed = &seed
+	}
+
+	a.Request = requestData
+	// The content streamed so far is the partial answer returned by Stop
+	var partial string
+	onContent := opts.onContent
+	if onContent != nil {
+		onContent = func(delta, content string) {
+			partial = content
+			opts.onContent(delta, content)
+		}
+	}
+	a.onContent = capContentHandler(onContent, a.maxResponseLength)
+	structuredRetries := a.structuredRetries
+	expectsJSON := requestData.ResponseFormat != nil
+	timeBudget := a.timeBudget
+	a.lastResponse = openai.ChatCompletionResponse{}
+
+	parent := a.Context
+	if opts.ctx != nil {
+		parent = opts.ctx
+	}
+
+	parent, stop := context.WithCancelCause(parent)
+	defer stop(nil)
+	a.stopTurn = func() { stop(ErrStopped) }
+	a.mu.Unlock()
+
+	if timeBudget > 0 {
+		var cancel context.CancelFunc
+		parent, cancel = context.WithTimeout(parent, timeBudget)
+		defer cancel()
+	}
+
+	ctx, span := startSpan(parent, "sapiens.ask", attribute.String("gen_ai.request.model", model))
+	response, err := a.AskAi(ctx)
+
+	for attempt := 0; err == nil && expectsJSON && attempt < structuredRetries && !isValidJSONResponse(response); attempt++ {
+		a.mu.Lock()
+		a.appendHistoryLocked(
+			NewMessages().AgentMessage(response.Choices[0].Message.Content),
+			NewMessages().UserMessage(invalidJSONCorrection),
+		)
+		a.mu.Unlock()
+
+		response, err = a.AskAi(ctx)
+	}
+
+	if err == nil {
+		a.diagnoseMissingToolCall(ctx, response)
+	}
+
+	if err != nil && timeBudget > 0 && errors.Is(parent.Err(), context.DeadlineExceeded) {
+		a.mu.Lock()
+		response = a.lastResponse
+		a.mu.Unlock()
+
+		err = fmt.Errorf("%w (%s): %v", ErrTimeBudgetExceeded, timeBudget, err)
+	}
+
+	if err != nil && errors.Is(context.Cause(parent), ErrStopped) {
+		a.mu.Lock()
+		response = stoppedResponse(a.lastResponse, partial)
+		a.mu.Unlock()
+
+		err = ErrStopped
+	}
+
+	endSpan(span, err)
+
+	a.mu.Lock()
+	a.onContent = nil
+	a.stopTurn = nil
+	truncateResponse(&response, a.maxResponseLength)
+	a.mu.Unlock()
+
+	return response, err
+}
+
+// SetTimeBudget bounds the wall-clock time of a whole turn, including every
+// completion and tool call. When the budget runs out, Ask returns the latest
+// response received, typically the one holding tool calls, together with an
+// error wrapping ErrTimeBudgetExceeded. Zero disables the budget.
+func (a *Agent) SetTimeBudget(budget time.Duration) {
+	a.mu.Lock()
+	a.timeBudget = budget
+	a.mu.Unlock()
+}
+
+// SetCompletionTimeout bounds each completion request, each attempt when
+// retrying, so a hanging provider fails fast while slow tools keep the whole
+// turn going. Zero disables the timeout.
+func (a *Agent) SetCompletionTimeout(timeout time.Duration) {
+	a.mu.Lock()
+	a.completionTimeout = timeout
+	a.mu.Unlock()
+}
+
+const invalidJSONCorrection = "Your previous response was not valid JSON. Respond with only the JSON matching the schema, without any surrounding text or formatting."
+
+// isValidJSONResponse reports whether the first choice holds valid JSON,
+// ignoring a surrounding Markdown code fence.
+func isValidJSONResponse(response openai.ChatCompletionResponse) bool {
+	if len(response.Choices) == 0 {
+		return true
+	}
+
+	return json.Valid([]byte(stripMarkdownFences(response.Choices[0].Message.Content)))
+}
+
+// AskStructured behaves like Ask but returns the provider-agnostic Response,
+// including the tool calls executed during the turn and the parsed structured
+// output when a response schema is set.
+func (a *Agent) AskStructured(user_messages []openai.ChatCompletionMessage) (*Response, error) {
+	a.askMu.Lock()
+	defer a.askMu.Unlock()
+
+	return a.askStructured(askOptions{}, user_messages)
+}
+
+func (a *Agent) askStructured(opts askOptions, user_messages []openai.ChatCompletionMessage) (*Response, error) {
+	raw, err := a.ask(opts, user_messages)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &Response{
+		Raw: raw,
+	}
+
+	a.mu.Lock()
+	response.ToolCalls = append([]ToolCall(nil), a.toolCallTrace...)
+	response.ToolResults = append([]ToolResult(nil), a.toolResultTrace...)
+	response.Citations = append([]Citation(nil), a.citations...)
+	hasSchema := a.StructuredResponseSchema != nil
+	arrayRoot := hasSchema && a.StructuredResponseSchema == a.wrappedResponseSchema
+	a.mu.Unlock()
+
+	for i, choice := range raw.Choices {
+		candidate := Candidate{
+			Content: choice.Message.Content,
+		}
+
+		if hasSchema && candidate.Content != "" {
+			// An array answer is unwrapped by decoding it into a slice
+			var err error
+			if arrayRoot {
+				var items []interface{}
+				err = unmarshalStructured(candidate.Content, &items)
+				candidate.Structured = items
+			} else {
+				err = unmarshalStructured(candidate.Content, &candidate.Structured)
+			}
+			if err != nil {
+				return response, fmt.Errorf("failed to parse structured response for candidate %d: %w", i, err)
+			}
+		}
+
+		response.Candidates = append(response.Candidates, candidate)
+	}
+
+	if len(response.Candidates) > 0 {
+		response.Content = response.Candidates[0].Content
+		response.Structured = response.Candidates[0].Structured
+		response.Truncated = raw.Choices[0].FinishReason == openai.FinishReasonLength
+	}
+
+	return response, nil
+}
+
+func (a *Agent) AskAi(ctx context.Context) (openai.ChatCompletionResponse, error) {
+	// Don't start another round once the turn is cancelled or out of time
+	if err := ctx.Err(); err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+
+	a.mu.Lock()
+	a.Request.Messages = a.requestMessagesLocked()
+	request := a.Request
+	onContent := a.onContent
+	a.mu.Unlock()
+
+	model := request.Model
+	responseStr, grounding, responseErr := a.complete(ctx, &request, onContent)
+	if request.Model != model && !needsFallback(responseStr, responseErr) {
+		// The rest of the turn stays on the model that answered
+		a.mu.Lock()
+		a.Request.Model = request.Model
+		a.mu.Unlock()
+	}
+
+	if responseErr != nil {
+		return responseStr, responseErr
+	}
+
+	a.mu.Lock()
+	a.lastResponse = responseStr
+	a.lastResponseMeta = newResponseMeta(responseStr)
+	a.appendReasoningLocked(responseStr)
+	a.grounding, a.citations = grounding.get()
+	a.mu.Unlock()
+
+	// Process tool calls if any and return the final response
+	finalResponse, err := a.toolCalls(ctx, responseStr)
+	if err != nil {
+		return responseStr, fmt.Errorf("tool call processing error: %w", err)
+	}
+
+	// Return the final response if tools were called, otherwise return original response
+	if finalResponse != nil {
+		return *finalResponse, nil
+	}
+
+	return responseStr, responseErr
+}
+
+// complete sends request with the agent's retry policy, completion timeout
+// and fallback models, recording metrics and a span. request.Model is left
+// set to the model that was asked last.
+func (a *Agent) complete(ctx context.Context, request *openai.ChatCompletionRequest, onContent contentHandler) (openai.ChatCompletionResponse, *groundingRecorder, error) {
+	a.mu.Lock()
+	fallbackModels := a.fallbackModels
+	retryPolicy, completionTimeout := a.retryPolicy, a.completionTimeout
+	a.mu.Unlock()
+
+	spanCtx, span := startSpan(ctx, "sapiens.completion",
+		attribute.String("gen_ai.request.model", request.Model),
+		attribute.Int("sapiens.messages", len(request.Messages)),
+	)
+
+	var responseStr openai.ChatCompletionResponse
+	var responseErr error
+	var grounding *groundingRecorder
+	complete := func() {
+		retryPolicy.run(spanCtx, func() error {
+			completionCtx := spanCtx
+			if completionTimeout > 0 {
+				var cancel context.CancelFunc
+				completionCtx, cancel = context.WithTimeout(spanCtx, completionTimeout)
+				defer cancel()
+			}
+
+			completionCtx, retryAfter := withRetryAfterHint(completionCtx)
+			completionCtx, grounding = withGroundingRecorder(completionCtx)
+
+			started := time.Now()
+			if onContent != nil {
+				responseStr, responseErr = a.streamCompletion(completionCtx, *request, onContent)
+			} else {
+				responseStr, responseErr = a.Llm.CreateChatCompletion(
+					completionCtx, // Fixed: Use the passed context parameter
+					*request,
+				)
+			}
+			responseErr = retryAfter.wrap(responseErr)
+			a.metricsCollector().ObserveCompletion(request.Model, time.Since(started), responseStr.Usage, responseErr)
+
+			return responseErr
+		})
+	}
+
+	complete()
+	for _, model := range fallbackModels {
+		if !needsFallback(responseStr, responseErr) || ctx.Err() != nil {
+			break
+		}
+
+		request.Model = model
+		complete()
+	}
+	if responseErr == nil && responseStr.Model == "" {
+		responseStr.Model = request.Model
+	}
+
+	endSpan(span, responseErr,
+		attribute.Int("gen_ai.usage.input_tokens", responseStr.Usage.PromptTokens),
+		attribute.Int("gen_ai.usage.output_tokens", responseStr.Usage.CompletionTokens),
+	)
+
+	return responseStr, grounding, responseErr
+}
+
+func (a *Agent) ToolCalls(response openai.ChatCompletionResponse) (*openai.ChatCompletionResponse, error) {
+	return a.toolCalls(a.Context, response)
+}
+
+// ToolCallFilter chooses which of the tool calls in a model response are
+// executed, e.g. to drop ambiguous alternatives or reorder them by priority.
+type ToolCallFilter func([]openai.ToolCall) []openai.ToolCall
+
+// SetToolCallFilter installs a filter applied to the tool calls of every
+// response before they are executed or, in manual mode, returned as pending.
+// If it drops every call, the response holding them is returned as is. A nil
+// filter executes all calls.
+func (a *Agent) SetToolCallFilter(filter ToolCallFilter) {
+	a.mu.Lock()
+	a.toolCallFilter = filter
+	a.mu.Unlock()
+}
+
+func filterToolCalls(filter ToolCallFilter, toolCalls []openai.ToolCall) []openai.ToolCall {
+	if filter == nil || len(toolCalls) == 0 {
+		return toolCalls
+	}
+
+	return filter(append([]openai.ToolCall(nil), toolCalls...))
+}
+
+func (a *Agent) toolCalls(ctx context.Context, response openai.ChatCompletionResponse) (*openai.ChatCompletionResponse, error) {
+	// Fixed: Add recursion depth check to prevent infinite loops
+	a.mu.Lock()
+	currentDepth, maxToolCallDepth, maxToolsPerRound := a.currentDepth, a.maxToolCallDepth, a.maxToolsPerRound
+	filter := a.toolCallFilter
+	if a.toolExecutionMode == ToolExecutionManual {
+		a.pendingToolCalls = nil
+		for _, choice := range response.Choices {
+			a.pendingToolCalls = append(a.pendingToolCalls, filterToolCalls(filter, choice.Message.ToolCalls)...)
+		}
+		a.mu.Unlock()
+
+		// Return the response carrying the tool calls to the caller
+		return nil, nil
+	}
+	a.mu.Unlock()
+
+	if currentDepth >= maxToolCallDepth {
+		return nil, fmt.Errorf("maximum tool call depth (%d) exceeded", maxToolCallDepth)
+	}
+
+	if loopResponse, looping := a.detectToolLoop(response); looping {
+		return &loopResponse, nil
+	}
+
+	var toolResults []ToolResult
+	var executedCalls []openai.ToolCall
+	var totalToolExecCount int = 0
+
+	// Identical calls (same tool and arguments) within a round run once, so a
+	// repeated call to a non-idempotent tool cannot take effect twice
+	var calls []openai.ToolCall
+	executed := make(map[string]bool)
+	for _, choice := range response.Choices {
+		// The assistant message with the tool calls is only recorded, with
+		// the results, for providers that need it
+		for _, toolCall := range filterToolCalls(filter, choice.Message.ToolCalls) {
+			key := toolCallKey(toolCall)
+			if executed[key] {
+				continue
+			}
+			executed[key] = true
+			calls = append(calls, toolCall)
+		}
+	}
+
+	// The limits are checked before any call runs, so a round over them has
+	// no side effects
+	if maxToolsPerRound > 0 && len(calls) > maxToolsPerRound {
+		return nil, fmt.Errorf("maximum tool calls per round (%d) exceeded", maxToolsPerRound)
+	}
+	a.mu.Lock()
+	if a.maxTotalToolCalls > 0 && a.totalToolCalls+len(calls) > a.maxTotalToolCalls {
+		a.mu.Unlock()
+		return nil, fmt.Errorf("maximum total tool calls (%d) exceeded", a.maxTotalToolCalls)
+	}
+	a.mu.Unlock()
+
+	for _, toolCall := range calls {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		key := toolCallKey(toolCall)
+		a.mu.Lock()
+		a.totalToolCalls++
+		if a.toolCallCounts == nil {
+			a.toolCallCounts = make(map[string]int)
+		}
+		a.toolCallCounts[key]++
+		a.mu.Unlock()
+
+		if err := a.audit(AuditBefore, toolCall, "", nil, 0); err != nil {
+			return nil, err
+		}
+
+		toolCtx, span := startSpan(ctx, "sapiens.tool",
+			attribute.String("sapiens.tool.name", toolCall.Function.Name),
+			attribute.Int("sapiens.tool.args_size", len(toolCall.Function.Arguments)),
+		)
+
+		started := time.Now()
+		toolResponse, err := a.executeToolCall(toolCtx, toolCall)
+		duration := time.Since(started)
+		a.metricsCollector().ObserveToolCall(toolCall.Function.Name, duration, err)
+
+		endSpan(span, err, attribute.Int64("sapiens.tool.duration_ms", duration.Milliseconds()))
+		if auditErr := a.audit(AuditAfter, toolCall, toolResponse, err, duration); auditErr != nil && err == nil {
+			err = auditErr
+		}
+		if err != nil {
+			a.mu.Lock()
+			a.toolResultTrace = append(a.toolResultTrace, newToolResult(toolCall, "", err, duration))
+			a.mu.Unlock()
+			return nil, err
+		}
+
+		toolResults = append(toolResults, newToolResult(toolCall, toolResponse, nil, duration))
+		executedCalls = append(executedCalls, toolCall)
+
+		a.mu.Lock()
+		a.toolCallTrace = append(a.toolCallTrace, toolCallFromOpenAI(toolCall))
+		a.mu.Unlock()
+
+		totalToolExecCount++
+	}
+
+	// Fixed: Add tool responses using user message format for Gemini compatibility
+	if len(toolResults) > 0 {
+		a.mu.Lock()
+		a.appendToolResultsLocked(executedCalls, toolResults)
+		a.mu.Unlock()
+
+		// Fixed: Recursive call with proper termination condition and return final response
+		if totalToolExecCount > 0 {
+			finalResponse, err := a.AskAi(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return &finalResponse, nil
+		}
+	}
+
+	// No tool calls found, return nil to indicate original response should be used
+	return nil, nil
+}
+
+// detectToolLoop reports whether the response asks again for a call that has
+// already run as often as the tool loop limit allows during this turn. The
+// turn then ends with the response without its tool calls, keeping any text
+// the model sent along, or with a message naming the repeated call.
+func (a *Agent) detectToolLoop(response openai.ChatCompletionResponse) (openai.ChatCompletionResponse, bool) {
+	a.mu.Lock()
+	limit, counts, filter := a.toolLoopLimit, a.toolCallCounts, a.toolCallFilter
+	a.mu.Unlock()
+
+	if limit <= 0 || len(counts) == 0 {
+		return response, false
+	}
+
+	var repeated *openai.ToolCall
+	for _, choice := range response.Choices {
+		for _, toolCall := range filterToolCalls(filter, choice.Message.ToolCalls) {
+			if counts[toolCallKey(toolCall)] >= limit {
+				repeated = &toolCall
+				break
+			}
+		}
+		if repeated != nil {
+			break
+		}
+	}
+	if repeated == nil {
+		return response, false
+	}
+
+	choices := make([]openai.ChatCompletionChoice, len(response.Choices))
+	for i, choice := range response.Choices {
+		choice.Message.ToolCalls = nil
+		choice.FinishReason = openai.FinishReasonStop
+		if choice.Message.Content == "" {
+			choice.Message.Content = fmt.Sprintf(
+				"Tool loop detected: '%s' was called %d times with the same arguments without making progress, so I stopped here.",
+				repeated.Function.Name, counts[toolCallKey(*repeated)],
+			)
+		}
+		choices[i] = choice
+	}
+	response.Choices = choices
+
+	return response, true
+}
+
+// appendToolResultsLocked adds tool results to the conversation ahead of the
+// next round, in the configured ToolResultFormat. toolCalls are the calls the
+// results answer. The caller must hold a.mu.
+func (a *Agent) appendToolResultsLocked(toolCalls []openai.ToolCall, toolResults []ToolResult) {
+	a.toolResultTrace = append(a.toolResultTrace, toolResults...)
+
+	format := a.toolResultFormat
+	if format.Role != openai.ChatMessageRoleTool {
+		for _, toolResult := range toolResults {
+			// Use user message format instead of tool message for Gemini compatibility
+			toolMessage := NewMessages().UserMessage(
+				fmt.Sprintf("Tool '%s' returned: %s", toolResult.Name, truncateToolResult(toolResult.Result, a.maxToolResultSize)),
+			)
+			a.appendHistoryLocked(toolMessage)
+		}
+		a.currentDepth++ // Increment depth before recursive call
+		return
+	}
+
+	if format.RequiresToolCallID {
+		calls := make([]openai.ToolCall, len(toolCalls))
+		for i, toolCall := range toolCalls {
+			toolCall.Index = nil // only meaningful in streamed responses
+			calls[i] = toolCall
+		}
+		a.appendHistoryLocked(openai.ChatCompletionMessage{
+			Role:      openai.ChatMessageRoleAssistant,
+			ToolCalls: calls,
+		})
+	}
+
+	for _, toolResult := range toolResults {
+		toolMessage := openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleTool,
+			Name:    toolResult.Name,
+			Content: truncateToolResult(toolResult.Result, a.maxToolResultSize),
+		}
+		if format.RequiresToolCallID {
+			toolMessage.ToolCallID = toolResult.ToolCallID
+		}
+		a.appendHistoryLocked(toolMessage)
+	}
+	a.currentDepth++
+}
+
+// SetToolResultFormat selects how tool results are sent back to the model.
+// Providers report the format they need with their ToolResultFormat method;
+// the default is ToolResultsAsUser, which every provider accepts.
+func (a *Agent) SetToolResultFormat(format ToolResultFormat) error {
+	if format.Role != openai.ChatMessageRoleUser && format.Role != openai.ChatMessageRoleTool {
+		return fmt.Errorf("unsupported tool result role '%s'; expected '%s' or '%s'", format.Role, openai.ChatMessageRoleUser, openai.ChatMessageRoleTool)
+	}
+
+	a.mu.Lock()
+	a.toolResultFormat = format
+	a.mu.Unlock()
+
+	return nil
+}
+
+// SetToolExecutionMode selects whether tool calls are executed automatically
+// (the default) or returned to the caller. In manual mode Ask returns the
+// response holding the tool calls; PendingToolCalls lists them and
+// SubmitToolResults continues the conversation with the approved results.
+func (a *Agent) SetToolExecutionMode(mode ToolExecutionMode) {
+	a.mu.Lock()
+	a.toolExecutionMode = mode
+	a.mu.Unlock()
+}
+
+// PendingToolCalls returns the tool calls awaiting results in manual mode.
+func (a *Agent) PendingToolCalls() []openai.ToolCall {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return append([]openai.ToolCall(nil), a.pendingToolCalls...)
+}
+
+// SubmitToolResults hands the results of pending tool calls, keyed by tool
+// call ID, back to the model and continues the turn. Calls without a result
+// are reported to the model as not executed.
+func (a *Agent) SubmitToolResults(results map[string]string) (openai.ChatCompletionResponse, error) {
+	a.askMu.Lock()
+	defer a.askMu.Unlock()
+
+	a.mu.Lock()
+	if a.shutdown {
+		a.mu.Unlock()
+		return openai.ChatCompletionResponse{}, ErrAgentShutdown
+	}
+
+	pending := a.pendingToolCalls
+	if len(pending) == 0 {
+		a.mu.Unlock()
+		return openai.ChatCompletionResponse{}, fmt.Errorf("no pending tool calls")
+	}
+
+	known := make(map[string]bool, len(pending))
+	for _, toolCall := range pending {
+		known[toolCall.ID] = true
+	}
+	for id := range results {
+		if !known[id] {
+			a.mu.Unlock()
+			return openai.ChatCompletionResponse{}, fmt.Errorf("no pending tool call with ID '%s'", id)
+		}
+	}
+
+	var toolResults []ToolResult
+	for _, toolCall := range pending {
+		result, ok := results[toolCall.ID]
+		if !ok {
+			toolResults = append(toolResults, newToolResult(toolCall, "not executed: the call was not approved", ErrToolCallNotApproved, 0))
+			continue
+		}
+
+		a.toolCallTrace = append(a.toolCallTrace, toolCallFromOpenAI(toolCall))
+		toolResults = append(toolResults, newToolResult(toolCall, result, nil, 0))
+	}
+
+	a.pendingToolCalls = nil
+	a.appendToolResultsLocked(pending, toolResults)
+	model := a.Request.Model
+	a.mu.Unlock()
+
+	ctx, span := startSpan(a.Context, "sapiens.ask", attribute.String("gen_ai.request.model", model))
+	response, err := a.AskAi(ctx)
+	endSpan(span, err)
+
+	a.mu.Lock()
+	truncateResponse(&response, a.maxResponseLength)
+	a.mu.Unlock()
+
+	return response, err
+}
+
+// toolCallKey identifies a tool call by its name and arguments, ignoring
+// insignificant whitespace in the arguments.
+func toolCallKey(toolCall openai.ToolCall) string {
+	var arguments bytes.Buffer
+	if err := json.Compact(&arguments, []byte(toolCall.Function.Arguments)); err != nil {
+		return toolCall.Function.Name + "\x00" + toolCall.Function.Arguments
+	}
+
+	return toolCall.Function.Name + "\x00" + arguments.String()
+}
+
+// executeToolCall runs a single regular or MCP tool call and returns its output.
+func (a *Agent) executeToolCall(ctx context.Context, toolCall openai.ToolCall) (string, error) {
+	// First try to find regular tool
+	toolInst, toolInsErr := a.GetToolByName(toolCall.Function.Name)
+	if toolInsErr == nil {
+		// Regular tool found; it receives strings, so its arguments are not coerced
+		parsedArgs, err := decodeArguments(toolCall.Function.Arguments)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse tool arguments for '%s': %w", toolCall.Function.Name, err)
+		}
+
+		return toolInst.ToolFunction(stringifyArguments(parsedArgs)), nil
+	}
+
+	// Try MCP tool
+	mcpTool, mcpErr := a.GetMcpToolByName(toolCall.Function.Name)
+	if mcpErr != nil {
+		return "", fmt.Errorf("tool '%s' not found in regular or MCP tools: %w", toolCall.Function.Name, mcpErr)
+	}
+
+	// Parse arguments as generic map for MCP
+	parsedArgs, err := decodeArguments(toolCall.Function.Arguments)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse MCP tool arguments for '%s': %w", toolCall.Function.Name, err)
+	}
+
+	a.mu.Lock()
+	mcpClient := a.McpClient
+	a.mu.Unlock()
+
+	parsedArgs = coerceArguments(mcpTool.Name, mcpClient.ParseToolDefinition(mcpTool.InputSchema), parsedArgs, a.redact)
+
+	// Call MCP tool
+	mcpResult, mcpCallErr := mcpClient.CallToolContext(ctx, mcp.CallToolParams{
+		Name:      mcpTool.Name,
+		Arguments: parsedArgs,
+	})
+
+	if mcpCallErr != nil {
+		return "", fmt.Errorf("MCP tool call failed for '%s': %w", toolCall.Function.Name, mcpCallErr)
+	}
+
+	// Convert MCP result to string
+	if len(mcpResult.Content) > 0 {
+		return fmt.Sprintf("%v", mcpResult.Content[0]), nil
+	}
+
+	return "MCP tool executed successfully", nil
 }
 
 func (a *Agent) GetToolByName(name string) (AgentTool, error) {
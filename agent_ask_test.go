@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
 	"testing"
 
+	openai "github.com/sashabaranov/go-openai"
 	"github.com/sashabaranov/go-openai/jsonschema"
 )
 
@@ -128,3 +130,234 @@ func TestAgentAsk(t *testing.T) {
 	fmt.Println(resp.Choices[0].Message.Content)
 
 }
+
+func TestAgentAskWithoutSystemPrompt(t *testing.T) {
+	completer := newMockCompleter(textResponse("hello"))
+
+	agent := NewAgent(context.Background(), completer, "test-model", "")
+
+	message := NewMessages()
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("hi"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	sent := completer.Requests[0].Messages
+	for _, msg := range sent {
+		if msg.Role == openai.ChatMessageRoleSystem {
+			t.Fatalf("expected no system message, got %+v", msg)
+		}
+	}
+
+	if len(sent) != 1 || sent[0].Content != "hi" {
+		t.Errorf("unexpected messages sent: %+v", sent)
+	}
+}
+
+func TestAgentAskWithModel(t *testing.T) {
+	completer := newMockCompleter(
+		toolCallResponse(functionCall("call_1", "echo", `{"text":"hi"}`)),
+		textResponse("done"),
+		textResponse("default"),
+	)
+
+	agent := NewAgent(context.Background(), completer, "cheap-model", "you are a test agent")
+	agent.AddTool("echo", "Echo text", map[string]jsonschema.Definition{
+		"text": {Type: jsonschema.String},
+	}, []string{"text"}, func(parameters map[string]string) string {
+		return parameters["text"]
+	})
+
+	message := NewMessages()
+	if _, err := agent.AskWithModel("big-model", message.MergeMessages(message.UserMessage("hard question"))); err != nil {
+		t.Fatalf("AskWithModel error: %v", err)
+	}
+
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("easy question"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	models := []string{}
+	for _, request := range completer.Requests {
+		models = append(models, request.Model)
+	}
+
+	expected := []string{"big-model", "big-model", "cheap-model"}
+	if fmt.Sprint(models) != fmt.Sprint(expected) {
+		t.Errorf("expected models %v, got %v", expected, models)
+	}
+
+	agent.SetModel("other-model")
+	if agent.Model != "other-model" {
+		t.Errorf("SetModel did not update the default model")
+	}
+}
+
+func TestAgentStateless(t *testing.T) {
+	completer := newMockCompleter(
+		toolCallResponse(functionCall("call_1", "echo", `{"text":"hi"}`)),
+		textResponse("first"),
+		textResponse("second"),
+	)
+
+	agent := NewAgent(context.Background(), completer, "test-model", "you are a test agent")
+	agent.SetStateless(true)
+	agent.AddTool("echo", "Echo text", map[string]jsonschema.Definition{
+		"text": {Type: jsonschema.String},
+	}, []string{"text"}, func(parameters map[string]string) string {
+		return parameters["text"]
+	})
+
+	message := NewMessages()
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("first question"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("second question"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	if len(agent.MessagesHistory) != 0 {
+		t.Errorf("expected no persisted history, got %d messages", len(agent.MessagesHistory))
+	}
+
+	// The tool round still sees the tool result from the same turn
+	if got := len(completer.Requests[1].Messages); got != 3 {
+		t.Errorf("expected 3 messages in tool round, got %d", got)
+	}
+
+	// The next turn only carries its own messages
+	last := completer.Requests[2].Messages
+	if len(last) != 2 || last[1].Content != "second question" {
+		t.Errorf("unexpected messages in stateless turn: %+v", last)
+	}
+}
+
+func TestAgentSetContext(t *testing.T) {
+	completer := newMockCompleter(textResponse("first"), textResponse("second"))
+
+	agent := NewAgent(context.Background(), completer, "test-model", "you answer from the context")
+	agent.SetContext("The office opens at 9am.")
+
+	message := NewMessages()
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("when does the office open?"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	agent.UpdateContext("The office closes at 5pm.")
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("when does it close?"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	first := completer.Requests[0].Messages
+	if len(first) != 3 || first[0].Content != "you answer from the context" || first[1].Content != "Context:\nThe office opens at 9am." {
+		t.Errorf("expected the context right after the system prompt, got %+v", first)
+	}
+
+	second := completer.Requests[1].Messages
+	if second[1].Content != "Context:\nThe office opens at 9am.\n\nThe office closes at 5pm." {
+		t.Errorf("expected the updated context, got %q", second[1].Content)
+	}
+
+	for _, msg := range agent.MessagesHistory {
+		if strings.HasPrefix(msg.Content, "Context:") {
+			t.Fatalf("context should not be stored in history: %+v", agent.MessagesHistory)
+		}
+	}
+}
+
+func TestAgentFewShotExamples(t *testing.T) {
+	completer := newMockCompleter(textResponse("negative"))
+
+	agent := NewAgent(context.Background(), completer, "test-model", "classify the sentiment")
+	agent.SetContext("Reviews are about a restaurant.")
+	agent.SetFewShotExamples(
+		FewShotExample{User: "The food was great!", Assistant: "positive"},
+		FewShotExample{User: "It was fine.", Assistant: "neutral"},
+	)
+
+	message := NewMessages()
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("Cold soup, rude staff."))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	want := []struct{ role, content string }{
+		{openai.ChatMessageRoleSystem, "classify the sentiment"},
+		{openai.ChatMessageRoleSystem, "Context:\nReviews are about a restaurant."},
+		{openai.ChatMessageRoleUser, "The food was great!"},
+		{openai.ChatMessageRoleAssistant, "positive"},
+		{openai.ChatMessageRoleUser, "It was fine."},
+		{openai.ChatMessageRoleAssistant, "neutral"},
+		{openai.ChatMessageRoleUser, "Cold soup, rude staff."},
+	}
+
+	sent := completer.Requests[0].Messages
+	if len(sent) != len(want) {
+		t.Fatalf("sent %d messages, want %d: %+v", len(sent), len(want), sent)
+	}
+	for i, w := range want {
+		if sent[i].Role != w.role || sent[i].Content != w.content {
+			t.Errorf("message %d = %s %q, want %s %q", i, sent[i].Role, sent[i].Content, w.role, w.content)
+		}
+	}
+
+	if len(agent.MessagesHistory) != 2 {
+		t.Errorf("examples should not be stored in history: %+v", agent.MessagesHistory)
+	}
+}
+
+func TestAgentRedactedHistory(t *testing.T) {
+	completer := newMockCompleter(textResponse("link created"))
+
+	agent := NewAgent(context.Background(), completer, "test-model", "you create payment links")
+	agent.SetRedactor(func(text string) string {
+		return strings.ReplaceAll(text, "a@paytring.com", "[email]")
+	})
+
+	message := NewMessages()
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("create link 200 rupee for a@paytring.com"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	sent := completer.Requests[0].Messages
+	if got := sent[len(sent)-1].Content; got != "create link 200 rupee for a@paytring.com" {
+		t.Errorf("the model should see the original message, got %q", got)
+	}
+
+	redacted := agent.RedactedHistory()
+	if got := redacted[len(redacted)-1].Content; got != "create link 200 rupee for [email]" {
+		t.Errorf("expected a redacted copy, got %q", got)
+	}
+
+	if got := agent.MessagesHistory[len(agent.MessagesHistory)-1].Content; got != "create link 200 rupee for a@paytring.com" {
+		t.Errorf("redaction should not modify the stored history, got %q", got)
+	}
+}
+
+func TestAgentSystemPromptPosition(t *testing.T) {
+	roles := func(messages []openai.ChatCompletionMessage) string {
+		var parts []string
+		for _, msg := range messages {
+			parts = append(parts, msg.Role+":"+msg.Content)
+		}
+		return strings.Join(parts, " | ")
+	}
+
+	completer := newMockCompleter(textResponse("one"), textResponse("two"), textResponse("three"))
+	agent := NewAgent(context.Background(), completer, "test-model", "be brief")
+	agent.SetSystemPromptPosition(SystemPromptBottom)
+
+	message := NewMessages()
+	agent.Ask(message.MergeMessages(message.UserMessage("first")))
+	agent.Ask(message.MergeMessages(message.UserMessage("second")))
+
+	if got, want := roles(completer.Requests[1].Messages), "user:first | system:be brief | user:second"; got != want {
+		t.Errorf("bottom: got %q, want %q", got, want)
+	}
+
+	agent.SetSystemPromptPosition(SystemPromptBoth)
+	agent.SetStateless(true)
+	agent.Ask(message.MergeMessages(message.UserMessage("third")))
+
+	if got, want := roles(completer.Requests[2].Messages), "system:be brief | system:be brief | user:third"; got != want {
+		t.Errorf("both: got %q, want %q", got, want)
+	}
+}
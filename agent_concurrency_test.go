@@ -0,0 +1,59 @@
+package sapiens
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+func TestAgentConcurrentAsk(t *testing.T) {
+	const callers = 8
+
+	var responses []openai.ChatCompletionResponse
+	for i := 0; i < callers; i++ {
+		responses = append(responses,
+			toolCallResponse(functionCall("call", "echo", `{"text":"hi"}`)),
+			textResponse("done"),
+		)
+	}
+
+	agent := NewAgent(context.Background(), newMockCompleter(responses...), "test-model", "you are a test agent")
+	agent.AddTool("echo", "Echo text", map[string]jsonschema.Definition{
+		"text": {Type: jsonschema.String},
+	}, []string{"text"}, func(parameters map[string]string) string {
+		return parameters["text"]
+	})
+
+	message := NewMessages()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := agent.Ask(message.MergeMessages(message.UserMessage("echo hi")))
+			if err != nil {
+				errs <- err
+				return
+			}
+			if resp.Choices[0].Message.Content != "done" {
+				t.Errorf("unexpected content: %q", resp.Choices[0].Message.Content)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("concurrent Ask failed: %v", err)
+	}
+
+	// Each turn adds a system message, a user message and a tool result.
+	if got := len(agent.MessagesHistory); got != callers*3 {
+		t.Errorf("expected %d history messages, got %d", callers*3, got)
+	}
+}
@@ -4,7 +4,12 @@ import (
 	"context"
 	"log"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
 )
 
 func TestAgentStructuredResponse(t *testing.T) {
@@ -40,3 +45,456 @@ func TestAgentStructuredResponse(t *testing.T) {
 	log.Printf("Unmarshalled result: %+v", result)
 
 }
+
+func TestAgentAskStructured(t *testing.T) {
+	client, _ := newScriptedClient(t,
+		toolCallResponse(functionCall("call_1", "get_weather", `{"location":"Delhi, India"}`)),
+		textResponse(`{"final_answer":"It is sunny in Delhi"}`),
+	)
+
+	agent := NewAgent(context.Background(), client, "test-model", "you are a weather reporter")
+
+	agent.AddTool("get_weather", "Get the weather", map[string]jsonschema.Definition{
+		"location": {Type: jsonschema.String},
+	}, []string{"location"}, func(parameters map[string]string) string {
+		return `{"condition":"sunny"}`
+	})
+
+	type Result struct {
+		FinalAnswer string `json:"final_answer"`
+	}
+	agent.SetResponseSchema("weather", "weather answer", true, Result{})
+
+	message := NewMessages()
+	resp, err := agent.AskStructured(message.MergeMessages(
+		message.UserMessage("what is the weather in delhi"),
+	))
+	if err != nil {
+		t.Fatalf("AskStructured error: %v", err)
+	}
+
+	if resp.Content != `{"final_answer":"It is sunny in Delhi"}` {
+		t.Errorf("unexpected content: %q", resp.Content)
+	}
+
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Name != "get_weather" || resp.ToolCalls[0].ID != "call_1" {
+		t.Errorf("unexpected tool calls: %+v", resp.ToolCalls)
+	}
+
+	structured, ok := resp.Structured.(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected structured map, got %T", resp.Structured)
+	}
+	if structured["final_answer"] != "It is sunny in Delhi" {
+		t.Errorf("unexpected structured answer: %v", structured["final_answer"])
+	}
+
+	if len(resp.Raw.Choices) != 1 {
+		t.Errorf("expected raw response to be preserved")
+	}
+}
+
+func TestAskJSON(t *testing.T) {
+	type Weather struct {
+		Location    string `json:"location"`
+		Temperature int    `json:"temperature"`
+	}
+
+	completer := newMockCompleter(textResponse("```json\n{\"location\":\"Delhi\",\"temperature\":27}\n```"))
+	agent := NewAgent(context.Background(), completer, "test-model", "you are a weather reporter")
+
+	message := NewMessages()
+	weather, err := AskJSON[Weather](agent, message.MergeMessages(message.UserMessage("weather in delhi")))
+	if err != nil {
+		t.Fatalf("AskJSON error: %v", err)
+	}
+
+	if weather.Location != "Delhi" || weather.Temperature != 27 {
+		t.Errorf("unexpected result: %+v", weather)
+	}
+
+	format := completer.Requests[0].ResponseFormat
+	if format == nil || format.JSONSchema == nil || format.JSONSchema.Name != "Weather" {
+		t.Fatalf("expected Weather response schema, got %+v", format)
+	}
+
+	if agent.StructuredResponseSchema != nil {
+		t.Error("AskJSON should not change the agent's response schema")
+	}
+}
+
+func TestStructuredArrayRoot(t *testing.T) {
+	type Item struct {
+		Name  string `json:"name"`
+		Price int    `json:"price"`
+	}
+
+	completer := newMockCompleter(
+		textResponse(`{"items":[{"name":"pen","price":2},{"name":"book","price":12}]}`),
+		textResponse(`[{"name":"mug","price":8}]`),
+		textResponse(`{"items":[{"name":"lamp","price":30}]}`),
+	)
+	agent := NewAgent(context.Background(), completer, "test-model", "you list products")
+
+	message := NewMessages()
+	items, err := AskJSON[[]Item](agent, message.MergeMessages(message.UserMessage("list two products")))
+	if err != nil {
+		t.Fatalf("AskJSON error: %v", err)
+	}
+	if len(items) != 2 || items[0].Name != "pen" || items[1].Price != 12 {
+		t.Errorf("unexpected items: %+v", items)
+	}
+
+	// Providers only accept object roots, so the array is wrapped
+	schema, ok := completer.Requests[0].ResponseFormat.JSONSchema.Schema.(*jsonschema.Definition)
+	if !ok || schema.Type != jsonschema.Object || schema.Properties["items"].Type != jsonschema.Array {
+		t.Fatalf("expected the array schema wrapped in an object, got %+v", completer.Requests[0].ResponseFormat.JSONSchema.Schema)
+	}
+
+	// A model answering with a bare array is accepted as well
+	agent.SetResponseSchema("products", "", true, []Item{})
+	resp, err := agent.Ask(message.MergeMessages(message.UserMessage("list one product")))
+	if err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	var parsed []Item
+	if err := agent.ParseResponse(resp, &parsed); err != nil {
+		t.Fatalf("ParseResponse error: %v", err)
+	}
+	if len(parsed) != 1 || parsed[0].Name != "mug" {
+		t.Errorf("unexpected parsed items: %+v", parsed)
+	}
+
+	if schema := agent.StructuredResponseSchema.JSONSchema.Schema.(*jsonschema.Definition); schema.Type != jsonschema.Object {
+		t.Errorf("expected SetResponseSchema to wrap the array schema, got %+v", schema)
+	}
+
+	if err := agent.ParseResponse(textResponse(`{"items":[{"name":"cup","price":5}]}`), &parsed); err != nil || parsed[0].Name != "cup" {
+		t.Errorf("ParseResponse of a wrapped array = %+v, %v", parsed, err)
+	}
+
+	structured, err := agent.AskStructured(message.MergeMessages(message.UserMessage("list a lamp")))
+	if err != nil {
+		t.Fatalf("AskStructured error: %v", err)
+	}
+	products, ok := structured.Structured.([]interface{})
+	if !ok || len(products) != 1 || products[0].(map[string]interface{})["name"] != "lamp" {
+		t.Errorf("AskStructured of a wrapped array = %#v, want the array", structured.Structured)
+	}
+	if !reflect.DeepEqual(structured.Candidates[0].Structured, structured.Structured) {
+		t.Errorf("candidate = %#v, want %#v", structured.Candidates[0].Structured, structured.Structured)
+	}
+}
+
+func TestStripMarkdownFences(t *testing.T) {
+	cases := map[string]string{
+		`{"a":1}`:                  `{"a":1}`,
+		"```json\n{\"a\":1}\n```":  `{"a":1}`,
+		"```\n{\"a\":1}\n```":      `{"a":1}`,
+		"  ```json {\"a\":1}```  ": `{"a":1}`,
+		"```JSON\n[1,2]\n```\n":    `[1,2]`,
+	}
+
+	for input, want := range cases {
+		if got := stripMarkdownFences(input); got != want {
+			t.Errorf("stripMarkdownFences(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestAgentAskStructuredCandidates(t *testing.T) {
+	completer := newMockCompleter(openai.ChatCompletionResponse{
+		Choices: []openai.ChatCompletionChoice{
+			{Index: 0, Message: openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: `{"subject":"Big sale"}`}},
+			{Index: 1, Message: openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: `{"subject":"Last chance"}`}},
+			{Index: 2, Message: openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: `{"subject":"Just for you"}`}},
+		},
+	})
+
+	agent := NewAgent(context.Background(), completer, "test-model", "you write email subject lines")
+	agent.SetCandidateCount(3)
+
+	type Subject struct {
+		Subject string `json:"subject"`
+	}
+	agent.SetResponseSchema("subject", "an email subject line", true, Subject{})
+
+	message := NewMessages()
+	resp, err := agent.AskStructured(message.MergeMessages(message.UserMessage("write a subject line")))
+	if err != nil {
+		t.Fatalf("AskStructured error: %v", err)
+	}
+
+	if completer.Requests[0].N != 3 {
+		t.Errorf("expected N=3 in request, got %d", completer.Requests[0].N)
+	}
+
+	if len(resp.Candidates) != 3 {
+		t.Fatalf("expected 3 candidates, got %d", len(resp.Candidates))
+	}
+
+	last, ok := resp.Candidates[2].Structured.(map[string]interface{})
+	if !ok || last["subject"] != "Just for you" {
+		t.Errorf("unexpected last candidate: %+v", resp.Candidates[2])
+	}
+
+	if resp.Content != `{"subject":"Big sale"}` {
+		t.Errorf("expected content to mirror the first candidate, got %q", resp.Content)
+	}
+}
+
+func TestAgentStructuredRetries(t *testing.T) {
+	type Answer struct {
+		Answer string `json:"answer"`
+	}
+
+	completer := newMockCompleter(
+		textResponse(`Sure! Here is the JSON: {"answer": "42"`),
+		textResponse(`{"answer":"42"}`),
+	)
+
+	agent := NewAgent(context.Background(), completer, "test-model", "you answer questions")
+	agent.SetStructuredRetries(2)
+
+	message := NewMessages()
+	answer, err := AskJSON[Answer](agent, message.MergeMessages(message.UserMessage("what is the answer?")))
+	if err != nil {
+		t.Fatalf("AskJSON error: %v", err)
+	}
+
+	if answer.Answer != "42" {
+		t.Errorf("unexpected answer: %+v", answer)
+	}
+
+	if len(completer.Requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(completer.Requests))
+	}
+
+	retry := completer.Requests[1].Messages
+	if last := retry[len(retry)-1]; last.Content != invalidJSONCorrection {
+		t.Errorf("expected corrective message, got %q", last.Content)
+	}
+}
+
+func TestAgentStructuredRetriesDisabledByDefault(t *testing.T) {
+	completer := newMockCompleter(textResponse(`not json`))
+
+	agent := NewAgent(context.Background(), completer, "test-model", "you answer questions")
+	agent.SetResponseSchema("answer", "an answer", true, struct {
+		Answer string `json:"answer"`
+	}{})
+
+	message := NewMessages()
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("what is the answer?"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	if len(completer.Requests) != 1 {
+		t.Errorf("expected no retries by default, got %d requests", len(completer.Requests))
+	}
+}
+
+func TestCompletePartialJSON(t *testing.T) {
+	cases := map[string]string{
+		`{"title":"Hel`:                   `{"title":"Hel"}`,
+		`{"title":"Hello","tags":["a","b`: `{"title":"Hello","tags":["a","b"]}`,
+		`{"title":"Hello",`:               `{"title":"Hello"}`,
+		`{"title":"Hello","ta`:            `{"title":"Hello"}`,
+		`{"title":"Hello","done":tr`:      `{"title":"Hello"}`,
+		`{"title":"Hello","count":`:       `{"title":"Hello","count":null}`,
+		`{"a":{"b`:                        `{"a":{}}`,
+		`{"path":"C:\`:                    `{"path":"C:"}`,
+		`[1,2`:                            `[1,2]`,
+	}
+
+	for input, want := range cases {
+		got, ok := completePartialJSON(input)
+		if !ok || got != want {
+			t.Errorf("completePartialJSON(%q) = %q, %v; want %q", input, got, ok, want)
+		}
+	}
+
+	if _, ok := completePartialJSON(""); ok {
+		t.Error("expected nothing to be recovered from empty input")
+	}
+}
+
+func TestAskStructuredStream(t *testing.T) {
+	type Article struct {
+		Title string   `json:"title"`
+		Tags  []string `json:"tags"`
+	}
+
+	client, script := newStreamingClient(t, contentChunks(
+		`{"title":"Go `, `streaming","ta`, `gs":["go",`, `"json"]}`,
+	))
+	agent := NewAgent(context.Background(), client, "test-model", "you write articles")
+
+	var updates []Article
+	message := NewMessages()
+	article, err := AskStructuredStream(agent, message.MergeMessages(message.UserMessage("write an article")), func(partial Article) {
+		updates = append(updates, partial)
+	})
+	if err != nil {
+		t.Fatalf("AskStructuredStream error: %v", err)
+	}
+
+	if article.Title != "Go streaming" || len(article.Tags) != 2 {
+		t.Errorf("unexpected final article: %+v", article)
+	}
+
+	if len(updates) < 3 {
+		t.Fatalf("expected progressive updates, got %+v", updates)
+	}
+	if updates[0].Title != "Go " {
+		t.Errorf("expected the first update to hold the partial title, got %+v", updates[0])
+	}
+	if last := updates[len(updates)-1]; last.Title != article.Title || len(last.Tags) != 2 {
+		t.Errorf("expected the last update to be the complete value, got %+v", last)
+	}
+
+	if !script.Requests[0].Stream || len(script.Requests[0].ResponseFormat) == 0 {
+		t.Error("expected a streamed request carrying the response schema")
+	}
+}
+
+func TestAskStructuredStreamWithToolCalls(t *testing.T) {
+	type Forecast struct {
+		Location  string `json:"location"`
+		Condition string `json:"condition"`
+	}
+
+	toolCall := func(call openai.ToolCall) openai.ChatCompletionStreamResponse {
+		return openai.ChatCompletionStreamResponse{Choices: []openai.ChatCompletionStreamChoice{
+			{Delta: openai.ChatCompletionStreamChoiceDelta{ToolCalls: []openai.ToolCall{call}}},
+		}}
+	}
+
+	// The tool round mixes content with a tool call whose arguments are split
+	// over several chunks
+	toolRound := []openai.ChatCompletionStreamResponse{
+		toolCall(openai.ToolCall{Index: intPtr(0), ID: "call_1", Type: openai.ToolTypeFunction, Function: openai.FunctionCall{Name: "get_weather"}}),
+		contentChunks(`{"location":"`)[0],
+		toolCall(openai.ToolCall{Index: intPtr(0), Function: openai.FunctionCall{Arguments: `{"location":`}}),
+		toolCall(openai.ToolCall{Index: intPtr(0), Function: openai.FunctionCall{Arguments: `"Paris"}`}}),
+		{Choices: []openai.ChatCompletionStreamChoice{{FinishReason: openai.FinishReasonToolCalls}}},
+	}
+
+	client, script := newStreamingClient(t, toolRound, contentChunks(`{"location":"Par`, `is","condition":"sun`, `ny"}`))
+	agent := NewAgent(context.Background(), client, "test-model", "you report the weather")
+
+	var location string
+	agent.AddTool("get_weather", "Get the weather", map[string]jsonschema.Definition{
+		"location": {Type: jsonschema.String},
+	}, []string{"location"}, func(parameters map[string]string) string {
+		location = parameters["location"]
+		return `{"condition":"sunny"}`
+	})
+
+	var updates []Forecast
+	message := NewMessages()
+	forecast, err := AskStructuredStream(agent, message.MergeMessages(message.UserMessage("weather in paris?")), func(partial Forecast) {
+		updates = append(updates, partial)
+	})
+	if err != nil {
+		t.Fatalf("AskStructuredStream error: %v", err)
+	}
+
+	if location != "Paris" {
+		t.Errorf("expected the streamed tool call to run with its assembled arguments, got location %q", location)
+	}
+	if forecast.Location != "Paris" || forecast.Condition != "sunny" {
+		t.Errorf("unexpected final forecast: %+v", forecast)
+	}
+
+	// Only the answer round produces updates, each a prefix of the answer
+	for _, update := range updates {
+		if update.Location == "" || !strings.HasPrefix("Paris", update.Location) {
+			t.Errorf("unexpected update from outside the answer: %+v", update)
+		}
+	}
+
+	if len(script.Requests) != 2 || len(script.Requests[1].ResponseFormat) == 0 {
+		t.Errorf("expected both rounds to carry the response schema, got %d requests", len(script.Requests))
+	}
+}
+
+type benchmarkOrder struct {
+	ID       string `json:"id" description:"Order identifier"`
+	Customer struct {
+		Name    string `json:"name"`
+		Email   string `json:"email"`
+		Address struct {
+			Street  string `json:"street"`
+			City    string `json:"city"`
+			Country string `json:"country" enum:"IN,US,DE"`
+		} `json:"address"`
+	} `json:"customer"`
+	Items []struct {
+		SKU      string   `json:"sku"`
+		Quantity int      `json:"quantity"`
+		Price    float64  `json:"price"`
+		Tags     []string `json:"tags"`
+	} `json:"items"`
+	Paid bool `json:"paid"`
+}
+
+func TestGenerateSchemaCached(t *testing.T) {
+	first, err := generateSchema(benchmarkOrder{})
+	if err != nil {
+		t.Fatalf("generateSchema error: %v", err)
+	}
+	second, err := generateSchema(benchmarkOrder{})
+	if err != nil {
+		t.Fatalf("generateSchema error: %v", err)
+	}
+
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("cached schema differs: %+v vs %+v", first, second)
+	}
+	if first == second {
+		t.Errorf("expected each call to get its own root definition")
+	}
+
+	second.Description = "changed"
+	if third, _ := generateSchema(benchmarkOrder{}); third.Description != "" {
+		t.Errorf("changing a returned schema changed the cache")
+	}
+
+	// Nested properties and items are not shared with the cache either
+	first.Properties["id"] = jsonschema.Definition{Type: jsonschema.Integer}
+	first.Properties["customer"].Properties["name"] = jsonschema.Definition{Type: jsonschema.Integer}
+	first.Properties["items"].Items.Properties["sku"] = jsonschema.Definition{Type: jsonschema.Integer}
+	first.Properties["items"].Items.Required[0] = "changed"
+
+	third, err := generateSchema(benchmarkOrder{})
+	if err != nil {
+		t.Fatalf("generateSchema error: %v", err)
+	}
+	want, err := jsonschema.GenerateSchemaForType(benchmarkOrder{})
+	if err != nil {
+		t.Fatalf("GenerateSchemaForType error: %v", err)
+	}
+	if !reflect.DeepEqual(third, want) {
+		t.Errorf("changing a returned schema's properties changed the cache: %+v", third)
+	}
+}
+
+func BenchmarkGenerateSchema(b *testing.B) {
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := jsonschema.GenerateSchemaForType(benchmarkOrder{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := generateSchema(benchmarkOrder{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,606 @@
+package sapiens
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+func TestAgentAddToolDuplicate(t *testing.T) {
+	agent := NewAgent(context.Background(), nil, "test-model", "you are a test agent")
+
+	params := map[string]jsonschema.Definition{
+		"location": {Type: jsonschema.String},
+	}
+
+	if err := agent.AddTool("get_weather", "first", params, []string{"location"}, func(map[string]string) string { return "first" }); err != nil {
+		t.Fatalf("unexpected error adding tool: %v", err)
+	}
+
+	if err := agent.AddTool("get_weather", "second", params, []string{"location"}, func(map[string]string) string { return "second" }); err == nil {
+		t.Fatal("expected error when adding a duplicate tool")
+	}
+
+	if len(agent.Tools) != 1 {
+		t.Fatalf("expected 1 tool, got %d", len(agent.Tools))
+	}
+
+	if err := agent.ReplaceTool("get_weather", "second", params, []string{"location"}, func(map[string]string) string { return "second" }); err != nil {
+		t.Fatalf("unexpected error replacing tool: %v", err)
+	}
+
+	tool, err := agent.GetToolByName("get_weather")
+	if err != nil {
+		t.Fatalf("tool not found after replace: %v", err)
+	}
+
+	if got := tool.ToolFunction(nil); got != "second" {
+		t.Errorf("expected replaced tool to return %q, got %q", "second", got)
+	}
+
+	if len(agent.Tools) != 1 {
+		t.Errorf("expected 1 tool after replace, got %d", len(agent.Tools))
+	}
+}
+
+func TestAgentAddToolConflictsWithMcpTool(t *testing.T) {
+	agent := NewAgent(context.Background(), nil, "test-model", "you are a test agent")
+	agent.McpTools = []mcp.Tool{{Name: "createOrder"}}
+
+	err := agent.AddTool("createOrder", "local order tool", nil, nil, func(map[string]string) string { return "" })
+	if err == nil {
+		t.Fatal("expected error when tool name conflicts with an MCP tool")
+	}
+
+	err = agent.ReplaceTool("createOrder", "local order tool", nil, nil, func(map[string]string) string { return "" })
+	if err == nil {
+		t.Fatal("expected error when replacing an MCP tool")
+	}
+}
+
+func TestAgentToolLoopWithMockCompleter(t *testing.T) {
+	completer := newMockCompleter(
+		toolCallResponse(functionCall("call_1", "get_weather", `{"location":"London, UK"}`)),
+		textResponse("It is cloudy in London"),
+	)
+
+	agent := NewAgent(context.Background(), completer, "test-model", "you are a weather reporter")
+
+	var calledWith string
+	agent.AddTool("get_weather", "Get the weather", map[string]jsonschema.Definition{
+		"location": {Type: jsonschema.String},
+	}, []string{"location"}, func(parameters map[string]string) string {
+		calledWith = parameters["location"]
+		return `{"condition":"cloudy"}`
+	})
+
+	message := NewMessages()
+	resp, err := agent.Ask(message.MergeMessages(message.UserMessage("weather in london?")))
+	if err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	if calledWith != "London, UK" {
+		t.Errorf("tool called with %q", calledWith)
+	}
+
+	if resp.Choices[0].Message.Content != "It is cloudy in London" {
+		t.Errorf("unexpected final content: %q", resp.Choices[0].Message.Content)
+	}
+
+	if len(completer.Requests) != 2 {
+		t.Fatalf("expected 2 completion requests, got %d", len(completer.Requests))
+	}
+
+	if len(completer.Requests[0].Tools) != 1 {
+		t.Errorf("expected tool definition to be sent, got %d tools", len(completer.Requests[0].Tools))
+	}
+}
+
+func TestAgentMaxToolsPerRound(t *testing.T) {
+	completer := newMockCompleter(
+		toolCallResponse(
+			functionCall("call_1", "echo", `{"text":"1"}`),
+			functionCall("call_2", "echo", `{"text":"2"}`),
+			functionCall("call_3", "echo", `{"text":"3"}`),
+		),
+		textResponse("done"),
+	)
+
+	agent := NewAgent(context.Background(), completer, "test-model", "you are a test agent")
+	agent.SetMaxToolsPerRound(2)
+
+	executions := 0
+	agent.AddTool("echo", "Echo text", map[string]jsonschema.Definition{
+		"text": {Type: jsonschema.String},
+	}, []string{"text"}, func(parameters map[string]string) string {
+		executions++
+		return parameters["text"]
+	})
+
+	message := NewMessages()
+	_, err := agent.Ask(message.MergeMessages(message.UserMessage("echo three times")))
+	if err == nil {
+		t.Fatal("expected error when tool calls per round are exceeded")
+	}
+
+	if executions != 0 {
+		t.Errorf("expected no executions when the round is over the limit, got %d", executions)
+	}
+}
+
+func TestAgentToolCallLimitsDisabled(t *testing.T) {
+	completer := newMockCompleter(
+		toolCallResponse(
+			functionCall("call_1", "echo", `{"text":"1"}`),
+			functionCall("call_2", "echo", `{"text":"2"}`),
+		),
+		textResponse("done"),
+	)
+
+	agent := NewAgent(context.Background(), completer, "test-model", "you are a test agent")
+	agent.SetMaxToolsPerRound(0)
+	agent.SetMaxTotalToolCalls(0)
+
+	executions := 0
+	agent.AddTool("echo", "Echo text", map[string]jsonschema.Definition{
+		"text": {Type: jsonschema.String},
+	}, []string{"text"}, func(parameters map[string]string) string {
+		executions++
+		return parameters["text"]
+	})
+
+	message := NewMessages()
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("echo twice"))); err != nil {
+		t.Fatalf("Ask error with the limits disabled: %v", err)
+	}
+
+	if executions != 2 {
+		t.Errorf("expected 2 executions with the limits disabled, got %d", executions)
+	}
+}
+
+func TestAgentMaxTotalToolCalls(t *testing.T) {
+	completer := newMockCompleter(
+		toolCallResponse(functionCall("call_1", "echo", `{"text":"1"}`)),
+		toolCallResponse(functionCall("call_2", "echo", `{"text":"2"}`)),
+		toolCallResponse(functionCall("call_3", "echo", `{"text":"3"}`)),
+		textResponse("done"),
+	)
+
+	agent := NewAgent(context.Background(), completer, "test-model", "you are a test agent")
+	agent.SetMaxTotalToolCalls(2)
+
+	agent.AddTool("echo", "Echo text", map[string]jsonschema.Definition{
+		"text": {Type: jsonschema.String},
+	}, []string{"text"}, func(parameters map[string]string) string {
+		return parameters["text"]
+	})
+
+	message := NewMessages()
+	_, err := agent.Ask(message.MergeMessages(message.UserMessage("echo repeatedly")))
+	if err == nil {
+		t.Fatal("expected error when total tool calls are exceeded")
+	}
+}
+
+func TestAgentParallelToolCalls(t *testing.T) {
+	client, script := newScriptedClient(t, textResponse("done"), textResponse("done"))
+
+	agent := NewAgent(context.Background(), client, "test-model", "you are a test agent")
+	agent.AddTool("echo", "Echo text", map[string]jsonschema.Definition{
+		"text": {Type: jsonschema.String},
+	}, []string{"text"}, func(parameters map[string]string) string {
+		return parameters["text"]
+	})
+
+	message := NewMessages()
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("hi"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	if script.Requests[0].ParallelToolCalls != nil {
+		t.Errorf("expected parallel_tool_calls to be omitted by default, got %v", script.Requests[0].ParallelToolCalls)
+	}
+
+	agent.SetParallelToolCalls(false)
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("hi"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	if script.Requests[1].ParallelToolCalls != false {
+		t.Errorf("expected parallel_tool_calls=false, got %v", script.Requests[1].ParallelToolCalls)
+	}
+}
+
+func TestAgentDeduplicatesIdenticalToolCalls(t *testing.T) {
+	completer := newMockCompleter(
+		toolCallResponse(
+			functionCall("call_1", "create_order", `{"amount":"200","email":"a@example.com"}`),
+			functionCall("call_2", "create_order", `{"amount": "200", "email": "a@example.com"}`),
+			functionCall("call_3", "create_order", `{"amount":"300","email":"a@example.com"}`),
+		),
+		textResponse("orders created"),
+	)
+
+	agent := NewAgent(context.Background(), completer, "test-model", "you create orders")
+
+	var amounts []string
+	agent.AddTool("create_order", "Create an order", map[string]jsonschema.Definition{
+		"amount": {Type: jsonschema.String},
+		"email":  {Type: jsonschema.String},
+	}, []string{"amount", "email"}, func(parameters map[string]string) string {
+		amounts = append(amounts, parameters["amount"])
+		return `{"status":"created"}`
+	})
+
+	message := NewMessages()
+	resp, err := agent.AskStructured(message.MergeMessages(message.UserMessage("create the orders")))
+	if err != nil {
+		t.Fatalf("AskStructured error: %v", err)
+	}
+
+	if len(amounts) != 2 || amounts[0] != "200" || amounts[1] != "300" {
+		t.Errorf("expected the duplicate call to run once, got executions %v", amounts)
+	}
+
+	if len(resp.ToolCalls) != 2 {
+		t.Errorf("expected 2 executed tool calls, got %+v", resp.ToolCalls)
+	}
+
+	if len(resp.ToolResults) != 2 {
+		t.Fatalf("expected 2 tool results, got %+v", resp.ToolResults)
+	}
+	for i, result := range resp.ToolResults {
+		if result.ToolCallID != resp.ToolCalls[i].ID || result.Name != "create_order" || result.Err != nil {
+			t.Errorf("unexpected tool result %+v", result)
+		}
+		if parsed, ok := result.Parsed.(map[string]interface{}); !ok || parsed["status"] != "created" {
+			t.Errorf("expected the parsed result, got %#v", result.Parsed)
+		}
+	}
+}
+
+func TestAgentToolCallFilter(t *testing.T) {
+	completer := newMockCompleter(
+		toolCallResponse(
+			functionCall("call_1", "search_web", `{"query":"go generics"}`),
+			functionCall("call_2", "search_docs", `{"query":"go generics"}`),
+		),
+		textResponse("found it"),
+	)
+
+	agent := NewAgent(context.Background(), completer, "test-model", "you search")
+
+	var called []string
+	for _, name := range []string{"search_web", "search_docs"} {
+		name := name
+		agent.AddTool(name, "Search", map[string]jsonschema.Definition{
+			"query": {Type: jsonschema.String},
+		}, []string{"query"}, func(parameters map[string]string) string {
+			called = append(called, name)
+			return "results"
+		})
+	}
+
+	// Prefer the docs over the web when the model asks for both
+	agent.SetToolCallFilter(func(calls []openai.ToolCall) []openai.ToolCall {
+		for _, call := range calls {
+			if call.Function.Name == "search_docs" {
+				return []openai.ToolCall{call}
+			}
+		}
+		return calls
+	})
+
+	message := NewMessages()
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("how do generics work?"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	if len(called) != 1 || called[0] != "search_docs" {
+		t.Errorf("executed tools %v, want [search_docs]", called)
+	}
+}
+
+func TestAgentToolResultsAsToolMessages(t *testing.T) {
+	completer := newMockCompleter(
+		toolCallResponse(
+			functionCall("call_1", "get_weather", `{"location":"Paris"}`),
+			functionCall("call_2", "get_weather", `{"location":"Paris"}`),
+		),
+		textResponse("It is sunny."),
+	)
+
+	agent := NewAgent(context.Background(), completer, "test-model", "you report the weather")
+	if err := agent.SetToolResultFormat(ToolResultsAsTool); err != nil {
+		t.Fatalf("SetToolResultFormat error: %v", err)
+	}
+	agent.AddTool("get_weather", "Get the weather", map[string]jsonschema.Definition{
+		"location": {Type: jsonschema.String},
+	}, []string{"location"}, func(parameters map[string]string) string {
+		return `{"condition":"sunny"}`
+	})
+
+	message := NewMessages()
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("weather in paris?"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	// The duplicate call is not executed, so only the answered call is sent
+	sent := completer.Requests[1].Messages
+	if len(sent) != 4 {
+		t.Fatalf("sent %d messages, want 4: %+v", len(sent), sent)
+	}
+	if calls := sent[2].ToolCalls; sent[2].Role != openai.ChatMessageRoleAssistant || len(calls) != 1 || calls[0].ID != "call_1" {
+		t.Errorf("expected the assistant message with the executed call, got %+v", sent[2])
+	}
+	if sent[3].Role != openai.ChatMessageRoleTool || sent[3].ToolCallID != "call_1" || sent[3].Content != `{"condition":"sunny"}` {
+		t.Errorf("expected a tool message answering call_1, got %+v", sent[3])
+	}
+
+	if err := validateToolMessages(agent.MessagesHistory); err != nil {
+		t.Errorf("history is not valid for the provider: %v", err)
+	}
+
+	if err := agent.SetToolResultFormat(ToolResultFormat{Role: openai.ChatMessageRoleFunction}); err == nil {
+		t.Errorf("expected an error for the function role")
+	}
+}
+
+func TestAgentManualToolExecution(t *testing.T) {
+	completer := newMockCompleter(
+		toolCallResponse(
+			functionCall("call_1", "create_payment_link", `{"amount":"200","email":"a@example.com"}`),
+			functionCall("call_2", "send_email", `{"to":"a@example.com"}`),
+		),
+		textResponse("The payment link is ready."),
+	)
+
+	agent := NewAgent(context.Background(), completer, "test-model", "you create payment links")
+	agent.SetToolExecutionMode(ToolExecutionManual)
+
+	executed := false
+	for _, name := range []string{"create_payment_link", "send_email"} {
+		agent.AddTool(name, name, map[string]jsonschema.Definition{
+			"amount": {Type: jsonschema.String},
+			"email":  {Type: jsonschema.String},
+			"to":     {Type: jsonschema.String},
+		}, nil, func(parameters map[string]string) string {
+			executed = true
+			return "{}"
+		})
+	}
+
+	message := NewMessages()
+	resp, err := agent.Ask(message.MergeMessages(message.UserMessage("create a 200 rupee link for a@example.com")))
+	if err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	if executed {
+		t.Fatal("tools must not run in manual mode")
+	}
+	if len(resp.Choices[0].Message.ToolCalls) != 2 || len(agent.PendingToolCalls()) != 2 {
+		t.Fatalf("expected the tool calls to be returned, got %+v", resp.Choices[0].Message)
+	}
+
+	if _, err := agent.SubmitToolResults(map[string]string{"call_9": "{}"}); err == nil {
+		t.Error("expected an error for an unknown tool call ID")
+	}
+
+	final, err := agent.SubmitToolResults(map[string]string{"call_1": `{"url":"https://pay.example.com/abc"}`})
+	if err != nil {
+		t.Fatalf("SubmitToolResults error: %v", err)
+	}
+
+	if final.Choices[0].Message.Content != "The payment link is ready." {
+		t.Errorf("unexpected final response: %q", final.Choices[0].Message.Content)
+	}
+
+	sent := completer.Requests[1].Messages
+	results := sent[len(sent)-2].Content + "\n" + sent[len(sent)-1].Content
+	if results != "Tool 'create_payment_link' returned: {\"url\":\"https://pay.example.com/abc\"}\nTool 'send_email' returned: not executed: the call was not approved" {
+		t.Errorf("unexpected tool results sent to the model:\n%s", results)
+	}
+
+	if trace := agent.toolResultTrace; len(trace) != 2 || trace[0].Err != nil || !errors.Is(trace[1].Err, ErrToolCallNotApproved) {
+		t.Errorf("expected the unapproved call to carry ErrToolCallNotApproved, got %+v", trace)
+	}
+
+	if len(agent.PendingToolCalls()) != 0 {
+		t.Error("expected no pending tool calls after submitting results")
+	}
+}
+
+func TestAgentTimeBudget(t *testing.T) {
+	completer := newMockCompleter(
+		toolCallResponse(functionCall("call_1", "slow_lookup", `{}`)),
+		textResponse("never sent"),
+	)
+
+	agent := NewAgent(context.Background(), completer, "test-model", "")
+	agent.SetTimeBudget(50 * time.Millisecond)
+	agent.AddTool("slow_lookup", "A slow lookup", map[string]jsonschema.Definition{}, nil, func(parameters map[string]string) string {
+		time.Sleep(100 * time.Millisecond)
+		return "{}"
+	})
+
+	message := NewMessages()
+	resp, err := agent.Ask(message.MergeMessages(message.UserMessage("look it up")))
+	if !errors.Is(err, ErrTimeBudgetExceeded) {
+		t.Fatalf("expected ErrTimeBudgetExceeded, got %v", err)
+	}
+
+	if len(resp.Choices) != 1 || len(resp.Choices[0].Message.ToolCalls) != 1 {
+		t.Errorf("expected the latest partial response, got %+v", resp)
+	}
+
+	if len(completer.Requests) != 1 {
+		t.Errorf("expected no completion after the budget ran out, got %d requests", len(completer.Requests))
+	}
+}
+
+func TestAgentToolLoopDetection(t *testing.T) {
+	repeat := func(n int) []openai.ChatCompletionResponse {
+		responses := make([]openai.ChatCompletionResponse, n)
+		for i := range responses {
+			responses[i] = toolCallResponse(functionCall(fmt.Sprintf("call_%d", i), "status", `{"job":"42"}`))
+		}
+		return responses
+	}
+
+	calls := 0
+	newAgent := func(responses ...openai.ChatCompletionResponse) *Agent {
+		agent := NewAgent(context.Background(), newMockCompleter(responses...), "test-model", "")
+		agent.AddTool("status", "Job status", map[string]jsonschema.Definition{
+			"job": {Type: jsonschema.String},
+		}, []string{"job"}, func(parameters map[string]string) string {
+			calls++
+			return `{"state":"running"}`
+		})
+		return agent
+	}
+
+	message := NewMessages()
+	agent := newAgent(repeat(6)...)
+	resp, err := agent.Ask(message.MergeMessages(message.UserMessage("wait for job 42")))
+	if err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("tool ran %d times, want 3", calls)
+	}
+	if len(resp.Choices[0].Message.ToolCalls) != 0 || !strings.HasPrefix(resp.Choices[0].Message.Content, "Tool loop detected: 'status'") {
+		t.Errorf("response = %+v", resp.Choices[0].Message)
+	}
+
+	calls = 0
+	agent = newAgent(repeat(3)...)
+	agent.SetToolLoopLimit(2)
+	resp, err = agent.Ask(message.MergeMessages(message.UserMessage("wait for job 42")))
+	if err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+	if calls != 2 || resp.Choices[0].Message.Content != "Tool loop detected: 'status' was called 2 times with the same arguments without making progress, so I stopped here." {
+		t.Errorf("calls = %d, response = %q", calls, resp.Choices[0].Message.Content)
+	}
+
+	// Text sent along with the repeated call is kept
+	withText := toolCallResponse(functionCall("call_x", "status", `{"job": "42"}`))
+	withText.Choices[0].Message.Content = "Job 42 is still running."
+	calls = 0
+	agent = newAgent(append(repeat(1), withText, textResponse("done"))...)
+	agent.SetToolLoopLimit(1)
+	resp, err = agent.Ask(message.MergeMessages(message.UserMessage("wait for job 42")))
+	if err != nil || resp.Choices[0].Message.Content != "Job 42 is still running." {
+		t.Errorf("response = %+v, %v", resp.Choices, err)
+	}
+
+	calls = 0
+	agent = newAgent(repeat(6)...)
+	agent.SetToolLoopLimit(0)
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("wait for job 42"))); err == nil || calls != 5 {
+		t.Errorf("with loop detection disabled: calls = %d, err = %v", calls, err)
+	}
+}
+
+func TestAgentToolCostOptions(t *testing.T) {
+	completer := newMockCompleter(textResponse("ok"), textResponse("ok"))
+	agent := NewAgent(context.Background(), completer, "test-model", "")
+
+	noop := func(parameters map[string]string) string { return "{}" }
+	premium := newAgentTool("premium_search", "Search with a paid API", nil, nil, noop)
+	premium.Cost = 10
+	if err := agent.AddTools(premium,
+		newAgentTool("local_search", "Search the local index", nil, nil, noop),
+		newAgentTool("web_search", "Search the web", nil, nil, noop),
+	); err != nil {
+		t.Fatalf("AddTools error: %v", err)
+	}
+	if err := agent.SetToolCost("local_search", 1); err != nil {
+		t.Fatalf("SetToolCost error: %v", err)
+	}
+	if err := agent.SetToolCost("web_search", 5); err != nil {
+		t.Fatalf("SetToolCost error: %v", err)
+	}
+	if err := agent.SetToolCost("missing", 1); err == nil {
+		t.Errorf("expected an error for an unknown tool")
+	}
+
+	describe := func(request openai.ChatCompletionRequest) []string {
+		var tools []string
+		for _, tool := range request.Tools {
+			tools = append(tools, tool.Function.Name+": "+tool.Function.Description)
+		}
+		return tools
+	}
+
+	message := NewMessages()
+	agent.Ask(message.MergeMessages(message.UserMessage("find go tutorials")))
+	if got := describe(completer.Requests[0]); got[0] != "premium_search: Search with a paid API" {
+		t.Errorf("tools without cost options = %v", got)
+	}
+
+	agent.SetToolCostOptions(ToolCostOptions{Order: true, Annotate: true})
+	agent.Ask(message.MergeMessages(message.UserMessage("find go tutorials")))
+	want := []string{
+		"local_search: Search the local index (low cost)",
+		"web_search: Search the web (medium cost)",
+		"premium_search: Search with a paid API (high cost)",
+	}
+	if got := describe(completer.Requests[1]); !reflect.DeepEqual(got, want) {
+		t.Errorf("tools = %v, want %v", got, want)
+	}
+
+	if tool, _ := agent.GetToolByName("web_search"); tool.ToolDefinition.Function.Description != "Search the web" {
+		t.Errorf("cost hint stored in the tool: %q", tool.ToolDefinition.Function.Description)
+	}
+}
+
+func TestAgentCheapestToolCallFilter(t *testing.T) {
+	completer := newMockCompleter(
+		toolCallResponse(
+			functionCall("call_1", "premium_search", `{}`),
+			functionCall("call_2", "web_search", `{}`),
+			functionCall("call_3", "get_time", `{}`),
+			functionCall("call_4", "web_search", `{"page":2}`),
+		),
+		textResponse("done"),
+	)
+	agent := NewAgent(context.Background(), completer, "test-model", "")
+
+	var called []string
+	tool := func(name string, cost float64) AgentTool {
+		agentTool := newAgentTool(name, name, nil, nil, func(map[string]string) string {
+			called = append(called, name)
+			return "{}"
+		})
+		agentTool.Cost = cost
+		return agentTool
+	}
+	if err := agent.AddTools(tool("premium_search", 10), tool("web_search", 2), tool("local_search", 1), tool("get_time", 5)); err != nil {
+		t.Fatalf("AddTools error: %v", err)
+	}
+
+	agent.SetToolCallFilter(agent.CheapestToolCallFilter([]string{"local_search", "web_search", "premium_search"}))
+
+	message := NewMessages()
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("search and tell the time"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	// local_search was not called, so web_search is the cheapest alternative
+	want := []string{"web_search", "get_time", "web_search"}
+	if !reflect.DeepEqual(called, want) {
+		t.Errorf("called = %v, want %v", called, want)
+	}
+}
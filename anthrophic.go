@@ -1,10 +1,18 @@
 package sapiens
 
-import openai "github.com/sashabaranov/go-openai"
+import (
+	"context"
+	"fmt"
 
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Anthropic is reached through its OpenAI SDK compatibility endpoint, which
+// translates function tools and tool calls to Anthropic's native tool_use and
+// tool_result content blocks.
 const (
-	AnthropicBaseUrl      = "https://generativelanguage.googleapis.com/v1beta/openai/"
-	AnthropicDefaultModel = "claude-sonet-3.5"
+	AnthropicBaseUrl      = "https://api.anthropic.com/v1/"
+	AnthropicDefaultModel = "claude-sonnet-4-20250514"
 )
 
 type AnthropicInterface struct {
@@ -12,6 +20,7 @@ type AnthropicInterface struct {
 	DefaultModel string
 	OrgId        string
 	AuthToken    string
+	ExtraBody    map[string]interface{} // provider specific fields added to every chat completion request
 }
 
 func NewAnthropic(authToken string) *AnthropicInterface {
@@ -31,6 +40,8 @@ func (g *AnthropicInterface) Client() *openai.Client {
 
 	client_config.BaseURL = g.BaseUrl
 
+	client_config.HTTPClient = newExtraBodyClient(g.ExtraBody)
+
 	client := openai.NewClientWithConfig(client_config)
 
 	return client
@@ -40,3 +51,50 @@ func (g *AnthropicInterface) Client() *openai.Client {
 func (g *AnthropicInterface) GetDefaultModel() string {
 	return g.DefaultModel
 }
+
+// SetThinkingBudget enables extended thinking with a budget of tokens, which
+// must be at least 1024 and below the request's max tokens. Zero disables
+// extended thinking. Call it before Client.
+func (g *AnthropicInterface) SetThinkingBudget(tokens int) {
+	if tokens <= 0 {
+		setExtraBody(&g.ExtraBody, "thinking", nil)
+		return
+	}
+
+	setExtraBody(&g.ExtraBody, "thinking", map[string]interface{}{
+		"type":          "enabled",
+		"budget_tokens": tokens,
+	})
+}
+
+// GetDefaultEmbeddingModel returns an empty string: Anthropic does not offer
+// embeddings, so another provider has to be used for them. Like the other
+// providers' values it is informational; the package has no embeddings API.
+func (g *AnthropicInterface) GetDefaultEmbeddingModel() string {
+	return ""
+}
+
+// ToolResultFormat returns how the compatibility endpoint expects tool
+// results: tool messages answering the calls by ID, which it turns into
+// tool_result blocks.
+func (g *AnthropicInterface) ToolResultFormat() ToolResultFormat {
+	return ToolResultsAsTool
+}
+
+// Initialize validates the API key and base URL with a one-token completion
+// of the default model, so bad credentials fail fast instead of on the first
+// Ask.
+func (g *AnthropicInterface) Initialize(ctx context.Context) error {
+	_, err := g.Client().CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model:     g.DefaultModel,
+		MaxTokens: 1,
+		Messages: []openai.ChatCompletionMessage{
+			NewMessages().UserMessage("ping"),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to initialize Anthropic provider: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+package sapiens
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+func TestAnthropicToolCall(t *testing.T) {
+	var requests []openai.ChatCompletionRequest
+	responses := []openai.ChatCompletionResponse{
+		toolCallResponse(functionCall("toolu_01", "get_weather", `{"location":"Paris, France"}`)),
+		textResponse("It is sunny in Paris."),
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer anthropic-key" {
+			t.Errorf("unexpected authorization header: %q", r.Header.Get("Authorization"))
+		}
+
+		var request openai.ChatCompletionRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		requests = append(requests, request)
+
+		json.NewEncoder(w).Encode(responses[0])
+		responses = responses[1:]
+	}))
+	defer server.Close()
+
+	llm := NewAnthropic("anthropic-key")
+	if llm.BaseUrl != "https://api.anthropic.com/v1/" {
+		t.Errorf("unexpected default base url: %s", llm.BaseUrl)
+	}
+	llm.BaseUrl = server.URL + "/v1/"
+
+	agent := NewAgent(context.Background(), llm.Client(), llm.GetDefaultModel(), "you are a weather reporter")
+
+	var location string
+	agent.AddTool("get_weather", "Get the weather", map[string]jsonschema.Definition{
+		"location": {Type: jsonschema.String},
+	}, []string{"location"}, func(parameters map[string]string) string {
+		location = parameters["location"]
+		return `{"condition":"sunny"}`
+	})
+
+	message := NewMessages()
+	resp, err := agent.Ask(message.MergeMessages(message.UserMessage("weather in paris?")))
+	if err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	if location != "Paris, France" {
+		t.Errorf("expected the tool call to be executed, got location %q", location)
+	}
+
+	if len(requests) != 2 || len(requests[0].Tools) != 1 || requests[0].Tools[0].Function.Name != "get_weather" {
+		t.Fatalf("expected the tool definition to be sent, got %+v", requests)
+	}
+
+	if requests[0].Model != AnthropicDefaultModel {
+		t.Errorf("unexpected model: %s", requests[0].Model)
+	}
+
+	if resp.Choices[0].Message.Content != "It is sunny in Paris." {
+		t.Errorf("unexpected final answer: %q", resp.Choices[0].Message.Content)
+	}
+}
@@ -0,0 +1,207 @@
+package sapiens
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Phases of an AuditEntry.
+const (
+	AuditBefore = "before" // the tool is about to run
+	AuditAfter  = "after"  // the tool has run
+)
+
+// AuditEntry records one side of a tool execution. Sinks chain the entries
+// by setting Sequence, PrevHash and Hash, see FileAuditSink.
+type AuditEntry struct {
+	Sequence   int64         `json:"sequence"`
+	Time       time.Time     `json:"time"`
+	Phase      string        `json:"phase"`
+	ToolCallID string        `json:"tool_call_id"`
+	Tool       string        `json:"tool"`
+	Arguments  string        `json:"arguments"`
+	Result     string        `json:"result,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	Duration   time.Duration `json:"duration,omitempty"`
+	PrevHash   string        `json:"prev_hash"`
+	Hash       string        `json:"hash"`
+}
+
+// AuditSink receives an entry synchronously before and after every regular
+// or MCP tool execution. When Record fails before a tool runs, the tool is
+// not run; either way the turn ends with the error. Implementations must be
+// safe for concurrent use.
+type AuditSink interface {
+	Record(entry AuditEntry) error
+}
+
+// SetAuditSink records every tool execution to sink. Arguments and results
+// pass through the redactor, if set. Pass nil to stop auditing.
+func (a *Agent) SetAuditSink(sink AuditSink) {
+	a.mu.Lock()
+	a.auditSink = sink
+	a.mu.Unlock()
+}
+
+func (a *Agent) audit(phase string, toolCall openai.ToolCall, result string, err error, duration time.Duration) error {
+	a.mu.Lock()
+	sink := a.auditSink
+	a.mu.Unlock()
+
+	if sink == nil {
+		return nil
+	}
+
+	entry := AuditEntry{
+		Time:       time.Now().UTC(),
+		Phase:      phase,
+		ToolCallID: toolCall.ID,
+		Tool:       toolCall.Function.Name,
+		Arguments:  a.redact(toolCall.Function.Arguments),
+		Duration:   duration,
+	}
+	if result != "" {
+		entry.Result = a.redact(result)
+	}
+	if err != nil {
+		entry.Error = a.redact(err.Error())
+	}
+
+	if recordErr := sink.Record(entry); recordErr != nil {
+		return fmt.Errorf("failed to audit tool '%s': %w", toolCall.Function.Name, recordErr)
+	}
+
+	return nil
+}
+
+// auditHash returns the hash of an entry, covering every field but Hash,
+// including PrevHash, which links it to the entry before.
+func auditHash(entry AuditEntry) (string, error) {
+	entry.Hash = ""
+	encoded, err := json.Marshal(entry)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// FileAuditSink appends audit entries to a JSONL file, one per line, each
+// carrying the hash of the previous entry so that editing, removing or
+// reordering lines is detected by VerifyAuditLog.
+type FileAuditSink struct {
+	mu       sync.Mutex
+	file     *os.File
+	sequence int64
+	lastHash string
+}
+
+// NewFileAuditSink opens, or creates, the audit log at path. An existing log
+// is verified and continued.
+func NewFileAuditSink(path string) (*FileAuditSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+
+	last, err := verifyAuditEntries(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("audit log '%s': %w", path, err)
+	}
+
+	return &FileAuditSink{file: file, sequence: last.Sequence, lastHash: last.Hash}, nil
+}
+
+// Record chains the entry to the previous one and appends it to the log.
+func (s *FileAuditSink) Record(entry AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry.Sequence = s.sequence + 1
+	entry.PrevHash = s.lastHash
+
+	hash, err := auditHash(entry)
+	if err != nil {
+		return err
+	}
+	entry.Hash = hash
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	s.sequence, s.lastHash = entry.Sequence, entry.Hash
+
+	return nil
+}
+
+// Close closes the log file.
+func (s *FileAuditSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.file.Close()
+}
+
+// VerifyAuditLog checks the hash chain of an audit log written by
+// FileAuditSink and reports the first entry that was tampered with.
+func VerifyAuditLog(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = verifyAuditEntries(file)
+	return err
+}
+
+var errAuditTampered = errors.New("audit log has been tampered with")
+
+// verifyAuditEntries checks the chain of the entries read from r and returns
+// the last one.
+func verifyAuditEntries(r io.Reader) (AuditEntry, error) {
+	var last AuditEntry
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return last, fmt.Errorf("line %d: %w: %v", line, errAuditTampered, err)
+		}
+
+		hash, err := auditHash(entry)
+		if err != nil {
+			return last, err
+		}
+		if entry.Sequence != last.Sequence+1 || entry.PrevHash != last.Hash || entry.Hash != hash {
+			return last, fmt.Errorf("line %d: %w", line, errAuditTampered)
+		}
+
+		last = entry
+	}
+
+	return last, scanner.Err()
+}
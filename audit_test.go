@@ -0,0 +1,105 @@
+package sapiens
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+type failingAuditSink struct{}
+
+func (failingAuditSink) Record(AuditEntry) error { return errors.New("disk full") }
+
+func TestAgentAuditSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	sink, err := NewFileAuditSink(path)
+	if err != nil {
+		t.Fatalf("NewFileAuditSink error: %v", err)
+	}
+
+	orders := 0
+	completer := newMockCompleter(
+		toolCallResponse(functionCall("call_1", "create_order", `{"amount":"200"}`)),
+		textResponse("Order created."),
+	)
+	agent := NewAgent(context.Background(), completer, "test-model", "")
+	agent.AddTool("create_order", "Create an order", map[string]jsonschema.Definition{
+		"amount": {Type: jsonschema.String},
+	}, []string{"amount"}, func(parameters map[string]string) string {
+		orders++
+		return `{"order_id":"A1"}`
+	})
+	agent.SetAuditSink(sink)
+
+	message := NewMessages()
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("order for 200"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+	sink.Close()
+
+	data, _ := os.ReadFile(path)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("audit log has %d entries, want 2:\n%s", len(lines), data)
+	}
+
+	var before, after AuditEntry
+	json.Unmarshal([]byte(lines[0]), &before)
+	json.Unmarshal([]byte(lines[1]), &after)
+	if before.Phase != AuditBefore || before.Tool != "create_order" || before.Arguments != `{"amount":"200"}` || before.Result != "" {
+		t.Errorf("before entry = %+v", before)
+	}
+	if after.Phase != AuditAfter || after.Result != `{"order_id":"A1"}` || after.ToolCallID != "call_1" || after.PrevHash != before.Hash || after.Sequence != 2 {
+		t.Errorf("after entry = %+v", after)
+	}
+
+	if err := VerifyAuditLog(path); err != nil {
+		t.Errorf("VerifyAuditLog error: %v", err)
+	}
+
+	// A reopened log continues the chain
+	sink, err = NewFileAuditSink(path)
+	if err != nil {
+		t.Fatalf("reopening the audit log: %v", err)
+	}
+	sink.Record(AuditEntry{Phase: AuditBefore, Tool: "noop"})
+	sink.Close()
+	if err := VerifyAuditLog(path); err != nil {
+		t.Errorf("VerifyAuditLog after reopening: %v", err)
+	}
+
+	tampered := strings.Replace(string(data), `A1`, `B2`, 1)
+	os.WriteFile(path, []byte(tampered), 0o600)
+	if err := VerifyAuditLog(path); !errors.Is(err, errAuditTampered) {
+		t.Errorf("expected tampering to be detected, got %v", err)
+	}
+	if _, err := NewFileAuditSink(path); err == nil {
+		t.Errorf("expected NewFileAuditSink to refuse a tampered log")
+	}
+
+	os.WriteFile(path, []byte(lines[1]+"\n"), 0o600)
+	if err := VerifyAuditLog(path); !errors.Is(err, errAuditTampered) {
+		t.Errorf("expected a removed entry to be detected, got %v", err)
+	}
+
+	// A tool is not run when its execution cannot be audited
+	orders = 0
+	agent = NewAgent(context.Background(), newMockCompleter(toolCallResponse(functionCall("call_2", "create_order", `{"amount":"300"}`))), "test-model", "")
+	agent.AddTool("create_order", "Create an order", nil, nil, func(parameters map[string]string) string {
+		orders++
+		return "{}"
+	})
+	agent.SetAuditSink(failingAuditSink{})
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("order for 300"))); err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("expected the audit error, got %v", err)
+	}
+	if orders != 0 {
+		t.Errorf("tool ran although it could not be audited")
+	}
+}
@@ -0,0 +1,221 @@
+package sapiens
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// BatchResult is the outcome of one RunBatch input. Error is empty when the
+// input succeeded.
+type BatchResult struct {
+	Index   int          `json:"index"`
+	Input   string       `json:"input"`
+	Content string       `json:"content"`
+	Usage   openai.Usage `json:"usage"`
+	Error   string       `json:"error,omitempty"`
+}
+
+// batchRateLimitRetries bounds how often an input is retried after the
+// provider answered 429 Too Many Requests; the wait doubles on every retry
+// unless the provider sent a Retry-After.
+var (
+	batchRateLimitRetries = 5
+	batchRetryDelay       = time.Second
+)
+
+// SetBatchCheckpoint makes RunBatch record every result as a JSON line in the
+// file at path. A later RunBatch over the same inputs skips those that already
+// succeeded, so an interrupted batch can be resumed. An empty path disables
+// checkpointing.
+func (a *Agent) SetBatchCheckpoint(path string) {
+	a.mu.Lock()
+	a.batchCheckpoint = path
+	a.mu.Unlock()
+}
+
+// RunBatch asks every input as a single user message, using up to concurrency
+// workers. Each input gets its own history; the agent's own history is left
+// untouched. Inputs the provider rate limits are retried with a growing delay.
+// Per-input failures are reported in the results; the returned error is only
+// set when the checkpoint cannot be read or written or ctx is done.
+func (a *Agent) RunBatch(ctx context.Context, inputs []string, concurrency int) ([]BatchResult, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	a.mu.Lock()
+	checkpointPath := a.batchCheckpoint
+	a.mu.Unlock()
+
+	results := make([]BatchResult, len(inputs))
+	done := make([]bool, len(inputs))
+
+	var checkpoint *os.File
+	if checkpointPath != "" {
+		restored, err := readBatchCheckpoint(checkpointPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, result := range restored {
+			if result.Error == "" && result.Index >= 0 && result.Index < len(inputs) && result.Input == inputs[result.Index] {
+				results[result.Index] = result
+				done[result.Index] = true
+			}
+		}
+
+		checkpoint, err = os.OpenFile(checkpointPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		defer checkpoint.Close()
+	}
+
+	var (
+		wg       sync.WaitGroup
+		writeMu  sync.Mutex
+		writeErr error
+	)
+
+	jobs := make(chan int)
+	for w := 0; w < concurrency; w++ {
+		worker := a.batchWorker()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for index := range jobs {
+				result := worker.runBatchInput(ctx, index, inputs[index])
+				results[index] = result
+
+				if checkpoint == nil {
+					continue
+				}
+
+				line, err := json.Marshal(result)
+				writeMu.Lock()
+				if err == nil {
+					_, err = checkpoint.Write(append(line, '\n'))
+				}
+				if err != nil && writeErr == nil {
+					writeErr = err
+				}
+				writeMu.Unlock()
+			}
+		}()
+	}
+
+	for index := range inputs {
+		if !done[index] {
+			jobs <- index
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if writeErr != nil {
+		return results, writeErr
+	}
+
+	return results, ctx.Err()
+}
+
+// batchWorker returns a stateless agent sharing this agent's configuration,
+// so that batch inputs neither see each other nor wait on each other's turns.
+func (a *Agent) batchWorker() *Agent {
+	worker := a.cloneConfig()
+	worker.stateless = true
+
+	return worker
+}
+
+func (a *Agent) runBatchInput(ctx context.Context, index int, input string) BatchResult {
+	result := BatchResult{Index: index, Input: input}
+	messages := []openai.ChatCompletionMessage{NewMessages().UserMessage(input)}
+
+	delay := batchRetryDelay
+	for attempt := 0; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			result.Error = err.Error()
+			return result
+		}
+
+		a.askMu.Lock()
+		resp, err := a.ask(askOptions{ctx: ctx}, messages)
+		a.askMu.Unlock()
+
+		if err == nil {
+			result.Usage = resp.Usage
+			if len(resp.Choices) > 0 {
+				result.Content = resp.Choices[0].Message.Content
+			}
+			return result
+		}
+
+		if attempt >= batchRateLimitRetries || !isRateLimited(err) {
+			result.Error = err.Error()
+			return result
+		}
+
+		wait := delay
+		if retryAfter, ok := retryAfterOf(err); ok {
+			wait = retryAfter
+		}
+
+		select {
+		case <-ctx.Done():
+			result.Error = ctx.Err().Error()
+			return result
+		case <-time.After(wait):
+		}
+		delay *= 2
+	}
+}
+
+// isRateLimited reports whether err is the provider answering 429.
+func isRateLimited(err error) bool {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.HTTPStatusCode == http.StatusTooManyRequests
+	}
+
+	var requestErr *openai.RequestError
+	if errors.As(err, &requestErr) {
+		return requestErr.HTTPStatusCode == http.StatusTooManyRequests
+	}
+
+	return false
+}
+
+func readBatchCheckpoint(path string) ([]BatchResult, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var results []BatchResult
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var result BatchResult
+		// A line cut short by an interrupted run is simply redone
+		if json.Unmarshal(scanner.Bytes(), &result) != nil {
+			continue
+		}
+		results = append(results, result)
+	}
+
+	return results, scanner.Err()
+}
@@ -0,0 +1,189 @@
+package sapiens
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+// echoCompleter answers every request with its last user message. Inputs in
+// fail always error; inputs in limited are rate limited once.
+type echoCompleter struct {
+	mu       sync.Mutex
+	fail     map[string]bool
+	limited  map[string]bool
+	requests []openai.ChatCompletionRequest
+}
+
+func (e *echoCompleter) CreateChatCompletion(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.requests = append(e.requests, request)
+	input := request.Messages[len(request.Messages)-1].Content
+
+	if e.fail[input] {
+		return openai.ChatCompletionResponse{}, errors.New("model unavailable")
+	}
+	if e.limited[input] {
+		delete(e.limited, input)
+		return openai.ChatCompletionResponse{}, &openai.APIError{HTTPStatusCode: http.StatusTooManyRequests, Message: "slow down"}
+	}
+
+	response := textResponse("echo: " + input)
+	response.Usage = openai.Usage{PromptTokens: len(request.Messages), CompletionTokens: 1, TotalTokens: len(request.Messages) + 1}
+
+	return response, nil
+}
+
+func (e *echoCompleter) CreateChatCompletionStream(ctx context.Context, request openai.ChatCompletionRequest) (*openai.ChatCompletionStream, error) {
+	return nil, errors.New("streaming is not supported by echoCompleter")
+}
+
+func TestAgentRunBatch(t *testing.T) {
+	defer func(delay time.Duration) { batchRetryDelay = delay }(batchRetryDelay)
+	batchRetryDelay = time.Millisecond
+
+	completer := &echoCompleter{
+		fail:    map[string]bool{"c": true},
+		limited: map[string]bool{"b": true},
+	}
+	agent := NewAgent(context.Background(), completer, "test-model", "you echo")
+	agent.SetBatchCheckpoint(filepath.Join(t.TempDir(), "batch.jsonl"))
+
+	inputs := []string{"a", "b", "c", "d", "e"}
+	results, err := agent.RunBatch(context.Background(), inputs, 3)
+	if err != nil {
+		t.Fatalf("RunBatch error: %v", err)
+	}
+
+	for i, result := range results {
+		if result.Index != i || result.Input != inputs[i] {
+			t.Errorf("result %d = %+v, want index %d input %q", i, result, i, inputs[i])
+		}
+		if inputs[i] == "c" {
+			if result.Error == "" {
+				t.Errorf("result for 'c' has no error")
+			}
+			continue
+		}
+		if result.Error != "" || result.Content != "echo: "+inputs[i] {
+			t.Errorf("result %d = %+v, want content %q", i, result, "echo: "+inputs[i])
+		}
+		// Each input is sent on its own, with only the system prompt before it
+		if result.Usage.PromptTokens != 2 {
+			t.Errorf("result %d prompt tokens = %d, want 2", i, result.Usage.PromptTokens)
+		}
+	}
+
+	if len(agent.MessagesHistory) != 0 {
+		t.Errorf("agent history has %d messages, want 0", len(agent.MessagesHistory))
+	}
+
+	// Resuming only redoes the input that failed
+	completer.mu.Lock()
+	completer.fail = nil
+	completer.requests = nil
+	completer.mu.Unlock()
+
+	results, err = agent.RunBatch(context.Background(), inputs, 3)
+	if err != nil {
+		t.Fatalf("resumed RunBatch error: %v", err)
+	}
+	if len(completer.requests) != 1 {
+		t.Errorf("resumed batch sent %d requests, want 1", len(completer.requests))
+	}
+	for i, result := range results {
+		if result.Error != "" || result.Content != "echo: "+inputs[i] {
+			t.Errorf("resumed result %d = %+v", i, result)
+		}
+	}
+}
+
+// conversationState lists the Agent fields holding conversation state rather
+// than configuration, which cloneConfig leaves out.
+var conversationState = map[string]bool{
+	"MessagesHistory": true, "Request": true, "mu": true, "askMu": true,
+	"currentDepth": true, "totalToolCalls": true, "toolCallCounts": true,
+	"toolCallTrace": true, "toolResultTrace": true, "stateless": true,
+	"turnMessages": true, "onContent": true, "turnStart": true,
+	"pendingToolCalls": true, "lastResponse": true, "batchCheckpoint": true,
+	"lastResponseMeta": true, "reasoning": true, "shutdown": true,
+	"stopTurn": true, "rewrittenPrompt": true, "messageOptions": true,
+	"grounding": true, "citations": true,
+}
+
+func TestAgentCloneConfig(t *testing.T) {
+	agent := NewAgent(context.Background(), newMockCompleter(), "test-model", "be brief")
+	agent.SetResponseSchema("answers", "", true, []struct{}{})
+	agent.SetResponseSchema("invalid", "", true, jsonschema.Definition{Type: jsonschema.Array})
+	agent.AddTool("echo", "Echo", nil, nil, func(map[string]string) string { return "" })
+	agent.McpClient = &McpClient{}
+	agent.McpTools = []mcp.Tool{{Name: "remote"}}
+	agent.SetMaxToolCallDepth(2)
+	agent.SetMaxToolsPerRound(3)
+	agent.SetMaxTotalToolCalls(4)
+	agent.SetToolLoopLimit(5)
+	agent.SetParallelToolCalls(false)
+	agent.SetCandidateCount(2)
+	agent.SetMetricsCollector(NewInMemoryMetrics())
+	agent.SetStructuredRetries(1)
+	agent.SetContext("context")
+	agent.SetRedactor(strings.ToUpper)
+	agent.SetSystemPromptPosition(SystemPromptBottom)
+	agent.SetMaxToolResultSize(100)
+	agent.SetToolExecutionMode(ToolExecutionManual)
+	agent.SetTimeBudget(time.Minute)
+	agent.SetToolCallFilter(func(calls []openai.ToolCall) []openai.ToolCall { return calls })
+	agent.SetFewShotExamples(FewShotExample{User: "hi", Assistant: "hello"})
+	agent.SetToolResultFormat(ToolResultsAsTool)
+	agent.AttachDocument("doc", "text")
+	agent.SetDocumentSummarizer(1000, func(context.Context, string, string) (string, error) { return "", nil })
+	agent.SetMaxResponseLength(500)
+	agent.SetFileUploader(NewOpenai("key"))
+	agent.uploadedFiles = map[string]string{"a.pdf": "file-1"}
+	agent.SetFallbackModels([]string{"backup"})
+	agent.SetAuditSink(failingAuditSink{})
+	agent.SetToolCostOptions(ToolCostOptions{Order: true})
+	agent.SetSeed(7)
+	agent.SetTemperature(0.5)
+	agent.SetMaxTokens(64)
+	agent.SetToolChoice("auto")
+	agent.SetRetryPolicy(RetryPolicy{MaxRetries: 1})
+	agent.SetCompletionTimeout(time.Second)
+	agent.SetPromptRewriter(func(ctx context.Context, original string) (string, error) { return original, nil })
+	agent.SystemPromptBuilder()
+	agent.SetToolCallDiagnostics(LogToolCallDiagnosis, false)
+
+	worker := agent.batchWorker()
+	if !worker.stateless {
+		t.Errorf("batch worker is not stateless")
+	}
+
+	original, clone := reflect.ValueOf(agent).Elem(), reflect.ValueOf(worker).Elem()
+	for i := 0; i < original.NumField(); i++ {
+		name := original.Type().Field(i).Name
+		if conversationState[name] {
+			continue
+		}
+
+		if original.Field(i).IsZero() {
+			t.Errorf("field %s is not configured by the test; set it or list it as conversation state", name)
+			continue
+		}
+		if got, want := fmt.Sprint(clone.Field(i)), fmt.Sprint(original.Field(i)); got != want {
+			t.Errorf("field %s = %s in the worker, want %s", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package sapiens
+
+import "encoding/json"
+
+// Citation attributes a part of an answer to a source. Text, StartIndex and
+// EndIndex locate the cited part in the content, as reported by the
+// provider; they are zero when the source backs the answer as a whole.
+type Citation struct {
+	URI        string
+	Title      string
+	Text       string
+	StartIndex int
+	EndIndex   int
+}
+
+// LastCitations returns the sources cited by the answer of the last turn,
+// from Gemini's grounding metadata or OpenAI's URL citation annotations.
+// Only completions that are not streamed carry them.
+func (a *Agent) LastCitations() []Citation {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return append([]Citation(nil), a.citations...)
+}
+
+// groundingCitations returns a citation for every source of every supported
+// part of the answer, followed by the sources no part refers to.
+func groundingCitations(metadata *GroundingMetadata) []Citation {
+	if metadata == nil {
+		return nil
+	}
+
+	var citations []Citation
+	cited := make([]bool, len(metadata.Sources))
+	for _, support := range metadata.Supports {
+		for _, index := range support.Sources {
+			if index < 0 || index >= len(metadata.Sources) {
+				continue
+			}
+			cited[index] = true
+
+			source := metadata.Sources[index]
+			citations = append(citations, Citation{
+				URI:        source.URI,
+				Title:      source.Title,
+				Text:       support.Text,
+				StartIndex: support.StartIndex,
+				EndIndex:   support.EndIndex,
+			})
+		}
+	}
+
+	for i, source := range metadata.Sources {
+		if !cited[i] {
+			citations = append(citations, Citation{URI: source.URI, Title: source.Title})
+		}
+	}
+
+	return citations
+}
+
+// parseURLCitations extracts the url_citation annotations of the first
+// choice of a chat completion body. Their indices count characters.
+func parseURLCitations(body []byte) []Citation {
+	var completion struct {
+		Choices []struct {
+			Message struct {
+				Content     string `json:"content"`
+				Annotations []struct {
+					Type        string `json:"type"`
+					URLCitation struct {
+						URL        string `json:"url"`
+						Title      string `json:"title"`
+						StartIndex int    `json:"start_index"`
+						EndIndex   int    `json:"end_index"`
+					} `json:"url_citation"`
+				} `json:"annotations"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if json.Unmarshal(body, &completion) != nil || len(completion.Choices) == 0 {
+		return nil
+	}
+
+	message := completion.Choices[0].Message
+	content := []rune(message.Content)
+
+	var citations []Citation
+	for _, annotation := range message.Annotations {
+		if annotation.Type != "url_citation" {
+			continue
+		}
+
+		citation := Citation{
+			URI:        annotation.URLCitation.URL,
+			Title:      annotation.URLCitation.Title,
+			StartIndex: annotation.URLCitation.StartIndex,
+			EndIndex:   annotation.URLCitation.EndIndex,
+		}
+		if 0 <= citation.StartIndex && citation.StartIndex < citation.EndIndex && citation.EndIndex <= len(content) {
+			citation.Text = string(content[citation.StartIndex:citation.EndIndex])
+		}
+		citations = append(citations, citation)
+	}
+
+	return citations
+}
@@ -0,0 +1,88 @@
+package sapiens
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func newCompletionServer(t *testing.T, completion string) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, completion)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestResponseCitationsFromGrounding(t *testing.T) {
+	llm := NewGemini("key")
+	llm.BaseUrl = newCompletionServer(t, groundedCompletion).URL
+	llm.SetGoogleSearch(true)
+
+	agent := NewAgent(context.Background(), llm.Client(), llm.GetDefaultModel(), "")
+	message := NewMessages()
+	response, err := agent.AskStructured(message.MergeMessages(message.UserMessage("who won euro 2024?")))
+	if err != nil {
+		t.Fatalf("AskStructured error: %v", err)
+	}
+
+	want := []Citation{{URI: "https://uefa.com/euro", Title: "uefa.com", Text: "Spain won Euro 2024.", StartIndex: 0, EndIndex: 20}}
+	if !reflect.DeepEqual(response.Citations, want) {
+		t.Errorf("Citations = %+v, want %+v", response.Citations, want)
+	}
+	if got := agent.LastCitations(); !reflect.DeepEqual(got, want) {
+		t.Errorf("LastCitations = %+v, want %+v", got, want)
+	}
+}
+
+func TestResponseCitationsFromAnnotations(t *testing.T) {
+	llm := NewOpenai("key")
+	llm.BaseUrl = newCompletionServer(t, `{
+		"id": "1",
+		"choices": [{
+			"index": 0,
+			"finish_reason": "stop",
+			"message": {
+				"role": "assistant",
+				"content": "Café prices rose, per the report.",
+				"annotations": [
+					{"type": "url_citation", "url_citation": {"url": "https://example.com/report", "title": "Report", "start_index": 0, "end_index": 16}},
+					{"type": "file_citation"}
+				]
+			}
+		}]
+	}`).URL
+
+	agent := NewAgent(context.Background(), llm.Client(), llm.GetDefaultModel(), "")
+	message := NewMessages()
+	response, err := agent.AskStructured(message.MergeMessages(message.UserMessage("prices?")))
+	if err != nil {
+		t.Fatalf("AskStructured error: %v", err)
+	}
+
+	want := []Citation{{URI: "https://example.com/report", Title: "Report", Text: "Café prices rose", StartIndex: 0, EndIndex: 16}}
+	if !reflect.DeepEqual(response.Citations, want) {
+		t.Errorf("Citations = %+v, want %+v", response.Citations, want)
+	}
+}
+
+func TestGroundingCitationsListsUncitedSources(t *testing.T) {
+	citations := groundingCitations(&GroundingMetadata{
+		Sources: []GroundingSource{{URI: "https://a"}, {URI: "https://b"}},
+		Supports: []GroundingSupport{
+			{Text: "x", EndIndex: 1, Sources: []int{1, 5}},
+		},
+	})
+
+	want := []Citation{{URI: "https://b", Text: "x", EndIndex: 1}, {URI: "https://a"}}
+	if !reflect.DeepEqual(citations, want) {
+		t.Errorf("citations = %+v, want %+v", citations, want)
+	}
+}
@@ -0,0 +1,43 @@
+package sapiens
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// Codec encodes and decodes JSON. encoding/json is used by default; faster
+// drop-in implementations such as json-iterator or goccy/go-json satisfy it.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+type stdCodec struct{}
+
+func (stdCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (stdCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+var (
+	codecMu   sync.RWMutex
+	jsonCodec Codec = stdCodec{}
+)
+
+// SetJSONCodec replaces the codec used to decode structured responses, by
+// ParseResponse, AskJSON, AskStructured and AskStructuredStream. A nil codec
+// restores encoding/json.
+func SetJSONCodec(codec Codec) {
+	if codec == nil {
+		codec = stdCodec{}
+	}
+
+	codecMu.Lock()
+	jsonCodec = codec
+	codecMu.Unlock()
+}
+
+func currentCodec() Codec {
+	codecMu.RLock()
+	defer codecMu.RUnlock()
+
+	return jsonCodec
+}
@@ -0,0 +1,49 @@
+package sapiens
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+type countingCodec struct {
+	unmarshals int
+}
+
+func (c *countingCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+func (c *countingCodec) Unmarshal(data []byte, v interface{}) error {
+	c.unmarshals++
+	return json.Unmarshal(data, v)
+}
+
+func TestSetJSONCodec(t *testing.T) {
+	codec := &countingCodec{}
+	SetJSONCodec(codec)
+	defer SetJSONCodec(nil)
+
+	type Weather struct {
+		Location string `json:"location"`
+	}
+
+	completer := newMockCompleter(textResponse(`{"location":"Delhi"}`), textResponse(`{"location":"Pune"}`))
+	agent := NewAgent(context.Background(), completer, "test-model", "")
+
+	message := NewMessages()
+	weather, err := AskJSON[Weather](agent, message.MergeMessages(message.UserMessage("weather in delhi")))
+	if err != nil || weather.Location != "Delhi" {
+		t.Fatalf("AskJSON = %+v, %v", weather, err)
+	}
+
+	resp, err := agent.Ask(message.MergeMessages(message.UserMessage("weather in pune")))
+	if err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+	if err := agent.ParseResponse(resp, &weather); err != nil || weather.Location != "Pune" {
+		t.Fatalf("ParseResponse = %+v, %v", weather, err)
+	}
+
+	if codec.unmarshals != 2 {
+		t.Errorf("custom codec decoded %d responses, want 2", codec.unmarshals)
+	}
+}
@@ -0,0 +1,71 @@
+package sapiens
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+const codeToolDefaultTimeout = 30 * time.Second
+
+type CodeResult struct {
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+	ExitCode int    `json:"exit_code"`
+}
+
+// CodeExecutor runs model-written code. The package deliberately ships no
+// implementation: running untrusted code safely (containers, firejail, a
+// remote sandbox) is the responsibility of the caller.
+type CodeExecutor interface {
+	Execute(ctx context.Context, language string, code string) (*CodeResult, error)
+}
+
+// NewCodeTool returns an "execute_code" tool backed by executor, with a
+// default timeout of 30 seconds per execution.
+func NewCodeTool(executor CodeExecutor) AgentTool {
+	return NewCodeToolWithTimeout(executor, codeToolDefaultTimeout)
+}
+
+func NewCodeToolWithTimeout(executor CodeExecutor, timeout time.Duration) AgentTool {
+	return newAgentTool("execute_code",
+		"Execute code in a sandbox and return its stdout, stderr and exit code",
+		map[string]jsonschema.Definition{
+			"language": {
+				Type:        jsonschema.String,
+				Description: "The programming language of the code, e.g. python",
+			},
+			"code": {
+				Type:        jsonschema.String,
+				Description: "The source code to execute",
+			},
+		},
+		[]string{"language", "code"},
+		func(parameters map[string]string) string {
+			if parameters["code"] == "" {
+				return toolError(fmt.Errorf("code is required"))
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			result, err := executor.Execute(ctx, parameters["language"], parameters["code"])
+			if err != nil {
+				if ctx.Err() == context.DeadlineExceeded {
+					return toolError(fmt.Errorf("execution timed out after %s", timeout))
+				}
+				return toolError(err)
+			}
+
+			encoded, err := json.Marshal(result)
+			if err != nil {
+				return toolError(err)
+			}
+
+			return string(encoded)
+		},
+	)
+}
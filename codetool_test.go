@@ -0,0 +1,51 @@
+package sapiens
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeExecutor struct {
+	language string
+	code     string
+}
+
+func (f *fakeExecutor) Execute(ctx context.Context, language string, code string) (*CodeResult, error) {
+	f.language = language
+	f.code = code
+
+	if code == "sleep" {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	return &CodeResult{Stdout: "4\n", ExitCode: 0}, nil
+}
+
+func TestCodeTool(t *testing.T) {
+	executor := &fakeExecutor{}
+	tool := NewCodeTool(executor)
+
+	out := tool.ToolFunction(map[string]string{"language": "python", "code": "print(2+2)"})
+
+	var result CodeResult
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatalf("unexpected tool output: %s", out)
+	}
+
+	if result.Stdout != "4\n" || executor.language != "python" || executor.code != "print(2+2)" {
+		t.Errorf("unexpected execution: %+v, executor: %+v", result, executor)
+	}
+}
+
+func TestCodeToolTimeout(t *testing.T) {
+	tool := NewCodeToolWithTimeout(&fakeExecutor{}, 10*time.Millisecond)
+
+	out := tool.ToolFunction(map[string]string{"language": "python", "code": "sleep"})
+	if !strings.Contains(out, "timed out") {
+		t.Errorf("expected timeout error, got %s", out)
+	}
+}
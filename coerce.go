@@ -0,0 +1,103 @@
+package sapiens
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+// coerceArguments converts string argument values to the number, integer or
+// boolean type declared for them in the tool's parameters, for tools that
+// receive typed arguments. Models often quote scalars ("3", "true") even when
+// the schema asks for a typed value. Values that cannot be converted exactly,
+// including NaN and infinities, are left untouched. redact is applied to
+// values before they are logged.
+func coerceArguments(tool string, properties map[string]jsonschema.Definition, args map[string]interface{}, redact func(string) string) map[string]interface{} {
+	for name, value := range args {
+		definition, ok := properties[name]
+		if !ok {
+			continue
+		}
+
+		str, ok := value.(string)
+		if !ok {
+			continue
+		}
+		str = strings.TrimSpace(str)
+
+		var coerced interface{}
+		switch definition.Type {
+		case jsonschema.Number:
+			if f, err := strconv.ParseFloat(str, 64); err == nil && !math.IsNaN(f) && !math.IsInf(f, 0) {
+				// Keep the digits as written when they are a valid JSON number
+				if json.Valid([]byte(str)) {
+					coerced = json.Number(str)
+				} else {
+					coerced = f
+				}
+			}
+		case jsonschema.Integer:
+			if i, err := strconv.ParseInt(str, 10, 64); err == nil {
+				coerced = i
+			} else if f, err := strconv.ParseFloat(str, 64); err == nil && f == math.Trunc(f) && math.Abs(f) <= 1<<53 {
+				coerced = int64(f)
+			}
+		case jsonschema.Boolean:
+			if b, err := strconv.ParseBool(str); err == nil {
+				coerced = b
+			}
+		}
+
+		if coerced != nil {
+			fmt.Printf("DEBUG: coerced argument '%s' of tool '%s' from string %q to %s\n", name, tool, redact(str), definition.Type)
+			args[name] = coerced
+		}
+	}
+
+	return args
+}
+
+// decodeArguments decodes tool call arguments, keeping numbers as json.Number
+// so large integers and the digits the model wrote are not lost.
+func decodeArguments(arguments string) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(strings.NewReader(arguments))
+	decoder.UseNumber()
+
+	var args map[string]interface{}
+	if err := decoder.Decode(&args); err != nil {
+		return nil, err
+	}
+
+	return args, nil
+}
+
+// stringifyArguments flattens arguments decoded by decodeArguments into the
+// map[string]string form regular tool functions receive. Strings are passed
+// as they are and numbers as written; other values are passed as their JSON
+// encoding, e.g. true becomes "true".
+func stringifyArguments(args map[string]interface{}) map[string]string {
+	params := make(map[string]string, len(args))
+	for name, value := range args {
+		switch v := value.(type) {
+		case string:
+			params[name] = v
+		case json.Number:
+			params[name] = v.String()
+		case nil:
+			params[name] = ""
+		default:
+			encoded, err := json.Marshal(v)
+			if err != nil {
+				params[name] = ""
+				continue
+			}
+			params[name] = string(encoded)
+		}
+	}
+
+	return params
+}
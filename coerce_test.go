@@ -0,0 +1,87 @@
+package sapiens
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+func TestCoerceArguments(t *testing.T) {
+	properties := map[string]jsonschema.Definition{
+		"price":    {Type: jsonschema.Number},
+		"quantity": {Type: jsonschema.Integer},
+		"express":  {Type: jsonschema.Boolean},
+		"note":     {Type: jsonschema.String},
+		"pages":    {Type: jsonschema.Integer},
+		"ratio":    {Type: jsonschema.Number},
+		"huge":     {Type: jsonschema.Integer},
+	}
+
+	got := coerceArguments("order", properties, map[string]interface{}{
+		"price":    "9.99",
+		"quantity": " 3 ",
+		"express":  "true",
+		"note":     "42",
+		"pages":    "many",
+		"ratio":    "NaN",
+		"huge":     "1e300",
+		"unknown":  "1",
+	}, func(s string) string { return s })
+
+	want := map[string]interface{}{
+		"price":    json.Number("9.99"),
+		"quantity": int64(3),
+		"express":  true,
+		"note":     "42",
+		"pages":    "many",
+		"ratio":    "NaN",
+		"huge":     "1e300",
+		"unknown":  "1",
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("coerceArguments() = %#v, want %#v", got, want)
+	}
+}
+
+func TestAgentToolReceivesArgumentsAsWritten(t *testing.T) {
+	completer := newMockCompleter(
+		toolCallResponse(functionCall("call_1", "order", `{"item":"pen","quantity":3,"express":"TRUE","price":"3.50","code":"007","id":12345678901234567890}`)),
+		textResponse("ordered"),
+	)
+
+	agent := NewAgent(context.Background(), completer, "test-model", "you place orders")
+
+	var calledWith map[string]string
+	agent.AddTool("order", "Place an order", map[string]jsonschema.Definition{
+		"item":     {Type: jsonschema.String},
+		"quantity": {Type: jsonschema.Integer},
+		"express":  {Type: jsonschema.Boolean},
+		"price":    {Type: jsonschema.Number},
+		"code":     {Type: jsonschema.Integer},
+		"id":       {Type: jsonschema.Integer},
+	}, []string{"item", "quantity"}, func(parameters map[string]string) string {
+		calledWith = parameters
+		return `{"status":"ok"}`
+	})
+
+	message := NewMessages()
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("order 3 pens"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	want := map[string]string{
+		"item":     "pen",
+		"quantity": "3",
+		"express":  "TRUE",
+		"price":    "3.50",
+		"code":     "007",
+		"id":       "12345678901234567890",
+	}
+	if !reflect.DeepEqual(calledWith, want) {
+		t.Errorf("tool called with %v, want %v", calledWith, want)
+	}
+}
@@ -0,0 +1,122 @@
+package sapiens
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// AgentConfig describes an agent declaratively, e.g. a profile loaded from a
+// config file, for NewAgentWithConfig. Zero values keep the defaults of
+// NewAgent.
+type AgentConfig struct {
+	Model            string      `json:"model"`
+	SystemPrompt     string      `json:"system_prompt,omitempty"`
+	MaxToolCallDepth int         `json:"max_tool_call_depth,omitempty"` // rounds of tool calls per Ask, 5 when zero
+	Temperature      *float32    `json:"temperature,omitempty"`         // the provider's default when nil
+	MaxTokens        int         `json:"max_tokens,omitempty"`          // per completion, no cap when zero
+	ToolChoice       string      `json:"tool_choice,omitempty"`         // see SetToolChoice
+	RetryPolicy      RetryPolicy `json:"retry_policy"`
+	Timeouts         Timeouts    `json:"timeouts"`
+}
+
+// Timeouts bound how long an agent waits; zero disables a timeout. In JSON
+// the durations are strings such as "30s", see time.ParseDuration.
+type Timeouts struct {
+	Turn       time.Duration `json:"turn,omitempty"`       // a whole Ask, see SetTimeBudget
+	Completion time.Duration `json:"completion,omitempty"` // each completion request, see SetCompletionTimeout
+}
+
+// jsonTimeouts is the JSON form of Timeouts.
+type jsonTimeouts struct {
+	Turn       jsonDuration `json:"turn,omitempty"`
+	Completion jsonDuration `json:"completion,omitempty"`
+}
+
+func (t Timeouts) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonTimeouts{Turn: jsonDuration(t.Turn), Completion: jsonDuration(t.Completion)})
+}
+
+func (t *Timeouts) UnmarshalJSON(data []byte) error {
+	var timeouts jsonTimeouts
+	if err := json.Unmarshal(data, &timeouts); err != nil {
+		return err
+	}
+
+	*t = Timeouts{Turn: time.Duration(timeouts.Turn), Completion: time.Duration(timeouts.Completion)}
+	return nil
+}
+
+// jsonDuration is a time.Duration written in JSON as a string such as "30s".
+// Numbers are read as nanoseconds, the encoding of time.Duration.
+type jsonDuration time.Duration
+
+func (d jsonDuration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *jsonDuration) UnmarshalJSON(data []byte) error {
+	var nanoseconds int64
+	if err := json.Unmarshal(data, &nanoseconds); err == nil {
+		*d = jsonDuration(nanoseconds)
+		return nil
+	}
+
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return fmt.Errorf("invalid duration %s", data)
+	}
+
+	duration, err := time.ParseDuration(text)
+	if err != nil {
+		return err
+	}
+	*d = jsonDuration(duration)
+
+	return nil
+}
+
+// Validate reports the first invalid setting of the config.
+func (c AgentConfig) Validate() error {
+	switch {
+	case c.Model == "":
+		return fmt.Errorf("model must not be empty")
+	case c.MaxToolCallDepth < 0:
+		return fmt.Errorf("max tool call depth must not be negative, got %d", c.MaxToolCallDepth)
+	case c.Temperature != nil && (*c.Temperature < 0 || *c.Temperature > 2):
+		return fmt.Errorf("temperature must be between 0 and 2, got %g", *c.Temperature)
+	case c.MaxTokens < 0:
+		return fmt.Errorf("max tokens must not be negative, got %d", c.MaxTokens)
+	case c.RetryPolicy.MaxRetries < 0 || c.RetryPolicy.Delay < 0:
+		return fmt.Errorf("retry policy must not be negative, got %+v", c.RetryPolicy)
+	case c.Timeouts.Turn < 0 || c.Timeouts.Completion < 0:
+		return fmt.Errorf("timeouts must not be negative, got %+v", c.Timeouts)
+	}
+
+	return nil
+}
+
+// NewAgentWithConfig creates an agent like NewAgent and applies the config
+// at once instead of through separate setters. It fails if the config is
+// invalid.
+func NewAgentWithConfig(ctx context.Context, llm ChatCompleter, config AgentConfig) (*Agent, error) {
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid agent config: %w", err)
+	}
+
+	agent := NewAgent(ctx, llm, config.Model, config.SystemPrompt)
+	if config.MaxToolCallDepth > 0 {
+		agent.SetMaxToolCallDepth(config.MaxToolCallDepth)
+	}
+	if config.Temperature != nil {
+		agent.SetTemperature(*config.Temperature)
+	}
+	agent.SetMaxTokens(config.MaxTokens)
+	agent.SetToolChoice(config.ToolChoice)
+	agent.SetRetryPolicy(config.RetryPolicy)
+	agent.SetTimeBudget(config.Timeouts.Turn)
+	agent.SetCompletionTimeout(config.Timeouts.Completion)
+
+	return agent, nil
+}
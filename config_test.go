@@ -0,0 +1,169 @@
+package sapiens
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestNewAgentWithConfig(t *testing.T) {
+	temperature := float32(0.2)
+	completer := newMockCompleter(
+		toolCallResponse(functionCall("call_1", "lookup", "{}")),
+		textResponse("done"),
+	)
+
+	agent, err := NewAgentWithConfig(context.Background(), completer, AgentConfig{
+		Model:            "support-model",
+		SystemPrompt:     "You help customers.",
+		MaxToolCallDepth: 2,
+		Temperature:      &temperature,
+		MaxTokens:        256,
+		ToolChoice:       "lookup",
+		RetryPolicy:      RetryPolicy{MaxRetries: 2, Delay: time.Millisecond},
+		Timeouts:         Timeouts{Turn: time.Minute, Completion: 10 * time.Second},
+	})
+	if err != nil {
+		t.Fatalf("NewAgentWithConfig error: %v", err)
+	}
+	agent.AddTool("lookup", "Look up the order", nil, nil, func(map[string]string) string { return "shipped" })
+
+	if agent.maxToolCallDepth != 2 || agent.maxTotalToolCalls != 25 || agent.timeBudget != time.Minute ||
+		agent.completionTimeout != 10*time.Second || agent.retryPolicy.MaxRetries != 2 {
+		t.Errorf("config not applied: %+v", agent)
+	}
+
+	message := NewMessages()
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("where is my order?"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	request := completer.Requests[0]
+	if request.Model != "support-model" || request.Messages[0].Content != "You help customers." {
+		t.Errorf("request model %q, messages %+v", request.Model, request.Messages)
+	}
+	if request.Temperature != 0.2 || request.MaxTokens != 256 {
+		t.Errorf("temperature = %v, max tokens = %d", request.Temperature, request.MaxTokens)
+	}
+	wantChoice := openai.ToolChoice{Type: openai.ToolTypeFunction, Function: openai.ToolFunction{Name: "lookup"}}
+	if !reflect.DeepEqual(request.ToolChoice, wantChoice) {
+		t.Errorf("tool choice = %#v", request.ToolChoice)
+	}
+}
+
+func TestNewAgentWithConfigDefaults(t *testing.T) {
+	completer := newMockCompleter(textResponse("hi"))
+
+	agent, err := NewAgentWithConfig(context.Background(), completer, AgentConfig{Model: "test-model"})
+	if err != nil {
+		t.Fatalf("NewAgentWithConfig error: %v", err)
+	}
+	if agent.maxToolCallDepth != 5 {
+		t.Errorf("max tool call depth = %d, want the default 5", agent.maxToolCallDepth)
+	}
+
+	message := NewMessages()
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("hi"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+	request := completer.Requests[0]
+	if request.Temperature != 0 || request.MaxTokens != 0 || request.ToolChoice != nil {
+		t.Errorf("expected provider defaults, got %+v", request)
+	}
+}
+
+func TestAgentConfigValidate(t *testing.T) {
+	tooHot := float32(2.5)
+	tests := map[string]struct {
+		config AgentConfig
+		want   string
+	}{
+		"no model":          {AgentConfig{}, "model"},
+		"negative depth":    {AgentConfig{Model: "m", MaxToolCallDepth: -1}, "depth"},
+		"temperature":       {AgentConfig{Model: "m", Temperature: &tooHot}, "temperature"},
+		"negative tokens":   {AgentConfig{Model: "m", MaxTokens: -1}, "tokens"},
+		"negative retries":  {AgentConfig{Model: "m", RetryPolicy: RetryPolicy{MaxRetries: -1}}, "retry"},
+		"negative timeouts": {AgentConfig{Model: "m", Timeouts: Timeouts{Turn: -time.Second}}, "timeouts"},
+	}
+
+	for name, test := range tests {
+		_, err := NewAgentWithConfig(context.Background(), newMockCompleter(), test.config)
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: error = %v, want one mentioning %q", name, err, test.want)
+		}
+	}
+
+	if err := (AgentConfig{Model: "m"}).Validate(); err != nil {
+		t.Errorf("minimal config rejected: %v", err)
+	}
+}
+
+func TestToolChoiceParam(t *testing.T) {
+	for _, choice := range []string{"auto", "none", "required"} {
+		if got := toolChoiceParam(choice); got != choice {
+			t.Errorf("toolChoiceParam(%q) = %#v", choice, got)
+		}
+	}
+}
+
+func TestAgentZeroTemperatureIsSent(t *testing.T) {
+	var body map[string]interface{}
+	server := newBodyRecorder(t, &body)
+
+	llm := NewOpenai("key")
+	llm.BaseUrl = server.URL
+
+	zero := float32(0)
+	agent, err := NewAgentWithConfig(context.Background(), llm.Client(), AgentConfig{Model: "test-model", Temperature: &zero})
+	if err != nil {
+		t.Fatalf("NewAgentWithConfig error: %v", err)
+	}
+
+	message := NewMessages()
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("hi"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	temperature, ok := body["temperature"]
+	if !ok || temperature != float64(0) {
+		t.Errorf("temperature = %v (sent: %t), want 0", temperature, ok)
+	}
+}
+
+func TestAgentConfigDurationsJSON(t *testing.T) {
+	var config AgentConfig
+	err := json.Unmarshal([]byte(`{
+		"model": "m",
+		"retry_policy": {"max_retries": 3, "delay": "500ms"},
+		"timeouts": {"turn": "2m", "completion": 30000000000}
+	}`), &config)
+	if err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+
+	want := AgentConfig{
+		Model:       "m",
+		RetryPolicy: RetryPolicy{MaxRetries: 3, Delay: 500 * time.Millisecond},
+		Timeouts:    Timeouts{Turn: 2 * time.Minute, Completion: 30 * time.Second},
+	}
+	if !reflect.DeepEqual(config, want) {
+		t.Errorf("config = %+v, want %+v", config, want)
+	}
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("Marshal error: %v", err)
+	}
+	if !strings.Contains(string(data), `"timeouts":{"turn":"2m0s","completion":"30s"}`) {
+		t.Errorf("encoded config = %s", data)
+	}
+
+	if err := json.Unmarshal([]byte(`{"timeouts": {"turn": "soon"}}`), &config); err == nil {
+		t.Errorf("expected an error for an invalid duration")
+	}
+}
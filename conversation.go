@@ -0,0 +1,96 @@
+package sapiens
+
+import (
+	"fmt"
+	"sync"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Participant is a named agent taking part in a Conversation.
+type Participant struct {
+	Name  string
+	Agent *Agent
+}
+
+// ConversationTurn is one message of a Conversation and who said it.
+type ConversationTurn struct {
+	Speaker string
+	Content string
+}
+
+// Conversation routes messages between agents. Participants speak in the
+// order they were given; each one receives what the others said since its
+// last turn as user messages prefixed with the speaker's name, and its own
+// previous reply as an assistant message.
+type Conversation struct {
+	mu           sync.Mutex
+	participants []Participant
+	turns        []ConversationTurn
+	next         int
+	seen         []int // per participant, index of the first turn not yet sent to it
+}
+
+func NewConversation(participants ...Participant) *Conversation {
+	return &Conversation{
+		participants: participants,
+		seen:         make([]int, len(participants)),
+	}
+}
+
+// Say adds a message to the conversation without asking any agent, e.g. the
+// opening prompt from a moderator or a human.
+func (c *Conversation) Say(speaker, content string) {
+	c.mu.Lock()
+	c.turns = append(c.turns, ConversationTurn{Speaker: speaker, Content: content})
+	c.mu.Unlock()
+}
+
+// Step asks the next participant to respond to the conversation so far and
+// returns its turn.
+func (c *Conversation) Step() (ConversationTurn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.participants) == 0 {
+		return ConversationTurn{}, fmt.Errorf("conversation has no participants")
+	}
+	if len(c.turns) == 0 {
+		return ConversationTurn{}, fmt.Errorf("conversation has not started; use Say to add an opening message")
+	}
+
+	index := c.next
+	participant := c.participants[index]
+
+	var messages []openai.ChatCompletionMessage
+	for _, turn := range c.turns[c.seen[index]:] {
+		if turn.Speaker == participant.Name {
+			messages = append(messages, NewMessages().AgentMessage(turn.Content))
+			continue
+		}
+		messages = append(messages, NewMessages().UserMessage(fmt.Sprintf("%s: %s", turn.Speaker, turn.Content)))
+	}
+
+	resp, err := participant.Agent.Ask(messages)
+	if err != nil {
+		return ConversationTurn{}, fmt.Errorf("participant '%s': %w", participant.Name, err)
+	}
+	if len(resp.Choices) == 0 {
+		return ConversationTurn{}, fmt.Errorf("participant '%s': no choices in response", participant.Name)
+	}
+
+	turn := ConversationTurn{Speaker: participant.Name, Content: resp.Choices[0].Message.Content}
+	c.seen[index] = len(c.turns)
+	c.turns = append(c.turns, turn)
+	c.next = (index + 1) % len(c.participants)
+
+	return turn, nil
+}
+
+// Turns returns the conversation so far, in order.
+func (c *Conversation) Turns() []ConversationTurn {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]ConversationTurn(nil), c.turns...)
+}
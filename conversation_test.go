@@ -0,0 +1,55 @@
+package sapiens
+
+import (
+	"context"
+	"testing"
+)
+
+func TestConversationStep(t *testing.T) {
+	alice := newMockCompleter(textResponse("I think we should use Go."), textResponse("Agreed, Go it is."))
+	bob := newMockCompleter(textResponse("Go is fine, but what about Rust?"))
+
+	conversation := NewConversation(
+		Participant{Name: "Alice", Agent: NewAgent(context.Background(), alice, "test-model", "you are Alice")},
+		Participant{Name: "Bob", Agent: NewAgent(context.Background(), bob, "test-model", "you are Bob")},
+	)
+
+	if _, err := conversation.Step(); err == nil {
+		t.Fatal("expected an error before the conversation started")
+	}
+
+	conversation.Say("Moderator", "Which language should we use?")
+
+	for i := 0; i < 3; i++ {
+		if _, err := conversation.Step(); err != nil {
+			t.Fatalf("Step %d error: %v", i, err)
+		}
+	}
+
+	turns := conversation.Turns()
+	speakers := []string{"Moderator", "Alice", "Bob", "Alice"}
+	if len(turns) != len(speakers) {
+		t.Fatalf("expected %d turns, got %+v", len(speakers), turns)
+	}
+	for i, speaker := range speakers {
+		if turns[i].Speaker != speaker {
+			t.Errorf("turn %d: expected %s, got %s", i, speaker, turns[i].Speaker)
+		}
+	}
+
+	// Bob hears the moderator and Alice, attributed by name
+	bobMessages := bob.Requests[0].Messages
+	if got := bobMessages[len(bobMessages)-1].Content; got != "Alice: I think we should use Go." {
+		t.Errorf("unexpected message routed to Bob: %q", got)
+	}
+
+	// Alice gets her own previous reply back as an assistant message, then Bob's
+	aliceMessages := alice.Requests[1].Messages
+	own, reply := aliceMessages[len(aliceMessages)-2], aliceMessages[len(aliceMessages)-1]
+	if own.Role != "assistant" || own.Content != "I think we should use Go." {
+		t.Errorf("expected Alice's own turn as an assistant message, got %+v", own)
+	}
+	if reply.Content != "Bob: Go is fine, but what about Rust?" {
+		t.Errorf("unexpected message routed to Alice: %q", reply.Content)
+	}
+}
@@ -0,0 +1,131 @@
+package sapiens
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+// dateTimeNow is the clock of the date/time tool, replaced in tests.
+var dateTimeNow = time.Now
+
+// DateTimeResult is the JSON returned by the "current_datetime" tool.
+type DateTimeResult struct {
+	Now      string `json:"now"`
+	Timezone string `json:"timezone"`
+	Weekday  string `json:"weekday"`
+	Date     string `json:"date,omitempty"`
+	Day      string `json:"day,omitempty"`
+}
+
+// NewDateTimeTool returns a "current_datetime" tool telling the model the
+// current time in the tz time zone, e.g. "Asia/Kolkata"; an empty tz means
+// UTC. The model may pass a relative day such as "tomorrow", "next monday",
+// "last friday", "in 3 days" or "2 weeks ago" to have it resolved to a date.
+func NewDateTimeTool(tz string) AgentTool {
+	location, locationErr := time.LoadLocation(tz)
+
+	return newAgentTool("current_datetime",
+		"Get the current date and time, optionally resolving a relative day such as 'tomorrow' or 'next monday' to a date",
+		map[string]jsonschema.Definition{
+			"relative": {
+				Type:        jsonschema.String,
+				Description: "A relative day to resolve: today, tomorrow, yesterday, [next|last] <weekday>, in <n> days|weeks, <n> days|weeks ago",
+			},
+		},
+		nil,
+		func(parameters map[string]string) string {
+			if locationErr != nil {
+				return toolError(fmt.Errorf("unknown time zone '%s': %w", tz, locationErr))
+			}
+
+			now := dateTimeNow().In(location)
+			result := DateTimeResult{
+				Now:      now.Format(time.RFC3339),
+				Timezone: location.String(),
+				Weekday:  now.Weekday().String(),
+			}
+
+			if relative := parameters["relative"]; relative != "" {
+				day, err := resolveRelativeDay(now, relative)
+				if err != nil {
+					return toolError(err)
+				}
+				result.Date = day.Format("2006-01-02")
+				result.Day = day.Weekday().String()
+			}
+
+			encoded, err := json.Marshal(result)
+			if err != nil {
+				return toolError(err)
+			}
+
+			return string(encoded)
+		},
+	)
+}
+
+var relativeOffset = regexp.MustCompile(`^(?:in\s+(\d+)\s+(day|week)s?|(\d+)\s+(day|week)s?\s+ago)$`)
+
+// resolveRelativeDay resolves an expression like "next monday" against now.
+// A bare weekday is its next occurrence, today included; "next" skips today
+// and "last" is the latest occurrence before today.
+func resolveRelativeDay(now time.Time, relative string) (time.Time, error) {
+	expression := strings.Join(strings.Fields(strings.ToLower(relative)), " ")
+
+	switch expression {
+	case "today", "now":
+		return now, nil
+	case "tomorrow":
+		return now.AddDate(0, 0, 1), nil
+	case "yesterday":
+		return now.AddDate(0, 0, -1), nil
+	}
+
+	if match := relativeOffset.FindStringSubmatch(expression); match != nil {
+		count, unit, sign := match[1], match[2], 1
+		if count == "" {
+			count, unit, sign = match[3], match[4], -1
+		}
+		n, _ := strconv.Atoi(count)
+		if unit == "week" {
+			n *= 7
+		}
+		return now.AddDate(0, 0, sign*n), nil
+	}
+
+	modifier, name := "", expression
+	if parts := strings.SplitN(expression, " ", 2); len(parts) == 2 {
+		modifier, name = parts[0], parts[1]
+	}
+
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		if strings.ToLower(weekday.String()) != name {
+			continue
+		}
+
+		ahead := (int(weekday) - int(now.Weekday()) + 7) % 7
+		switch modifier {
+		case "", "this", "coming":
+			return now.AddDate(0, 0, ahead), nil
+		case "next":
+			if ahead == 0 {
+				ahead = 7
+			}
+			return now.AddDate(0, 0, ahead), nil
+		case "last", "previous":
+			behind := (int(now.Weekday()) - int(weekday) + 7) % 7
+			if behind == 0 {
+				behind = 7
+			}
+			return now.AddDate(0, 0, -behind), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("cannot resolve relative day '%s'", relative)
+}
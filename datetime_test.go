@@ -0,0 +1,55 @@
+package sapiens
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDateTimeTool(t *testing.T) {
+	previous := dateTimeNow
+	// Wednesday 2025-01-15 23:30 UTC, already Thursday in Kolkata
+	dateTimeNow = func() time.Time { return time.Date(2025, 1, 15, 23, 30, 0, 0, time.UTC) }
+	defer func() { dateTimeNow = previous }()
+
+	tool := NewDateTimeTool("Asia/Kolkata")
+	if tool.ToolDefinition.Function.Name != "current_datetime" {
+		t.Fatalf("unexpected tool name: %s", tool.ToolDefinition.Function.Name)
+	}
+
+	var result DateTimeResult
+	if err := json.Unmarshal([]byte(tool.ToolFunction(map[string]string{})), &result); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if result.Now != "2025-01-16T05:00:00+05:30" || result.Weekday != "Thursday" || result.Timezone != "Asia/Kolkata" || result.Date != "" {
+		t.Errorf("result = %+v", result)
+	}
+
+	tests := map[string]string{
+		"tomorrow":      "2025-01-17",
+		"Yesterday":     "2025-01-15",
+		"next monday":   "2025-01-20",
+		"thursday":      "2025-01-16",
+		"next Thursday": "2025-01-23",
+		"last thursday": "2025-01-09",
+		"last friday":   "2025-01-10",
+		"in 3 days":     "2025-01-19",
+		"2 weeks ago":   "2025-01-02",
+	}
+	for relative, want := range tests {
+		var resolved DateTimeResult
+		json.Unmarshal([]byte(tool.ToolFunction(map[string]string{"relative": relative})), &resolved)
+		if resolved.Date != want {
+			t.Errorf("%q resolved to %q, want %q", relative, resolved.Date, want)
+		}
+	}
+
+	if out := tool.ToolFunction(map[string]string{"relative": "the day after the party"}); !strings.Contains(out, "cannot resolve") {
+		t.Errorf("expected an error for an unknown expression, got %s", out)
+	}
+
+	if out := NewDateTimeTool("Mars/Olympus").ToolFunction(map[string]string{}); !strings.Contains(out, "unknown time zone") {
+		t.Errorf("expected an error for an unknown time zone, got %s", out)
+	}
+}
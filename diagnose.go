@@ -0,0 +1,102 @@
+package sapiens
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// ToolCallDiagnosis describes a turn in which tools were sent to the model
+// but it answered without calling any of them.
+type ToolCallDiagnosis struct {
+	Tools       []openai.Tool // the tools sent with the request
+	Answer      string        // the model's direct answer
+	Explanation string        // why the model did not call a tool, when asked
+	Err         error         // why the explanation could not be obtained
+}
+
+// ToolCallDiagnostics receives the diagnosis of a turn that called no tool.
+type ToolCallDiagnostics func(ToolCallDiagnosis)
+
+// toolCallDiagnostics is the configuration set with SetToolCallDiagnostics.
+type toolCallDiagnostics struct {
+	handler ToolCallDiagnostics
+	explain bool
+}
+
+const missingToolCallQuestion = "You answered without calling any of the tools available to you. Briefly explain why you did not use a tool."
+
+// SetToolCallDiagnostics calls handler after every turn in which tools were
+// sent but the model answered directly, e.g. with LogToolCallDiagnosis. Turns
+// with the tool choice "none" are not diagnosed. When explain is set, the
+// model is also asked why in a follow-up request that is not stored in the
+// history; it is retried, falls back and is measured like the turn's own
+// completions. A nil handler disables the diagnostics.
+func (a *Agent) SetToolCallDiagnostics(handler ToolCallDiagnostics, explain bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if handler == nil {
+		a.toolCallDiagnostics = nil
+		return
+	}
+	a.toolCallDiagnostics = &toolCallDiagnostics{handler: handler, explain: explain}
+}
+
+// LogToolCallDiagnosis logs the tools that were sent and the explanation, if any.
+func LogToolCallDiagnosis(diagnosis ToolCallDiagnosis) {
+	tools, _ := json.Marshal(diagnosis.Tools)
+	log.Printf("DEBUG: no tool was called; tools sent: %s", tools)
+
+	if diagnosis.Explanation != "" {
+		log.Printf("DEBUG: the model explained: %s", diagnosis.Explanation)
+	}
+	if diagnosis.Err != nil {
+		log.Printf("DEBUG: failed to ask the model why no tool was called: %v", diagnosis.Err)
+	}
+}
+
+// diagnoseMissingToolCall reports response to the diagnostics handler when
+// the turn offered tools and none was called.
+func (a *Agent) diagnoseMissingToolCall(ctx context.Context, response openai.ChatCompletionResponse) {
+	a.mu.Lock()
+	diagnostics := a.toolCallDiagnostics
+	request := a.Request
+	called := len(a.toolCallTrace) > 0 || len(a.pendingToolCalls) > 0
+	a.mu.Unlock()
+
+	if diagnostics == nil || len(request.Tools) == 0 || called || len(response.Choices) == 0 || request.ToolChoice == "none" {
+		return
+	}
+	for _, choice := range response.Choices {
+		if len(choice.Message.ToolCalls) > 0 {
+			return
+		}
+	}
+
+	diagnosis := ToolCallDiagnosis{
+		Tools:  request.Tools,
+		Answer: response.Choices[0].Message.Content,
+	}
+
+	if diagnostics.explain {
+		request.Messages = append(request.Messages[:len(request.Messages):len(request.Messages)],
+			NewMessages().AgentMessage(diagnosis.Answer),
+			NewMessages().UserMessage(missingToolCallQuestion),
+		)
+		request.ToolChoice = "none"
+		request.ResponseFormat = nil
+		request.N = 0
+		request.Stream = false
+
+		explanation, _, err := a.complete(ctx, &request, nil)
+		if err == nil && len(explanation.Choices) > 0 {
+			diagnosis.Explanation = explanation.Choices[0].Message.Content
+		}
+		diagnosis.Err = err
+	}
+
+	diagnostics.handler(diagnosis)
+}
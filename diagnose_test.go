@@ -0,0 +1,132 @@
+package sapiens
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestAgentToolCallDiagnostics(t *testing.T) {
+	completer := newMockCompleter(
+		textResponse("It is sunny."),
+		textResponse("I already knew the weather."),
+		toolCallResponse(functionCall("call_1", "get_weather", `{}`)),
+		textResponse("Sunny."),
+	)
+	agent := NewAgent(context.Background(), completer, "test-model", "")
+	if err := agent.AddTool("get_weather", "current weather", nil, nil, func(map[string]string) string { return "sunny" }); err != nil {
+		t.Fatalf("AddTool error: %v", err)
+	}
+
+	var diagnoses []ToolCallDiagnosis
+	agent.SetToolCallDiagnostics(func(diagnosis ToolCallDiagnosis) {
+		diagnoses = append(diagnoses, diagnosis)
+	}, true)
+
+	messages := NewMessages()
+	if _, err := agent.Ask(messages.MergeMessages(messages.UserMessage("weather?"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	if len(diagnoses) != 1 {
+		t.Fatalf("got %d diagnoses, want 1", len(diagnoses))
+	}
+	diagnosis := diagnoses[0]
+	if len(diagnosis.Tools) != 1 || diagnosis.Tools[0].Function.Name != "get_weather" {
+		t.Errorf("Tools = %+v, want get_weather", diagnosis.Tools)
+	}
+	if diagnosis.Answer != "It is sunny." || diagnosis.Explanation != "I already knew the weather." || diagnosis.Err != nil {
+		t.Errorf("diagnosis = %+v", diagnosis)
+	}
+
+	explain := completer.Requests[1]
+	if explain.ToolChoice != "none" || explain.Messages[len(explain.Messages)-1].Content != missingToolCallQuestion {
+		t.Errorf("explanation request = %+v", explain)
+	}
+	for _, message := range agent.MessagesHistory {
+		if message.Content == missingToolCallQuestion {
+			t.Errorf("explanation request stored in the history")
+		}
+	}
+
+	// A turn that calls a tool is not diagnosed
+	if _, err := agent.Ask(messages.MergeMessages(messages.UserMessage("weather now?"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+	if len(diagnoses) != 1 {
+		t.Errorf("got %d diagnoses after a tool call, want 1", len(diagnoses))
+	}
+
+	agent.SetToolCallDiagnostics(nil, false)
+	completer.Enqueue(textResponse("Sunny."))
+	if _, err := agent.Ask(messages.MergeMessages(messages.UserMessage("and tomorrow?"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+	if len(diagnoses) != 1 || completer.Remaining() != 0 {
+		t.Errorf("diagnostics still active after being disabled")
+	}
+}
+
+func TestAgentToolCallDiagnosticsWithoutTools(t *testing.T) {
+	completer := newMockCompleter(textResponse("Hello."))
+	agent := NewAgent(context.Background(), completer, "test-model", "")
+
+	called := false
+	agent.SetToolCallDiagnostics(func(ToolCallDiagnosis) { called = true }, true)
+
+	if _, err := agent.Ask([]openai.ChatCompletionMessage{NewMessages().UserMessage("hi")}); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+	if called || len(completer.Requests) != 1 {
+		t.Errorf("turn without tools was diagnosed")
+	}
+}
+
+func TestAgentToolCallDiagnosticsExplanationUsesCompletionPath(t *testing.T) {
+	explanation := textResponse("No tool was needed.")
+	explanation.Usage = openai.Usage{PromptTokens: 30, CompletionTokens: 4}
+
+	completer := newMockCompleter(textResponse("Hello."))
+	completer.EnqueueError(&openai.APIError{HTTPStatusCode: http.StatusTooManyRequests})
+	completer.Enqueue(explanation)
+
+	agent := NewAgent(context.Background(), completer, "test-model", "")
+	agent.AddTool("lookup", "lookup", nil, nil, func(map[string]string) string { return "" })
+	agent.SetRetryPolicy(RetryPolicy{MaxRetries: 1, Delay: time.Millisecond})
+	metrics := NewInMemoryMetrics()
+	agent.SetMetricsCollector(metrics)
+
+	var diagnosis ToolCallDiagnosis
+	agent.SetToolCallDiagnostics(func(d ToolCallDiagnosis) { diagnosis = d }, true)
+
+	if _, err := agent.Ask([]openai.ChatCompletionMessage{NewMessages().UserMessage("hi")}); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	if diagnosis.Explanation != "No tool was needed." || diagnosis.Err != nil {
+		t.Errorf("diagnosis = %+v, want the retried explanation", diagnosis)
+	}
+	if snapshot := metrics.Snapshot(); snapshot.Requests != 3 || snapshot.PromptTokens != 30 {
+		t.Errorf("metrics = %+v, want the explanation requests counted", snapshot)
+	}
+}
+
+func TestAgentToolCallDiagnosticsSkipsToolChoiceNone(t *testing.T) {
+	completer := newMockCompleter(textResponse("Hello."))
+	agent := NewAgent(context.Background(), completer, "test-model", "")
+	agent.AddTool("lookup", "lookup", nil, nil, func(map[string]string) string { return "" })
+	agent.SetToolChoice("none")
+
+	called := false
+	agent.SetToolCallDiagnostics(func(ToolCallDiagnosis) { called = true }, true)
+
+	if _, err := agent.Ask([]openai.ChatCompletionMessage{NewMessages().UserMessage("hi")}); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+	if called || len(completer.Requests) != 1 {
+		t.Errorf("turn with tool choice none was diagnosed")
+	}
+}
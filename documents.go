@@ -0,0 +1,181 @@
+package sapiens
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// document is a named text attached to the agent's context, split into
+// chunks when it is larger than the document limit.
+type document struct {
+	name   string
+	chunks []string
+}
+
+// DocumentSummarizer condenses one chunk of a large document. It can be
+// backed by another agent, typically a cheaper model.
+type DocumentSummarizer func(ctx context.Context, name, chunk string) (string, error)
+
+// AttachDocument makes a document's text available to the model across turns.
+// Documents are sent with the context block of every request, each under a
+// header with its name, and are not stored in MessagesHistory. Attaching a
+// document under an existing name replaces it.
+//
+// A document larger than the limit set with SetDocumentChunkSize or
+// SetDocumentSummarizer is split into chunks of at most that size, each sent
+// under its own part header. When a summarizer is set, the summaries of the
+// chunks are attached instead.
+func (a *Agent) AttachDocument(name, content string) error {
+	if name == "" {
+		return fmt.Errorf("document name must not be empty")
+	}
+
+	a.mu.Lock()
+	limit, summarize, ctx := a.documentLimit, a.documentSummarizer, a.Context
+	a.mu.Unlock()
+
+	chunks := []string{content}
+	if limit > 0 && len(content) > limit {
+		chunks = chunkText(content, limit)
+	}
+
+	if summarize != nil && len(chunks) > 1 {
+		summaries := make([]string, len(chunks))
+		for i, chunk := range chunks {
+			summary, err := summarize(ctx, name, chunk)
+			if err != nil {
+				return fmt.Errorf("failed to summarize part %d of document '%s': %w", i+1, name, err)
+			}
+			summaries[i] = summary
+		}
+		chunks = []string{strings.Join(summaries, "\n\n")}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for i := range a.documents {
+		if a.documents[i].name == name {
+			a.documents[i].chunks = chunks
+			return nil
+		}
+	}
+	a.documents = append(a.documents, document{name: name, chunks: chunks})
+
+	return nil
+}
+
+// DetachDocument removes an attached document. It reports whether a document
+// with that name was attached.
+func (a *Agent) DetachDocument(name string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for i := range a.documents {
+		if a.documents[i].name == name {
+			a.documents = append(a.documents[:i:i], a.documents[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// AttachMCPResource reads a resource from the MCP server added with AddMCP and
+// attaches its text as a document named after the URI, see AttachDocument.
+// The resource is read once; attach it again to pick up changes, or detach
+// it with DetachDocument(uri).
+func (a *Agent) AttachMCPResource(uri string) error {
+	a.mu.Lock()
+	mcpClient, ctx := a.McpClient, a.Context
+	a.mu.Unlock()
+
+	if mcpClient == nil {
+		return fmt.Errorf("no MCP server added")
+	}
+
+	result, err := mcpClient.ReadResourceContext(ctx, uri)
+	if err != nil {
+		return err
+	}
+
+	content, err := resourceText(uri, result)
+	if err != nil {
+		return err
+	}
+
+	return a.AttachDocument(uri, content)
+}
+
+// SetDocumentChunkSize makes AttachDocument split documents larger than size
+// bytes into chunks of at most that size. Zero attaches documents whole.
+func (a *Agent) SetDocumentChunkSize(size int) {
+	a.mu.Lock()
+	a.documentLimit = size
+	a.mu.Unlock()
+}
+
+// SetDocumentSummarizer makes AttachDocument summarize documents larger than
+// limit bytes, chunk by chunk. A nil summarizer only chunks them, as
+// SetDocumentChunkSize does.
+func (a *Agent) SetDocumentSummarizer(limit int, summarize DocumentSummarizer) {
+	a.mu.Lock()
+	a.documentLimit = limit
+	a.documentSummarizer = summarize
+	a.mu.Unlock()
+}
+
+// contextBlockLocked returns the context text followed by the attached
+// documents. The caller must hold a.mu.
+func (a *Agent) contextBlockLocked() string {
+	parts := make([]string, 0, len(a.documents)+1)
+	if a.contextText != "" {
+		parts = append(parts, a.contextText)
+	}
+	for _, doc := range a.documents {
+		if len(doc.chunks) == 1 {
+			parts = append(parts, fmt.Sprintf("Document: %s\n%s", doc.name, doc.chunks[0]))
+			continue
+		}
+		for i, chunk := range doc.chunks {
+			parts = append(parts, fmt.Sprintf("Document: %s (part %d of %d)\n%s", doc.name, i+1, len(doc.chunks), strings.TrimRight(chunk, "\n")))
+		}
+	}
+
+	return strings.Join(parts, "\n\n")
+}
+
+// chunkText splits text into chunks of at most size bytes, preferring to cut
+// after a paragraph, then a line, then a space.
+func chunkText(text string, size int) []string {
+	var chunks []string
+	for len(text) > size {
+		cut := -1
+		for _, separator := range []string{"\n\n", "\n", " "} {
+			if i := strings.LastIndex(text[:size], separator); i > 0 {
+				cut = i + len(separator)
+				break
+			}
+		}
+		if cut < 0 {
+			cut = size
+			for cut > 0 && !utf8.RuneStart(text[cut]) {
+				cut--
+			}
+			if cut == 0 {
+				cut = size
+			}
+		}
+
+		chunks = append(chunks, text[:cut])
+		text = text[cut:]
+	}
+
+	if text != "" {
+		chunks = append(chunks, text)
+	}
+
+	return chunks
+}
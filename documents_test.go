@@ -0,0 +1,120 @@
+package sapiens
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAgentAttachDocument(t *testing.T) {
+	completer := newMockCompleter(textResponse("first"), textResponse("second"))
+
+	agent := NewAgent(context.Background(), completer, "test-model", "you answer from the documents")
+	agent.SetContext("The user is a new employee.")
+	agent.AttachDocument("handbook.md", "The office opens at 9am.")
+	agent.AttachDocument("faq.md", "Parking is free.")
+
+	message := NewMessages()
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("when does the office open?"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	want := "Context:\nThe user is a new employee.\n\nDocument: handbook.md\nThe office opens at 9am.\n\nDocument: faq.md\nParking is free."
+	if got := completer.Requests[0].Messages[1].Content; got != want {
+		t.Errorf("context block = %q, want %q", got, want)
+	}
+
+	agent.AttachDocument("handbook.md", "The office opens at 8am.")
+	if !agent.DetachDocument("faq.md") || agent.DetachDocument("faq.md") {
+		t.Errorf("expected faq.md to be detached exactly once")
+	}
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("and now?"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	want = "Context:\nThe user is a new employee.\n\nDocument: handbook.md\nThe office opens at 8am."
+	if got := completer.Requests[1].Messages[1].Content; got != want {
+		t.Errorf("context block = %q, want %q", got, want)
+	}
+
+	for _, msg := range agent.MessagesHistory {
+		if strings.Contains(msg.Content, "Document:") {
+			t.Fatalf("documents should not be stored in history: %+v", agent.MessagesHistory)
+		}
+	}
+}
+
+func TestAgentAttachDocumentSummarizesLargeDocuments(t *testing.T) {
+	agent := NewAgent(context.Background(), newMockCompleter(), "test-model", "")
+
+	var chunks []string
+	agent.SetDocumentSummarizer(32, func(ctx context.Context, name, chunk string) (string, error) {
+		chunks = append(chunks, chunk)
+		return fmt.Sprintf("summary %d of %s", len(chunks), name), nil
+	})
+
+	if err := agent.AttachDocument("small.txt", "short"); err != nil {
+		t.Fatalf("AttachDocument error: %v", err)
+	}
+	if err := agent.AttachDocument("report.txt", "First paragraph here.\n\nSecond paragraph, a bit longer."); err != nil {
+		t.Fatalf("AttachDocument error: %v", err)
+	}
+
+	if want := []string{"First paragraph here.\n\n", "Second paragraph, a bit longer."}; !reflect.DeepEqual(chunks, want) {
+		t.Errorf("summarized chunks %q, want %q", chunks, want)
+	}
+
+	want := "Document: small.txt\nshort\n\nDocument: report.txt\nsummary 1 of report.txt\n\nsummary 2 of report.txt"
+	if got := agent.contextBlockLocked(); got != want {
+		t.Errorf("context block = %q, want %q", got, want)
+	}
+}
+
+func TestAgentAttachDocumentChunksWithoutSummarizer(t *testing.T) {
+	agent := NewAgent(context.Background(), newMockCompleter(), "test-model", "")
+	agent.SetDocumentChunkSize(32)
+
+	if err := agent.AttachDocument("small.txt", "short"); err != nil {
+		t.Fatalf("AttachDocument error: %v", err)
+	}
+	if err := agent.AttachDocument("report.txt", "First paragraph here.\n\nSecond paragraph, a bit longer."); err != nil {
+		t.Fatalf("AttachDocument error: %v", err)
+	}
+
+	want := "Document: small.txt\nshort\n\n" +
+		"Document: report.txt (part 1 of 2)\nFirst paragraph here.\n\n" +
+		"Document: report.txt (part 2 of 2)\nSecond paragraph, a bit longer."
+	if got := agent.contextBlockLocked(); got != want {
+		t.Errorf("context block = %q, want %q", got, want)
+	}
+
+	agent.SetDocumentChunkSize(0)
+	if err := agent.AttachDocument("report.txt", "First paragraph here.\n\nSecond paragraph, a bit longer."); err != nil {
+		t.Fatalf("AttachDocument error: %v", err)
+	}
+	if got := agent.contextBlockLocked(); strings.Contains(got, "part") {
+		t.Errorf("document chunked without a limit: %q", got)
+	}
+}
+
+func TestChunkText(t *testing.T) {
+	tests := []struct {
+		text string
+		size int
+		want []string
+	}{
+		{"one two three", 20, []string{"one two three"}},
+		{"one two three", 8, []string{"one two ", "three"}},
+		{"line one\nline two", 12, []string{"line one\n", "line two"}},
+		{"abcdefgh", 3, []string{"abc", "def", "gh"}},
+		{"ééé", 3, []string{"é", "é", "é"}},
+	}
+
+	for _, tt := range tests {
+		if got := chunkText(tt.text, tt.size); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("chunkText(%q, %d) = %q, want %q", tt.text, tt.size, got, tt.want)
+		}
+	}
+}
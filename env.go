@@ -0,0 +1,79 @@
+package sapiens
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// NewAgentFromEnv builds an agent configured entirely from environment
+// variables:
+//
+//	SAPIENS_PROVIDER  gemini, openai, anthropic or ollama (required)
+//	SAPIENS_API_KEY   API key (required except for ollama)
+//	SAPIENS_MODEL     model name (defaults to the provider's default model)
+//	SAPIENS_BASE_URL  overrides the provider's API endpoint
+//
+// Tool results are sent in the format the provider expects.
+func NewAgentFromEnv(ctx context.Context, systemPrompt string) (*Agent, error) {
+	provider := strings.ToLower(strings.TrimSpace(os.Getenv("SAPIENS_PROVIDER")))
+	apiKey := os.Getenv("SAPIENS_API_KEY")
+	model := os.Getenv("SAPIENS_MODEL")
+	baseUrl := os.Getenv("SAPIENS_BASE_URL")
+
+	if provider == "" {
+		return nil, fmt.Errorf("SAPIENS_PROVIDER is not set")
+	}
+
+	if apiKey == "" && provider != "ollama" {
+		return nil, fmt.Errorf("SAPIENS_API_KEY is not set for provider '%s'", provider)
+	}
+
+	var llm ChatCompleter
+	var defaultModel string
+	var toolResultFormat ToolResultFormat
+
+	switch provider {
+	case "gemini":
+		gemini := NewGemini(apiKey)
+		if baseUrl != "" {
+			gemini.BaseUrl = baseUrl
+		}
+		llm, defaultModel, toolResultFormat = gemini.Client(), gemini.GetDefaultModel(), gemini.ToolResultFormat()
+	case "openai":
+		openai := NewOpenai(apiKey)
+		if baseUrl != "" {
+			openai.BaseUrl = baseUrl
+		}
+		llm, defaultModel, toolResultFormat = openai.Client(), openai.GetDefaultModel(), openai.ToolResultFormat()
+	case "anthropic":
+		anthropic := NewAnthropic(apiKey)
+		if baseUrl != "" {
+			anthropic.BaseUrl = baseUrl
+		}
+		llm, defaultModel, toolResultFormat = anthropic.Client(), anthropic.GetDefaultModel(), anthropic.ToolResultFormat()
+	case "ollama":
+		if baseUrl == "" {
+			baseUrl = OllamaLocalBaseUrl
+		}
+		if model == "" {
+			return nil, fmt.Errorf("SAPIENS_MODEL is required for provider 'ollama'")
+		}
+		ollama := NewOllama(baseUrl, apiKey, model)
+		llm, defaultModel, toolResultFormat = ollama.Client(), ollama.GetDefaultModel(), ollama.ToolResultFormat()
+	default:
+		return nil, fmt.Errorf("unknown SAPIENS_PROVIDER '%s'; expected gemini, openai, anthropic or ollama", provider)
+	}
+
+	if model == "" {
+		model = defaultModel
+	}
+
+	agent := NewAgent(ctx, llm, model, systemPrompt)
+	if err := agent.SetToolResultFormat(toolResultFormat); err != nil {
+		return nil, err
+	}
+
+	return agent, nil
+}
@@ -0,0 +1,69 @@
+package sapiens
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestNewAgentFromEnv(t *testing.T) {
+	_, script := newScriptedClient(t, textResponse("hello"))
+
+	t.Setenv("SAPIENS_PROVIDER", "Gemini")
+	t.Setenv("SAPIENS_API_KEY", "env-key")
+	t.Setenv("SAPIENS_MODEL", "")
+	t.Setenv("SAPIENS_BASE_URL", script.URL)
+
+	agent, err := NewAgentFromEnv(context.Background(), "be brief")
+	if err != nil {
+		t.Fatalf("NewAgentFromEnv error: %v", err)
+	}
+
+	if agent.Model != GeminiDefaultModel {
+		t.Errorf("expected the provider's default model, got %q", agent.Model)
+	}
+
+	if agent.toolResultFormat != ToolResultsAsUser {
+		t.Errorf("expected Gemini's tool result format, got %+v", agent.toolResultFormat)
+	}
+
+	message := NewMessages()
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("hi"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	if len(script.Requests) != 1 {
+		t.Errorf("expected the request to reach SAPIENS_BASE_URL")
+	}
+}
+
+func TestNewAgentFromEnvErrors(t *testing.T) {
+	cases := []struct {
+		provider, apiKey, model string
+		wantErr                 string
+	}{
+		{"", "key", "", "SAPIENS_PROVIDER is not set"},
+		{"openai", "", "", "SAPIENS_API_KEY is not set"},
+		{"ollama", "", "", "SAPIENS_MODEL is required"},
+		{"mistral", "key", "", "unknown SAPIENS_PROVIDER 'mistral'"},
+	}
+
+	for _, tc := range cases {
+		t.Setenv("SAPIENS_PROVIDER", tc.provider)
+		t.Setenv("SAPIENS_API_KEY", tc.apiKey)
+		t.Setenv("SAPIENS_MODEL", tc.model)
+		t.Setenv("SAPIENS_BASE_URL", "")
+
+		_, err := NewAgentFromEnv(context.Background(), "")
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("provider %q: expected error containing %q, got %v", tc.provider, tc.wantErr, err)
+		}
+	}
+
+	t.Setenv("SAPIENS_PROVIDER", "ollama")
+	t.Setenv("SAPIENS_MODEL", "llama3")
+	agent, err := NewAgentFromEnv(context.Background(), "")
+	if err != nil || agent.Model != "llama3" {
+		t.Errorf("expected an ollama agent without API key, got %v, %v", agent, err)
+	}
+}
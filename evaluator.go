@@ -0,0 +1,61 @@
+package sapiens
+
+import (
+	"fmt"
+)
+
+const evaluatorPrompt = `You are an impartial judge. Rate how well the answer satisfies the criteria for the given question.
+
+Question:
+%s
+
+Answer:
+%s
+
+Criteria:
+%s
+
+Respond with a score between 0 and 1, where 0 means the answer does not meet the criteria at all and 1 means it fully meets them, and a short rationale for the score.`
+
+type evaluation struct {
+	Score     float64 `json:"score" description:"Score between 0 and 1"`
+	Rationale string  `json:"rationale" description:"Short explanation of the score"`
+}
+
+// Evaluator scores answers using an agent as the judge (LLM-as-judge).
+type Evaluator struct {
+	Agent *Agent
+}
+
+// NewEvaluator returns an Evaluator that uses agent as the judge. The agent
+// is switched to stateless mode so that every score is independent of the
+// previous ones.
+func NewEvaluator(agent *Agent) *Evaluator {
+	agent.SetStateless(true)
+
+	return &Evaluator{
+		Agent: agent,
+	}
+}
+
+// Score rates answer against criteria for question, returning a score in
+// the range [0, 1] and the judge's rationale.
+func (e *Evaluator) Score(question, answer, criteria string) (float64, string, error) {
+	message := NewMessages()
+
+	result, err := AskJSON[evaluation](e.Agent, message.MergeMessages(
+		message.UserMessage(fmt.Sprintf(evaluatorPrompt, question, answer, criteria)),
+	))
+	if err != nil {
+		return 0, "", fmt.Errorf("evaluation failed: %w", err)
+	}
+
+	score := result.Score
+	if score < 0 {
+		score = 0
+	} else if score > 1 {
+		score = 1
+	}
+
+	return score, result.Rationale, nil
+}
@@ -0,0 +1,46 @@
+package sapiens
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestEvaluatorScore(t *testing.T) {
+	completer := newMockCompleter(
+		textResponse(`{"score":0.8,"rationale":"Mostly correct"}`),
+		textResponse(`{"score":1.7,"rationale":"Overly generous"}`),
+	)
+
+	evaluator := NewEvaluator(NewAgent(context.Background(), completer, "judge-model", "you are a strict grader"))
+
+	score, rationale, err := evaluator.Score("What is the capital of France?", "Paris", "The answer must name the correct city")
+	if err != nil {
+		t.Fatalf("Score error: %v", err)
+	}
+
+	if score != 0.8 || rationale != "Mostly correct" {
+		t.Errorf("unexpected evaluation: %v %q", score, rationale)
+	}
+
+	request := completer.Requests[0]
+	if request.ResponseFormat == nil || request.ResponseFormat.JSONSchema == nil {
+		t.Fatal("expected a structured response format")
+	}
+	if !strings.Contains(request.Messages[len(request.Messages)-1].Content, "Paris") {
+		t.Error("expected the answer to be included in the judge prompt")
+	}
+
+	score, _, err = evaluator.Score("q", "a", "c")
+	if err != nil {
+		t.Fatalf("Score error: %v", err)
+	}
+	if score != 1 {
+		t.Errorf("expected score to be clamped to 1, got %v", score)
+	}
+
+	// Evaluations are independent of each other
+	if got := len(completer.Requests[1].Messages); got != 2 {
+		t.Errorf("expected 2 messages in the second evaluation, got %d", got)
+	}
+}
@@ -0,0 +1,132 @@
+package sapiens
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+)
+
+// extraBodyTransport adds provider specific fields, which go-openai's request
+// struct has no place for, to every chat completion request body, and
+// rewrites the file parts of FileMessage. Built-in provider tools are added
+// after the function tools. It also notes the Retry-After header and the
+// grounding metadata of responses, which go-openai leaves out.
+type extraBodyTransport struct {
+	base  http.RoundTripper
+	extra map[string]interface{}
+	tools []interface{}
+}
+
+func newExtraBodyClient(extra map[string]interface{}, tools ...interface{}) *http.Client {
+	fields := make(map[string]interface{}, len(extra))
+	for key, value := range extra {
+		fields[key] = value
+	}
+
+	return &http.Client{
+		Transport: &extraBodyTransport{
+			base:  http.DefaultTransport,
+			extra: fields,
+			tools: tools,
+		},
+	}
+}
+
+func (t *extraBodyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodPost || req.Body == nil || !strings.HasSuffix(req.URL.Path, "/chat/completions") {
+		return t.roundTrip(req)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+
+	if err := rewriteFileParts(fields); err != nil {
+		return nil, err
+	}
+	restoreZeroTemperature(fields)
+
+	for key, value := range t.extra {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		fields[key] = encoded
+	}
+
+	if len(t.tools) > 0 {
+		var tools []interface{}
+		if raw, ok := fields["tools"]; ok {
+			if err := json.Unmarshal(raw, &tools); err != nil {
+				return nil, err
+			}
+		}
+
+		encoded, err := json.Marshal(append(tools, t.tools...))
+		if err != nil {
+			return nil, err
+		}
+		fields["tools"] = encoded
+	}
+
+	body, err = json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	// RoundTrippers must not modify the original request
+	clone := req.Clone(req.Context())
+	clone.Body = io.NopCloser(bytes.NewReader(body))
+	clone.ContentLength = int64(len(body))
+	clone.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+
+	return t.roundTrip(clone)
+}
+
+func (t *extraBodyTransport) roundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	noteRetryAfter(req.Context(), resp)
+	if err := noteGrounding(req.Context(), resp); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// restoreZeroTemperature sends the smallest float32, which the agent sets for
+// a zero temperature that go-openai would omit, as 0.
+func restoreZeroTemperature(fields map[string]json.RawMessage) {
+	var temperature float32
+	if raw, ok := fields["temperature"]; ok && json.Unmarshal(raw, &temperature) == nil && temperature == math.SmallestNonzeroFloat32 {
+		fields["temperature"] = json.RawMessage("0")
+	}
+}
+
+// setExtraBody sets or, for a nil value, removes a request field.
+func setExtraBody(extra *map[string]interface{}, key string, value interface{}) {
+	if value == nil {
+		delete(*extra, key)
+		return
+	}
+
+	if *extra == nil {
+		*extra = make(map[string]interface{})
+	}
+	(*extra)[key] = value
+}
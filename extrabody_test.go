@@ -0,0 +1,81 @@
+package sapiens
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newBodyRecorder returns a server that answers every chat completion with
+// "ok" and stores the raw JSON body of the last request.
+func newBodyRecorder(t *testing.T, body *map[string]interface{}) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		json.Unmarshal(raw, body)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(textResponse("ok"))
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestGeminiReasoningControls(t *testing.T) {
+	var body map[string]interface{}
+	server := newBodyRecorder(t, &body)
+
+	llm := NewGemini("key")
+	llm.BaseUrl = server.URL
+	llm.SetThinkingBudget(0)
+	llm.SetReasoningEffort("low")
+
+	agent := NewAgent(context.Background(), llm.Client(), llm.GetDefaultModel(), "")
+	message := NewMessages()
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("hi"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	if body["reasoning_effort"] != "low" {
+		t.Errorf("expected reasoning_effort, got %v", body["reasoning_effort"])
+	}
+
+	thinking, _ := json.Marshal(body["extra_body"])
+	if string(thinking) != `{"google":{"thinking_config":{"thinking_budget":0}}}` {
+		t.Errorf("unexpected thinking config: %s", thinking)
+	}
+
+	if body["model"] != GeminiDefaultModel {
+		t.Errorf("the original request fields should be kept, got model %v", body["model"])
+	}
+}
+
+func TestAnthropicThinkingBudget(t *testing.T) {
+	var body map[string]interface{}
+	server := newBodyRecorder(t, &body)
+
+	llm := NewAnthropic("key")
+	llm.BaseUrl = server.URL
+	llm.SetThinkingBudget(2048)
+
+	agent := NewAgent(context.Background(), llm.Client(), llm.GetDefaultModel(), "")
+	message := NewMessages()
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("hi"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	thinking, _ := json.Marshal(body["thinking"])
+	if string(thinking) != `{"budget_tokens":2048,"type":"enabled"}` {
+		t.Errorf("unexpected thinking config: %s", thinking)
+	}
+
+	llm.SetThinkingBudget(0)
+	if _, ok := llm.ExtraBody["thinking"]; ok {
+		t.Error("a zero budget should disable extended thinking")
+	}
+}
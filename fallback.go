@@ -0,0 +1,54 @@
+package sapiens
+
+import (
+	"errors"
+	"net/http"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// SetFallbackModels sets models to try, in order, when the model of a
+// completion is unavailable (a 5xx error), is still rate limited (a 429
+// error) once the retry policy is exhausted, or filters the content. Once a
+// fallback answers, the remaining rounds of the turn use it too; the Model
+// of the response, and LastResponseMeta, tell which model answered. Pass
+// nil to remove the fallbacks.
+func (a *Agent) SetFallbackModels(models []string) {
+	a.mu.Lock()
+	a.fallbackModels = append([]string(nil), models...)
+	a.mu.Unlock()
+}
+
+// needsFallback reports whether a completion failed in a way another model
+// may not: the model is unavailable, rate limited or refused the content.
+func needsFallback(response openai.ChatCompletionResponse, err error) bool {
+	if err != nil {
+		return isFallbackError(err)
+	}
+
+	for _, choice := range response.Choices {
+		if choice.FinishReason == openai.FinishReasonContentFilter {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isFallbackError(err error) bool {
+	if isRateLimited(err) {
+		return true
+	}
+
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.HTTPStatusCode >= http.StatusInternalServerError || apiErr.Code == "content_filter"
+	}
+
+	var requestErr *openai.RequestError
+	if errors.As(err, &requestErr) {
+		return requestErr.HTTPStatusCode >= http.StatusInternalServerError
+	}
+
+	return false
+}
@@ -0,0 +1,133 @@
+package sapiens
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+// modelCompleter answers each model from its own script and records the
+// model of every request.
+type modelCompleter struct {
+	scripts map[string][]openai.ChatCompletionResponse
+	errors  map[string]error
+	models  []string
+}
+
+func (m *modelCompleter) CreateChatCompletion(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	m.models = append(m.models, request.Model)
+	if err := m.errors[request.Model]; err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+
+	response := m.scripts[request.Model][0]
+	m.scripts[request.Model] = m.scripts[request.Model][1:]
+
+	return response, nil
+}
+
+func (m *modelCompleter) CreateChatCompletionStream(ctx context.Context, request openai.ChatCompletionRequest) (*openai.ChatCompletionStream, error) {
+	return nil, nil
+}
+
+func TestAgentFallbackModels(t *testing.T) {
+	filtered := textResponse("")
+	filtered.Choices[0].FinishReason = openai.FinishReasonContentFilter
+
+	completer := &modelCompleter{
+		scripts: map[string][]openai.ChatCompletionResponse{
+			"filtered": {filtered},
+			"backup": {
+				toolCallResponse(functionCall("call_1", "weather", `{"city":"Paris"}`)),
+				textResponse("Sunny in Paris."),
+			},
+		},
+		errors: map[string]error{
+			"primary": &openai.APIError{HTTPStatusCode: http.StatusServiceUnavailable, Message: "overloaded"},
+		},
+	}
+
+	agent := NewAgent(context.Background(), completer, "primary", "")
+	agent.AddTool("weather", "Get the weather", map[string]jsonschema.Definition{
+		"city": {Type: jsonschema.String},
+	}, []string{"city"}, func(parameters map[string]string) string {
+		return `{"sky":"sunny"}`
+	})
+
+	message := NewMessages()
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("weather in Paris?"))); err == nil {
+		t.Fatalf("expected the 503 without fallbacks")
+	}
+
+	completer.models = nil
+	agent.SetFallbackModels([]string{"filtered", "backup"})
+	resp, err := agent.Ask(message.MergeMessages(message.UserMessage("weather in Paris?")))
+	if err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+	if resp.Choices[0].Message.Content != "Sunny in Paris." || resp.Model != "backup" {
+		t.Errorf("response = %q from %q", resp.Choices[0].Message.Content, resp.Model)
+	}
+
+	wantModels := []string{"primary", "filtered", "backup", "backup"}
+	if !reflect.DeepEqual(completer.models, wantModels) {
+		t.Errorf("requested models %v, want %v", completer.models, wantModels)
+	}
+	if agent.Model != "primary" {
+		t.Errorf("default model changed to %q", agent.Model)
+	}
+}
+
+func TestAgentFallbackAfterRateLimitRetries(t *testing.T) {
+	completer := &modelCompleter{
+		scripts: map[string][]openai.ChatCompletionResponse{
+			"backup": {textResponse("from backup")},
+		},
+		errors: map[string]error{
+			"primary": &openai.APIError{HTTPStatusCode: http.StatusTooManyRequests, Message: "rate limited"},
+		},
+	}
+
+	agent := NewAgent(context.Background(), completer, "primary", "")
+	agent.SetRetryPolicy(RetryPolicy{MaxRetries: 2, Delay: time.Millisecond})
+	agent.SetFallbackModels([]string{"backup"})
+
+	message := NewMessages()
+	resp, err := agent.Ask(message.MergeMessages(message.UserMessage("hi")))
+	if err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+	if resp.Choices[0].Message.Content != "from backup" || resp.Model != "backup" {
+		t.Errorf("response = %q from %q", resp.Choices[0].Message.Content, resp.Model)
+	}
+
+	wantModels := []string{"primary", "primary", "primary", "backup"}
+	if !reflect.DeepEqual(completer.models, wantModels) {
+		t.Errorf("requested models %v, want %v", completer.models, wantModels)
+	}
+}
+
+func TestIsFallbackError(t *testing.T) {
+	tests := map[string]struct {
+		err  error
+		want bool
+	}{
+		"503":            {&openai.APIError{HTTPStatusCode: 503}, true},
+		"500 request":    {&openai.RequestError{HTTPStatusCode: 500}, true},
+		"content filter": {&openai.APIError{HTTPStatusCode: 400, Code: "content_filter"}, true},
+		"bad request":    {&openai.APIError{HTTPStatusCode: 400}, false},
+		"rate limited":   {&openai.APIError{HTTPStatusCode: 429}, true},
+		"retry after":    {&RetryAfterError{Err: &openai.APIError{HTTPStatusCode: 429}}, true},
+	}
+
+	for name, test := range tests {
+		if got := isFallbackError(test.err); got != test.want {
+			t.Errorf("%s: isFallbackError = %v, want %v", name, got, test.want)
+		}
+	}
+}
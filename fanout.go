@@ -0,0 +1,85 @@
+package sapiens
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// FanOutEventType tells what a FanOutEvent carries.
+type FanOutEventType string
+
+const (
+	// FanOutChunk carries a piece of an agent's streamed answer.
+	FanOutChunk FanOutEventType = "chunk"
+	// FanOutDone carries an agent's final response. It is the agent's last event.
+	FanOutDone FanOutEventType = "done"
+	// FanOutError carries the error an agent's turn ended with. It is the
+	// agent's last event.
+	FanOutError FanOutEventType = "error"
+)
+
+// FanOutEvent is an event of one of the agents run by FanOut.
+type FanOutEvent struct {
+	Agent    int // index of the agent in the slice passed to FanOut
+	Type     FanOutEventType
+	Chunk    string                        // for FanOutChunk
+	Response openai.ChatCompletionResponse // for FanOutDone
+	Err      error                         // for FanOutError
+}
+
+// FanOut asks every agent the same prompt concurrently, streaming their
+// answers, and multiplexes the events tagged with the agent's index onto one
+// channel, for side-by-side comparisons. Each agent ends with a FanOutDone or
+// FanOutError event, and the channel is closed once all agents are done.
+// Cancelling ctx aborts the remaining turns.
+func FanOut(ctx context.Context, prompt string, agents []*Agent) (<-chan FanOutEvent, error) {
+	if len(agents) == 0 {
+		return nil, fmt.Errorf("no agents to fan out to")
+	}
+	for i, agent := range agents {
+		if agent == nil {
+			return nil, fmt.Errorf("agent %d is nil", i)
+		}
+	}
+
+	events := make(chan FanOutEvent, len(agents))
+	send := func(event FanOutEvent) {
+		select {
+		case events <- event:
+		case <-ctx.Done():
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i, agent := range agents {
+		wg.Add(1)
+		go func(i int, agent *Agent) {
+			defer wg.Done()
+
+			onContent := func(delta, _ string) {
+				send(FanOutEvent{Agent: i, Type: FanOutChunk, Chunk: delta})
+			}
+
+			messages := NewMessages()
+			agent.askMu.Lock()
+			response, err := agent.ask(askOptions{ctx: ctx, onContent: onContent}, messages.MergeMessages(messages.UserMessage(prompt)))
+			agent.askMu.Unlock()
+
+			if err != nil {
+				send(FanOutEvent{Agent: i, Type: FanOutError, Err: err})
+				return
+			}
+			send(FanOutEvent{Agent: i, Type: FanOutDone, Response: response})
+		}(i, agent)
+	}
+
+	go func() {
+		wg.Wait()
+		close(events)
+	}()
+
+	return events, nil
+}
@@ -0,0 +1,60 @@
+package sapiens
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestFanOut(t *testing.T) {
+	fastClient, _ := newStreamingClient(t, contentChunks("Paris ", "is the capital."))
+	slowClient, _ := newStreamingClient(t, contentChunks("It's ", "Paris."))
+	failingClient, _ := newStreamingClient(t)
+
+	agents := []*Agent{
+		NewAgent(context.Background(), fastClient, "model-a", ""),
+		NewAgent(context.Background(), slowClient, "model-b", ""),
+		NewAgent(context.Background(), failingClient, "model-c", ""),
+	}
+
+	events, err := FanOut(context.Background(), "capital of France?", agents)
+	if err != nil {
+		t.Fatalf("FanOut error: %v", err)
+	}
+
+	streamed := make([]string, len(agents))
+	final := make([]string, len(agents))
+	ended := make([]int, len(agents))
+	for event := range events {
+		if ended[event.Agent] > 0 {
+			t.Errorf("event after the last event of agent %d: %+v", event.Agent, event)
+		}
+		switch event.Type {
+		case FanOutChunk:
+			streamed[event.Agent] += event.Chunk
+		case FanOutDone:
+			final[event.Agent] = event.Response.Choices[0].Message.Content
+			ended[event.Agent]++
+		case FanOutError:
+			final[event.Agent] = "error: " + event.Err.Error()
+			ended[event.Agent]++
+		}
+	}
+
+	if streamed[0] != "Paris is the capital." || final[0] != streamed[0] {
+		t.Errorf("agent 0 streamed %q, answered %q", streamed[0], final[0])
+	}
+	if streamed[1] != "It's Paris." || final[1] != streamed[1] {
+		t.Errorf("agent 1 streamed %q, answered %q", streamed[1], final[1])
+	}
+	if !strings.HasPrefix(final[2], "error: ") {
+		t.Errorf("agent 2 ended with %q, want an error", final[2])
+	}
+
+	if _, err := FanOut(context.Background(), "hi", nil); err == nil {
+		t.Errorf("expected an error without agents")
+	}
+	if _, err := FanOut(context.Background(), "hi", []*Agent{agents[0], nil}); err == nil {
+		t.Errorf("expected an error for a nil agent")
+	}
+}
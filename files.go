@@ -0,0 +1,142 @@
+package sapiens
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// ChatMessagePartTypeFile marks a message part referencing an uploaded file.
+// go-openai has no file part, so the file ID travels in the part's Text and
+// the OpenAI provider's client rewrites it to the API's file part.
+const ChatMessagePartTypeFile openai.ChatMessagePartType = "file"
+
+// FileUploader uploads a file to a provider's file API and returns the ID
+// that messages use to reference it. OpenaiInterface implements it.
+type FileUploader interface {
+	UploadFile(ctx context.Context, path string) (string, error)
+}
+
+// FileMessage builds a user message holding the text followed by a reference
+// to each uploaded file, see Agent.AttachFile.
+func (a *Messages) FileMessage(text string, fileIDs ...string) openai.ChatCompletionMessage {
+	parts := make([]openai.ChatMessagePart, 0, len(fileIDs)+1)
+	if text != "" {
+		parts = append(parts, openai.ChatMessagePart{
+			Type: openai.ChatMessagePartTypeText,
+			Text: text,
+		})
+	}
+
+	for _, fileID := range fileIDs {
+		parts = append(parts, openai.ChatMessagePart{
+			Type: ChatMessagePartTypeFile,
+			Text: fileID,
+		})
+	}
+
+	return openai.ChatCompletionMessage{
+		Role:         openai.ChatMessageRoleUser,
+		MultiContent: parts,
+	}
+}
+
+// SetFileUploader sets the provider file API used by AttachFile.
+func (a *Agent) SetFileUploader(uploader FileUploader) {
+	a.mu.Lock()
+	a.fileUploader = uploader
+	a.mu.Unlock()
+}
+
+// AttachFile uploads a file, such as a large PDF, to the provider and returns
+// its ID for FileMessage, so the document is referenced instead of inlined in
+// every request. Each path is uploaded once; attaching it again returns the
+// same ID.
+func (a *Agent) AttachFile(path string) (string, error) {
+	a.mu.Lock()
+	uploader, ctx := a.fileUploader, a.Context
+	fileID, uploaded := a.uploadedFiles[path]
+	a.mu.Unlock()
+
+	if uploaded {
+		return fileID, nil
+	}
+	if uploader == nil {
+		return "", fmt.Errorf("no file uploader set; see SetFileUploader")
+	}
+
+	fileID, err := uploader.UploadFile(ctx, path)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload '%s': %w", path, err)
+	}
+
+	a.mu.Lock()
+	if a.uploadedFiles == nil {
+		a.uploadedFiles = make(map[string]string)
+	}
+	a.uploadedFiles[path] = fileID
+	a.mu.Unlock()
+
+	return fileID, nil
+}
+
+// UploadFile uploads a file to the OpenAI Files API for use in chat messages.
+func (g *OpenaiInterface) UploadFile(ctx context.Context, path string) (string, error) {
+	file, err := g.Client().CreateFile(ctx, openai.FileRequest{
+		FileName: filepath.Base(path),
+		FilePath: path,
+		Purpose:  "user_data",
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return file.ID, nil
+}
+
+var fileTypeMarker = []byte(`"type":"file"`)
+
+// rewriteFileParts turns the file parts of FileMessage into the API's
+// {"type":"file","file":{"file_id":...}} form.
+func rewriteFileParts(fields map[string]json.RawMessage) error {
+	if !bytes.Contains(fields["messages"], fileTypeMarker) {
+		return nil
+	}
+
+	var messages []map[string]json.RawMessage
+	if err := json.Unmarshal(fields["messages"], &messages); err != nil {
+		return err
+	}
+
+	for _, message := range messages {
+		var parts []map[string]interface{}
+		if json.Unmarshal(message["content"], &parts) != nil {
+			continue // plain text content
+		}
+
+		for _, part := range parts {
+			if part["type"] == string(ChatMessagePartTypeFile) {
+				part["file"] = map[string]interface{}{"file_id": part["text"]}
+				delete(part, "text")
+			}
+		}
+
+		encoded, err := json.Marshal(parts)
+		if err != nil {
+			return err
+		}
+		message["content"] = encoded
+	}
+
+	encoded, err := json.Marshal(messages)
+	if err != nil {
+		return err
+	}
+	fields["messages"] = encoded
+
+	return nil
+}
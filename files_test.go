@@ -0,0 +1,69 @@
+package sapiens
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAgentAttachFileWithOpenAI(t *testing.T) {
+	uploads := 0
+	var chatBody map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "/files") {
+			uploads++
+			r.ParseMultipartForm(1 << 20)
+			if r.FormValue("purpose") != "user_data" {
+				t.Errorf("purpose = %q", r.FormValue("purpose"))
+			}
+			w.Write([]byte(`{"id":"file-abc","object":"file","purpose":"user_data"}`))
+			return
+		}
+
+		raw, _ := io.ReadAll(r.Body)
+		json.Unmarshal(raw, &chatBody)
+		json.NewEncoder(w).Encode(textResponse("It's a lease agreement."))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "contract.pdf")
+	if err := os.WriteFile(path, []byte("%PDF-1.4"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	llm := NewOpenai("key")
+	llm.BaseUrl = server.URL
+	agent := NewAgent(context.Background(), llm.Client(), llm.GetDefaultModel(), "")
+
+	if _, err := agent.AttachFile(path); err == nil {
+		t.Errorf("expected an error without a file uploader")
+	}
+
+	agent.SetFileUploader(llm)
+	fileID, err := agent.AttachFile(path)
+	if err != nil || fileID != "file-abc" {
+		t.Fatalf("AttachFile = %q, %v", fileID, err)
+	}
+	if again, err := agent.AttachFile(path); err != nil || again != fileID || uploads != 1 {
+		t.Errorf("second AttachFile = %q, %v after %d uploads, want the cached ID", again, err, uploads)
+	}
+
+	message := NewMessages()
+	if _, err := agent.Ask(message.MergeMessages(message.FileMessage("what kind of document is this?", fileID))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	messages := chatBody["messages"].([]interface{})
+	content, _ := json.Marshal(messages[len(messages)-1].(map[string]interface{})["content"])
+	want := `[{"text":"what kind of document is this?","type":"text"},{"file":{"file_id":"file-abc"},"type":"file"}]`
+	if string(content) != want {
+		t.Errorf("content sent as %s, want %s", content, want)
+	}
+}
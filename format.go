@@ -0,0 +1,132 @@
+package sapiens
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Formats understood by FormatResponse.
+const (
+	FormatPlain    = "plain"
+	FormatMarkdown = "markdown"
+)
+
+// FormatResponse renders response content for display. FormatPlain strips
+// Markdown syntax, keeping the text, code and link targets, for logs and
+// terminals without Markdown support. FormatMarkdown, like any other format,
+// returns the content unchanged.
+func FormatResponse(content string, format string) string {
+	if format != FormatPlain {
+		return content
+	}
+
+	return stripMarkdown(content)
+}
+
+var (
+	markdownFence     = regexp.MustCompile("^\\s*(```|~~~)")
+	markdownHeading   = regexp.MustCompile(`^\s{0,3}#{1,6}\s+(.*?)(\s+#+)?\s*$`)
+	markdownRule      = regexp.MustCompile(`^\s{0,3}([-*_])(\s*[-*_]){2,}\s*$`)
+	markdownQuote     = regexp.MustCompile(`^\s{0,3}>\s?`)
+	markdownBullet    = regexp.MustCompile(`^(\s*)[-*+]\s+(\[[ xX]\]\s+)?`)
+	markdownTableRule = regexp.MustCompile(`^\s*\|?\s*:?-{3,}:?\s*(\|\s*:?-{3,}:?\s*)*\|?\s*$`)
+
+	markdownImage       = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	markdownLink        = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)(\s+"[^"]*")?\)`)
+	markdownBold        = regexp.MustCompile(`\*\*(\S(?:.*?\S)?)\*\*`)
+	markdownBoldUnder   = regexp.MustCompile(`(^|\W)__(\S(?:.*?\S)?)__(\W|$)`)
+	markdownItalic      = regexp.MustCompile(`\*(\S(?:[^*]*?\S)?)\*`)
+	markdownItalicUnder = regexp.MustCompile(`(^|\W)_(\S(?:[^_]*?\S)?)_(\W|$)`)
+	markdownStrike      = regexp.MustCompile(`~~(\S(?:.*?\S)?)~~`)
+	markdownEscape      = regexp.MustCompile(`\\([\\` + "`" + `*_{}\[\]()#+\-.!~|>])`)
+)
+
+func stripMarkdown(content string) string {
+	lines := strings.Split(content, "\n")
+	out := make([]string, 0, len(lines))
+
+	inFence := false
+	for _, line := range lines {
+		if markdownFence.MatchString(line) {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			out = append(out, line)
+			continue
+		}
+
+		if markdownRule.MatchString(line) || (markdownTableRule.MatchString(line) && strings.Contains(line, "|")) {
+			continue
+		}
+
+		for markdownQuote.MatchString(line) {
+			line = markdownQuote.ReplaceAllString(line, "")
+		}
+
+		if match := markdownHeading.FindStringSubmatch(line); match != nil {
+			line = match[1]
+		}
+
+		line = markdownBullet.ReplaceAllString(line, "$1- ")
+
+		if trimmed := strings.TrimSpace(line); strings.HasPrefix(trimmed, "|") && strings.HasSuffix(trimmed, "|") {
+			cells := strings.Split(strings.Trim(trimmed, "|"), "|")
+			for i, cell := range cells {
+				cells[i] = strings.TrimSpace(cell)
+			}
+			line = strings.Join(cells, " | ")
+		}
+
+		out = append(out, stripInlineMarkdown(line))
+	}
+
+	return strings.Join(out, "\n")
+}
+
+// stripInlineMarkdown removes emphasis and link syntax from a line, leaving
+// the contents of code spans as they are.
+func stripInlineMarkdown(line string) string {
+	parts := strings.Split(line, "`")
+	if len(parts)%2 == 0 {
+		// An unmatched backtick is literal text
+		parts[len(parts)-2] += "`" + parts[len(parts)-1]
+		parts = parts[:len(parts)-1]
+	}
+
+	for i := 0; i < len(parts); i += 2 {
+		parts[i] = stripEmphasis(parts[i])
+	}
+
+	return strings.Join(parts, "")
+}
+
+func stripEmphasis(text string) string {
+	// Hide escaped characters from the patterns below, restoring them at the end
+	escaped := map[string]string{}
+	text = markdownEscape.ReplaceAllStringFunc(text, func(match string) string {
+		placeholder := string(rune(0xE000 + len(escaped)))
+		escaped[placeholder] = match[1:]
+		return placeholder
+	})
+
+	text = markdownImage.ReplaceAllString(text, "$1")
+	text = markdownLink.ReplaceAllStringFunc(text, func(match string) string {
+		parts := markdownLink.FindStringSubmatch(match)
+		if parts[1] == parts[2] {
+			return parts[2]
+		}
+		return parts[1] + " (" + parts[2] + ")"
+	})
+	text = markdownBold.ReplaceAllString(text, "$1")
+	text = markdownBoldUnder.ReplaceAllString(text, "$1$2$3")
+	text = markdownStrike.ReplaceAllString(text, "$1")
+	text = markdownItalic.ReplaceAllString(text, "$1")
+	text = markdownItalicUnder.ReplaceAllString(text, "$1$2$3")
+
+	for placeholder, char := range escaped {
+		text = strings.ReplaceAll(text, placeholder, char)
+	}
+
+	return text
+}
@@ -0,0 +1,45 @@
+package sapiens
+
+import "testing"
+
+func TestFormatResponse(t *testing.T) {
+	markdown := "# Weather in **Paris**\n" +
+		"\n" +
+		"> It's *sunny* and ~~cold~~ __warm__.\n" +
+		"\n" +
+		"- Temperature: `21_c`\n" +
+		"* [ ] Bring [sunglasses](https://example.com/shop \"shop\")\n" +
+		"1. See ![map](map.png) or <https://example.com>\n" +
+		"\n" +
+		"---\n" +
+		"| Day | Temp |\n" +
+		"|-----|:----:|\n" +
+		"| Mon | 21 |\n" +
+		"\n" +
+		"```go\n" +
+		"x := a * b * c // **not bold**\n" +
+		"```\n" +
+		"Use snake_case_names and \\*literal stars\\*."
+
+	want := "Weather in Paris\n" +
+		"\n" +
+		"It's sunny and cold warm.\n" +
+		"\n" +
+		"- Temperature: 21_c\n" +
+		"- Bring sunglasses (https://example.com/shop)\n" +
+		"1. See map or <https://example.com>\n" +
+		"\n" +
+		"Day | Temp\n" +
+		"Mon | 21\n" +
+		"\n" +
+		"x := a * b * c // **not bold**\n" +
+		"Use snake_case_names and *literal stars*."
+
+	if got := FormatResponse(markdown, FormatPlain); got != want {
+		t.Errorf("FormatResponse(plain) =\n%s\nwant\n%s", got, want)
+	}
+
+	if got := FormatResponse(markdown, FormatMarkdown); got != markdown {
+		t.Errorf("FormatResponse(markdown) changed the content:\n%s", got)
+	}
+}
@@ -1,10 +1,15 @@
 package sapiens
 
-import openai "github.com/sashabaranov/go-openai"
+import (
+	"context"
+
+	openai "github.com/sashabaranov/go-openai"
+)
 
 const (
-	GeminiBaseUrl      = "https://generativelanguage.googleapis.com/v1beta/openai/"
-	GeminiDefaultModel = "gemini-2.0-flash"
+	GeminiBaseUrl               = "https://generativelanguage.googleapis.com/v1beta/openai/"
+	GeminiDefaultModel          = "gemini-2.0-flash"
+	GeminiDefaultEmbeddingModel = "gemini-embedding-exp-03-07"
 )
 
 type GeminiInterface struct {
@@ -12,6 +17,8 @@ type GeminiInterface struct {
 	DefaultModel string
 	OrgId        string
 	AuthToken    string
+	ExtraBody    map[string]interface{} // provider specific fields added to every chat completion request
+	Tools        []interface{}          // built-in tools, such as Google Search, added to every chat completion request
 }
 
 func NewGemini(authToken string) *GeminiInterface {
@@ -31,6 +38,8 @@ func (g *GeminiInterface) Client() *openai.Client {
 
 	client_config.BaseURL = g.BaseUrl
 
+	client_config.HTTPClient = newExtraBodyClient(g.ExtraBody, g.Tools...)
+
 	client := openai.NewClientWithConfig(client_config)
 
 	return client
@@ -40,3 +49,41 @@ func (g *GeminiInterface) Client() *openai.Client {
 func (g *GeminiInterface) GetDefaultModel() string {
 	return g.DefaultModel
 }
+
+// SetReasoningEffort sets how much the model thinks before answering:
+// "none", "low", "medium" or "high". Call it before Client.
+func (g *GeminiInterface) SetReasoningEffort(effort string) {
+	setExtraBody(&g.ExtraBody, "reasoning_effort", effort)
+}
+
+// SetThinkingBudget caps the tokens Gemini 2.5 models spend thinking. Zero
+// disables thinking where the model allows it and -1 lets the model decide.
+// It cannot be combined with SetReasoningEffort. Call it before Client.
+func (g *GeminiInterface) SetThinkingBudget(tokens int) {
+	setExtraBody(&g.ExtraBody, "extra_body", map[string]interface{}{
+		"google": map[string]interface{}{
+			"thinking_config": map[string]interface{}{
+				"thinking_budget": tokens,
+			},
+		},
+	})
+}
+
+// GetDefaultEmbeddingModel returns the model to use for embeddings; the chat
+// model returned by GetDefaultModel cannot embed text. It is informational:
+// the package has no embeddings API, the model is for use with the provider's.
+func (g *GeminiInterface) GetDefaultEmbeddingModel() string {
+	return GeminiDefaultEmbeddingModel
+}
+
+// ToolResultFormat returns how Gemini's OpenAI-compatible endpoint expects
+// tool results: user messages, as it rejects tool messages.
+func (g *GeminiInterface) ToolResultFormat() ToolResultFormat {
+	return ToolResultsAsUser
+}
+
+// Initialize validates the API key and base URL by listing the available
+// models, so bad credentials fail fast instead of on the first Ask.
+func (g *GeminiInterface) Initialize(ctx context.Context) error {
+	return initializeByListingModels(ctx, g.Client(), "Gemini")
+}
@@ -5,6 +5,8 @@ go 1.23.4
 require (
 	github.com/mark3labs/mcp-go v0.31.0
 	github.com/sashabaranov/go-openai v1.40.1
+	go.opentelemetry.io/otel v1.35.0
+	go.opentelemetry.io/otel/trace v1.35.0
 )
 
 require (
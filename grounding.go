@@ -0,0 +1,186 @@
+package sapiens
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// GroundingMetadata describes the web sources a grounded answer is based on,
+// see GeminiInterface.SetGoogleSearch.
+type GroundingMetadata struct {
+	SearchQueries []string           // the queries the model searched for
+	Sources       []GroundingSource  // the pages found
+	Supports      []GroundingSupport // the parts of the answer each source supports
+}
+
+// GroundingSource is a web page a grounded answer draws on.
+type GroundingSource struct {
+	URI   string
+	Title string
+}
+
+// GroundingSupport links a part of the answer, the bytes from StartIndex to
+// EndIndex of the content, to the Sources backing it, by index.
+type GroundingSupport struct {
+	Text       string
+	StartIndex int
+	EndIndex   int
+	Sources    []int
+}
+
+// googleSearchTool is Gemini's built-in Google Search tool.
+var googleSearchTool = map[string]interface{}{"google_search": map[string]interface{}{}}
+
+// SetGoogleSearch lets Gemini ground its answers in Google Search results. The
+// sources are returned by Agent.LastGrounding. Some models reject Google Search
+// together with function tools. Call it before Client.
+func (g *GeminiInterface) SetGoogleSearch(enabled bool) {
+	tools := g.Tools[:0:0]
+	for _, tool := range g.Tools {
+		if builtin, ok := tool.(map[string]interface{}); !ok || builtin["google_search"] == nil {
+			tools = append(tools, tool)
+		}
+	}
+	if enabled {
+		tools = append(tools, googleSearchTool)
+	}
+
+	g.Tools = tools
+}
+
+// LastGrounding returns the grounding metadata of the answer of the last
+// turn, or nil when the provider returned none. Only completions that are
+// not streamed carry it.
+func (a *Agent) LastGrounding() *GroundingMetadata {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.grounding
+}
+
+// groundingRecorder receives the grounding metadata and the citations of the
+// response to a request made with its context, see withGroundingRecorder.
+type groundingRecorder struct {
+	mu        sync.Mutex
+	metadata  *GroundingMetadata
+	citations []Citation
+}
+
+type groundingRecorderKey struct{}
+
+// withGroundingRecorder returns a context whose requests, when sent by the
+// providers' clients, record the grounding metadata and citations of their
+// response.
+func withGroundingRecorder(ctx context.Context) (context.Context, *groundingRecorder) {
+	recorder := &groundingRecorder{}
+	return context.WithValue(ctx, groundingRecorderKey{}, recorder), recorder
+}
+
+func (r *groundingRecorder) get() (*GroundingMetadata, []Citation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.metadata, r.citations
+}
+
+// noteGrounding records the grounding metadata and citations of a chat
+// completion in the recorder of the request's context. The body is restored
+// for the client.
+func noteGrounding(ctx context.Context, resp *http.Response) error {
+	recorder, ok := ctx.Value(groundingRecorderKey{}).(*groundingRecorder)
+	if !ok || resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	metadata := parseGrounding(body)
+	citations := groundingCitations(metadata)
+	if citations == nil {
+		citations = parseURLCitations(body)
+	}
+
+	recorder.mu.Lock()
+	recorder.metadata, recorder.citations = metadata, citations
+	recorder.mu.Unlock()
+
+	return nil
+}
+
+// rawGrounding is Gemini's groundingMetadata.
+type rawGrounding struct {
+	WebSearchQueries []string `json:"webSearchQueries"`
+	GroundingChunks  []struct {
+		Web struct {
+			URI   string `json:"uri"`
+			Title string `json:"title"`
+		} `json:"web"`
+	} `json:"groundingChunks"`
+	GroundingSupports []struct {
+		Segment struct {
+			StartIndex int    `json:"startIndex"`
+			EndIndex   int    `json:"endIndex"`
+			Text       string `json:"text"`
+		} `json:"segment"`
+		GroundingChunkIndices []int `json:"groundingChunkIndices"`
+	} `json:"groundingSupports"`
+}
+
+// parseGrounding extracts the grounding metadata of a chat completion body,
+// from its first choice or the completion itself.
+func parseGrounding(body []byte) *GroundingMetadata {
+	var completion struct {
+		groundingFields
+		Choices []groundingFields `json:"choices"`
+	}
+	if json.Unmarshal(body, &completion) != nil {
+		return nil
+	}
+
+	raw := completion.metadata()
+	if raw == nil && len(completion.Choices) > 0 {
+		raw = completion.Choices[0].metadata()
+	}
+	if raw == nil {
+		return nil
+	}
+
+	metadata := &GroundingMetadata{SearchQueries: raw.WebSearchQueries}
+	for _, chunk := range raw.GroundingChunks {
+		metadata.Sources = append(metadata.Sources, GroundingSource{URI: chunk.Web.URI, Title: chunk.Web.Title})
+	}
+	for _, support := range raw.GroundingSupports {
+		metadata.Supports = append(metadata.Supports, GroundingSupport{
+			Text:       support.Segment.Text,
+			StartIndex: support.Segment.StartIndex,
+			EndIndex:   support.Segment.EndIndex,
+			Sources:    support.GroundingChunkIndices,
+		})
+	}
+
+	return metadata
+}
+
+// groundingFields holds the grounding metadata under either casing.
+type groundingFields struct {
+	Snake *rawGrounding `json:"grounding_metadata"`
+	Camel *rawGrounding `json:"groundingMetadata"`
+}
+
+func (f groundingFields) metadata() *rawGrounding {
+	if f.Snake != nil {
+		return f.Snake
+	}
+
+	return f.Camel
+}
@@ -0,0 +1,90 @@
+package sapiens
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+const groundedCompletion = `{
+	"id": "1",
+	"model": "gemini-2.0-flash",
+	"choices": [{
+		"index": 0,
+		"finish_reason": "stop",
+		"message": {"role": "assistant", "content": "Spain won Euro 2024."},
+		"grounding_metadata": {
+			"webSearchQueries": ["euro 2024 winner"],
+			"groundingChunks": [{"web": {"uri": "https://uefa.com/euro", "title": "uefa.com"}}],
+			"groundingSupports": [{
+				"segment": {"startIndex": 0, "endIndex": 20, "text": "Spain won Euro 2024."},
+				"groundingChunkIndices": [0]
+			}]
+		}
+	}]
+}`
+
+func TestGeminiGoogleSearch(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		json.Unmarshal(raw, &body)
+
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, groundedCompletion)
+	}))
+	t.Cleanup(server.Close)
+
+	llm := NewGemini("key")
+	llm.BaseUrl = server.URL
+	llm.SetGoogleSearch(true)
+	llm.SetGoogleSearch(true)
+
+	agent := NewAgent(context.Background(), llm.Client(), llm.GetDefaultModel(), "")
+	if err := agent.AddTool("lookup", "lookup", nil, nil, func(map[string]string) string { return "" }); err != nil {
+		t.Fatalf("AddTool error: %v", err)
+	}
+
+	message := NewMessages()
+	response, err := agent.Ask(message.MergeMessages(message.UserMessage("who won euro 2024?")))
+	if err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+	if response.Choices[0].Message.Content != "Spain won Euro 2024." {
+		t.Errorf("content = %q", response.Choices[0].Message.Content)
+	}
+
+	tools, _ := json.Marshal(body["tools"])
+	if want := `[{"function":{"description":"lookup","name":"lookup","parameters":{"type":"object"}},"type":"function"},{"google_search":{}}]`; string(tools) != want {
+		t.Errorf("tools = %s, want %s", tools, want)
+	}
+
+	want := &GroundingMetadata{
+		SearchQueries: []string{"euro 2024 winner"},
+		Sources:       []GroundingSource{{URI: "https://uefa.com/euro", Title: "uefa.com"}},
+		Supports:      []GroundingSupport{{Text: "Spain won Euro 2024.", StartIndex: 0, EndIndex: 20, Sources: []int{0}}},
+	}
+	if got := agent.LastGrounding(); !reflect.DeepEqual(got, want) {
+		t.Errorf("LastGrounding = %+v, want %+v", got, want)
+	}
+
+	llm.SetGoogleSearch(false)
+	if len(llm.Tools) != 0 {
+		t.Errorf("Tools = %v after disabling Google Search", llm.Tools)
+	}
+
+	agent.Reset()
+	if agent.LastGrounding() != nil {
+		t.Errorf("grounding kept after Reset")
+	}
+}
+
+func TestParseGroundingWithoutMetadata(t *testing.T) {
+	if metadata := parseGrounding([]byte(`{"choices":[{"message":{"content":"hi"}}]}`)); metadata != nil {
+		t.Errorf("parseGrounding = %+v, want nil", metadata)
+	}
+}
@@ -0,0 +1,137 @@
+package sapiens
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// recordedRequest is the request as seen by the scripted server. The response
+// format is kept raw because its schema field is an interface on the client side.
+type recordedRequest struct {
+	openai.ChatCompletionRequest
+	ResponseFormat json.RawMessage `json:"response_format,omitempty"`
+}
+
+// scriptedServer is an OpenAI-compatible test server that replies with a
+// fixed sequence of chat completion responses and records the requests.
+type scriptedServer struct {
+	URL       string
+	mu        sync.Mutex
+	responses []openai.ChatCompletionResponse
+	Requests  []recordedRequest
+}
+
+func newScriptedClient(t *testing.T, responses ...openai.ChatCompletionResponse) (*openai.Client, *scriptedServer) {
+	t.Helper()
+
+	script := &scriptedServer{responses: responses}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request recordedRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		script.mu.Lock()
+		script.Requests = append(script.Requests, request)
+		if len(script.responses) == 0 {
+			script.mu.Unlock()
+			http.Error(w, `{"error":{"message":"no scripted response left"}}`, http.StatusInternalServerError)
+			return
+		}
+		response := script.responses[0]
+		script.responses = script.responses[1:]
+		script.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+	script.URL = server.URL
+
+	config := openai.DefaultConfig("test-token")
+	config.BaseURL = server.URL
+
+	return openai.NewClientWithConfig(config), script
+}
+
+// newStreamingClient starts an OpenAI-compatible test server that answers each
+// request with the next scripted stream, sent as server-sent events.
+func newStreamingClient(t *testing.T, streams ...[]openai.ChatCompletionStreamResponse) (*openai.Client, *scriptedServer) {
+	t.Helper()
+
+	script := &scriptedServer{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request recordedRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		script.mu.Lock()
+		script.Requests = append(script.Requests, request)
+		if len(streams) == 0 {
+			script.mu.Unlock()
+			http.Error(w, `{"error":{"message":"no scripted stream left"}}`, http.StatusInternalServerError)
+			return
+		}
+		chunks := streams[0]
+		streams = streams[1:]
+		script.mu.Unlock()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range chunks {
+			data, _ := json.Marshal(chunk)
+			fmt.Fprintf(w, "data: %s\n\n", data)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(server.Close)
+	script.URL = server.URL
+
+	config := openai.DefaultConfig("test-token")
+	config.BaseURL = server.URL
+
+	return openai.NewClientWithConfig(config), script
+}
+
+// contentChunks splits a streamed answer into one chunk per part.
+func contentChunks(parts ...string) []openai.ChatCompletionStreamResponse {
+	var chunks []openai.ChatCompletionStreamResponse
+	for _, part := range parts {
+		chunks = append(chunks, openai.ChatCompletionStreamResponse{
+			Choices: []openai.ChatCompletionStreamChoice{
+				{Delta: openai.ChatCompletionStreamChoiceDelta{Content: part}},
+			},
+		})
+	}
+
+	return chunks
+}
+
+func textResponse(content string) openai.ChatCompletionResponse {
+	return MockTextResponse(content)
+}
+
+func toolCallResponse(calls ...openai.ToolCall) openai.ChatCompletionResponse {
+	return MockToolCallResponse(calls...)
+}
+
+func functionCall(id, name, arguments string) openai.ToolCall {
+	return MockToolCall(id, name, arguments)
+}
+
+// mockCompleter is the in-process ChatCompleter of the tests.
+type mockCompleter = MockCompleter
+
+func newMockCompleter(responses ...openai.ChatCompletionResponse) *mockCompleter {
+	return NewMockCompleter(responses...)
+}
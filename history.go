@@ -0,0 +1,258 @@
+package sapiens
+
+import (
+	"encoding/json"
+	"fmt"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// ExportHistory encodes the conversation history as JSON. Assistant tool
+// calls and the tool results answering them keep their IDs, so the history
+// can be restored with ImportHistory and the conversation continued.
+func (a *Agent) ExportHistory() ([]byte, error) {
+	a.mu.Lock()
+	history := append([]openai.ChatCompletionMessage(nil), a.MessagesHistory...)
+	a.mu.Unlock()
+
+	return json.Marshal(history)
+}
+
+// History returns the conversation history as package Messages, so callers
+// don't depend on the go-openai message type.
+func (a *Agent) History() []Message {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	history := make([]Message, len(a.MessagesHistory))
+	for i, message := range a.MessagesHistory {
+		history[i] = MessageFromOpenAI(message)
+		history[i].Options = copyOptions(a.messageOptions[i])
+	}
+
+	return history
+}
+
+// AskMessages behaves like Ask for package Messages. Their Options stay with
+// them in the history, see History, without being sent to the model.
+func (a *Agent) AskMessages(messages []Message) (openai.ChatCompletionResponse, error) {
+	converted := make([]openai.ChatCompletionMessage, len(messages))
+	options := make([]map[string]interface{}, len(messages))
+	for i, message := range messages {
+		converted[i] = message.ToOpenAI()
+		options[i] = message.Options
+	}
+
+	a.askMu.Lock()
+	defer a.askMu.Unlock()
+
+	return a.ask(askOptions{messageOptions: options}, converted)
+}
+
+// SetMessageOptions attaches metadata, such as a source or a timestamp, to
+// the message at index in MessagesHistory, replacing any set before. It is
+// returned by History and never sent to the model. Nil options remove it.
+func (a *Agent) SetMessageOptions(index int, options map[string]interface{}) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if index < 0 || index >= len(a.MessagesHistory) {
+		return fmt.Errorf("message %d is beyond the history of %d messages", index, len(a.MessagesHistory))
+	}
+	a.setMessageOptionsLocked(index, options)
+
+	return nil
+}
+
+// setMessageOptionsLocked stores a copy of options. The caller must hold a.mu.
+func (a *Agent) setMessageOptionsLocked(index int, options map[string]interface{}) {
+	if len(options) == 0 {
+		delete(a.messageOptions, index)
+		return
+	}
+
+	if a.messageOptions == nil {
+		a.messageOptions = make(map[int]map[string]interface{})
+	}
+	a.messageOptions[index] = copyOptions(options)
+}
+
+func copyOptions(options map[string]interface{}) map[string]interface{} {
+	if options == nil {
+		return nil
+	}
+
+	copied := make(map[string]interface{}, len(options))
+	for key, value := range options {
+		copied[key] = value
+	}
+
+	return copied
+}
+
+// ImportHistory replaces the conversation history with one produced by
+// ExportHistory. Providers reject tool results that don't answer an earlier
+// tool call and tool calls left without a result, so such histories are
+// refused instead of failing on the next Ask. A turn in progress finishes
+// first.
+func (a *Agent) ImportHistory(data []byte) error {
+	var history []openai.ChatCompletionMessage
+	if err := json.Unmarshal(data, &history); err != nil {
+		return fmt.Errorf("failed to decode history: %w", err)
+	}
+
+	if err := validateToolMessages(history); err != nil {
+		return err
+	}
+
+	// Wait for the turn in progress, which still appends to the history
+	a.askMu.Lock()
+	defer a.askMu.Unlock()
+
+	a.mu.Lock()
+	a.MessagesHistory = history
+	a.messageOptions = nil
+	a.mu.Unlock()
+
+	return nil
+}
+
+// validateToolMessages checks that every tool result answers a tool call of
+// the assistant message before it and that every tool call is answered.
+func validateToolMessages(history []openai.ChatCompletionMessage) error {
+	pending := map[string]bool{}
+
+	for i, message := range history {
+		if message.Role == openai.ChatMessageRoleTool {
+			if !pending[message.ToolCallID] {
+				return fmt.Errorf("message %d: tool result for unknown tool call '%s'", i, message.ToolCallID)
+			}
+			delete(pending, message.ToolCallID)
+			continue
+		}
+
+		if len(pending) > 0 {
+			return fmt.Errorf("message %d: %d tool call(s) of the previous assistant message have no result", i, len(pending))
+		}
+
+		if message.Role == openai.ChatMessageRoleAssistant {
+			for _, toolCall := range message.ToolCalls {
+				if toolCall.ID == "" {
+					return fmt.Errorf("message %d: tool call '%s' has no ID", i, toolCall.Function.Name)
+				}
+				pending[toolCall.ID] = true
+			}
+		}
+	}
+
+	if len(pending) > 0 {
+		return fmt.Errorf("last assistant message has %d tool call(s) without a result", len(pending))
+	}
+
+	return nil
+}
+
+// CheckpointID marks a point in the conversation history to roll back to.
+type CheckpointID int
+
+// Checkpoint marks the current end of the conversation history.
+func (a *Agent) Checkpoint() CheckpointID {
+	a.askMu.Lock()
+	defer a.askMu.Unlock()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return CheckpointID(len(a.MessagesHistory))
+}
+
+// Rollback discards every message added to the history after the checkpoint,
+// undoing the turns taken since. It fails if the history has already been cut
+// shorter than the checkpoint, e.g. by an earlier rollback.
+func (a *Agent) Rollback(id CheckpointID) error {
+	a.askMu.Lock()
+	defer a.askMu.Unlock()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if id < 0 || int(id) > len(a.MessagesHistory) {
+		return fmt.Errorf("checkpoint %d is beyond the history of %d messages", id, len(a.MessagesHistory))
+	}
+
+	a.MessagesHistory = a.MessagesHistory[:id:id]
+	for index := range a.messageOptions {
+		if index >= int(id) {
+			delete(a.messageOptions, index)
+		}
+	}
+
+	return nil
+}
+
+// Reset is ResetConversation.
+func (a *Agent) Reset() {
+	a.ResetConversation()
+}
+
+// ResetConversation returns the agent to the state of a fresh agent with the
+// same configuration: the history, the tool call counters and traces, the
+// pending tool calls and the last response are cleared. Tools, schema,
+// context and settings are kept, so a pooled agent can serve a new user.
+func (a *Agent) ResetConversation() {
+	a.askMu.Lock()
+	defer a.askMu.Unlock()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.resetConversationLocked()
+}
+
+// ResetAll clears the conversation like ResetConversation and also removes
+// the tools, MCP servers, response schema, context, documents and few-shot
+// examples. Limits and other settings are kept.
+func (a *Agent) ResetAll() {
+	a.askMu.Lock()
+	defer a.askMu.Unlock()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.resetConversationLocked()
+
+	if a.McpClient != nil {
+		a.McpClient.Disconnect()
+	}
+	a.Tools = nil
+	a.McpClient = nil
+	a.McpTools = nil
+	a.StructuredResponseSchema = nil
+	a.wrappedResponseSchema = nil
+	a.responseSchemaErr = nil
+	a.contextText = ""
+	a.documents = nil
+	a.fewShotExamples = nil
+}
+
+// resetConversationLocked clears the conversation state. The caller must
+// hold a.mu.
+func (a *Agent) resetConversationLocked() {
+	a.MessagesHistory = nil
+	a.messageOptions = nil
+	a.turnMessages = nil
+	a.turnStart = 0
+	a.Request = openai.ChatCompletionRequest{}
+	a.currentDepth = 0
+	a.totalToolCalls = 0
+	a.toolCallCounts = nil
+	a.toolCallTrace = nil
+	a.toolResultTrace = nil
+	a.pendingToolCalls = nil
+	a.lastResponse = openai.ChatCompletionResponse{}
+	a.lastResponseMeta = ResponseMeta{}
+	a.reasoning = ""
+	a.grounding = nil
+	a.citations = nil
+	a.rewrittenPrompt = nil
+}
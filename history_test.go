@@ -0,0 +1,278 @@
+package sapiens
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+func TestAgentExportImportHistoryWithToolCalls(t *testing.T) {
+	messages := NewMessages()
+	toolUse := []openai.ChatCompletionMessage{
+		messages.UserMessage("what's the weather in Paris?"),
+		{
+			Role:      openai.ChatMessageRoleAssistant,
+			ToolCalls: []openai.ToolCall{functionCall("call_1", "weather", `{"city":"Paris"}`)},
+		},
+		{
+			Role:       openai.ChatMessageRoleTool,
+			Name:       "weather",
+			ToolCallID: "call_1",
+			Content:    `{"temp":21}`,
+		},
+		messages.AgentMessage("It's 21 degrees in Paris."),
+	}
+
+	original := NewAgent(context.Background(), newMockCompleter(textResponse("noted")), "test-model", "")
+	if _, err := original.Ask(toolUse); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	exported, err := original.ExportHistory()
+	if err != nil {
+		t.Fatalf("ExportHistory error: %v", err)
+	}
+
+	completer := newMockCompleter(textResponse("Bring sunglasses."))
+	restored := NewAgent(context.Background(), completer, "test-model", "")
+	if err := restored.ImportHistory(exported); err != nil {
+		t.Fatalf("ImportHistory error: %v", err)
+	}
+
+	if !reflect.DeepEqual(restored.MessagesHistory, original.MessagesHistory) {
+		t.Fatalf("restored history = %+v, want %+v", restored.MessagesHistory, original.MessagesHistory)
+	}
+
+	history := restored.History()
+	wantHistory := []Message{
+		{Role: openai.ChatMessageRoleUser, Content: "what's the weather in Paris?"},
+		{Role: openai.ChatMessageRoleAssistant, ToolCalls: []ToolCall{{ID: "call_1", Name: "weather", Arguments: `{"city":"Paris"}`}}},
+		{Role: openai.ChatMessageRoleTool, Name: "weather", ToolCallID: "call_1", Content: `{"temp":21}`},
+		{Role: openai.ChatMessageRoleAssistant, Content: "It's 21 degrees in Paris."},
+	}
+	if !reflect.DeepEqual(history, wantHistory) {
+		t.Errorf("History() = %+v, want %+v", history, wantHistory)
+	}
+
+	resp, err := restored.Ask(messages.MergeMessages(messages.UserMessage("what should I bring?")))
+	if err != nil {
+		t.Fatalf("Ask after import error: %v", err)
+	}
+	if resp.Choices[0].Message.Content != "Bring sunglasses." {
+		t.Errorf("response = %q", resp.Choices[0].Message.Content)
+	}
+
+	sent := completer.Requests[0].Messages
+	if len(sent) != len(toolUse)+1 {
+		t.Fatalf("sent %d messages, want %d", len(sent), len(toolUse)+1)
+	}
+	if got := sent[1].ToolCalls; len(got) != 1 || got[0].ID != "call_1" || got[0].Function.Arguments != `{"city":"Paris"}` {
+		t.Errorf("sent tool calls = %+v", got)
+	}
+	if sent[2].Role != openai.ChatMessageRoleTool || sent[2].ToolCallID != "call_1" {
+		t.Errorf("sent tool result = %+v", sent[2])
+	}
+}
+
+func TestAgentImportHistoryRejectsUnmatchedToolMessages(t *testing.T) {
+	tests := map[string]string{
+		"unknown tool call": `[{"role":"user","content":"hi"},{"role":"tool","tool_call_id":"call_9","content":"{}"}]`,
+		"unanswered call":   `[{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"weather","arguments":"{}"}}]},{"role":"user","content":"well?"}]`,
+		"trailing call":     `[{"role":"assistant","tool_calls":[{"id":"call_1","type":"function","function":{"name":"weather","arguments":"{}"}}]}]`,
+		"invalid JSON":      `[{"role":`,
+	}
+
+	for name, history := range tests {
+		t.Run(name, func(t *testing.T) {
+			agent := NewAgent(context.Background(), newMockCompleter(), "test-model", "")
+			if err := agent.ImportHistory([]byte(history)); err == nil {
+				t.Errorf("ImportHistory(%s) succeeded, want error", history)
+			}
+			if len(agent.MessagesHistory) != 0 {
+				t.Errorf("history changed despite the error")
+			}
+		})
+	}
+}
+
+func TestAgentImportHistoryWaitsForTurn(t *testing.T) {
+	agent := NewAgent(context.Background(), newMockCompleter(
+		toolCallResponse(functionCall("call_1", "wait", "{}")),
+		textResponse("done"),
+	), "test-model", "")
+
+	started, release := make(chan struct{}), make(chan struct{})
+	agent.AddTool("wait", "Waits", nil, nil, func(map[string]string) string {
+		close(started)
+		<-release
+		return "ok"
+	})
+
+	messages := NewMessages()
+	askErr := make(chan error, 1)
+	go func() {
+		_, err := agent.Ask(messages.MergeMessages(messages.UserMessage("hi")))
+		askErr <- err
+	}()
+	<-started
+
+	imported := make(chan error, 1)
+	go func() { imported <- agent.ImportHistory([]byte(`[{"role":"user","content":"restored"}]`)) }()
+
+	select {
+	case err := <-imported:
+		t.Fatalf("ImportHistory returned during the turn: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-askErr; err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+	if err := <-imported; err != nil {
+		t.Fatalf("ImportHistory error: %v", err)
+	}
+
+	if len(agent.MessagesHistory) != 1 || agent.MessagesHistory[0].Content != "restored" {
+		t.Errorf("history = %+v, want only the imported message", agent.MessagesHistory)
+	}
+}
+
+func TestAgentCheckpointRollback(t *testing.T) {
+	completer := newMockCompleter(textResponse("Paris"), textResponse("a banana"), textResponse("Berlin"))
+	agent := NewAgent(context.Background(), completer, "test-model", "")
+
+	messages := NewMessages()
+	if _, err := agent.Ask(messages.MergeMessages(messages.UserMessage("capital of France?"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	checkpoint := agent.Checkpoint()
+	kept := append([]openai.ChatCompletionMessage(nil), agent.MessagesHistory...)
+
+	if _, err := agent.Ask(messages.MergeMessages(messages.UserMessage("what is your favourite fruit?"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	if err := agent.Rollback(checkpoint); err != nil {
+		t.Fatalf("Rollback error: %v", err)
+	}
+	if !reflect.DeepEqual(agent.MessagesHistory, kept) {
+		t.Errorf("history after rollback = %+v, want %+v", agent.MessagesHistory, kept)
+	}
+
+	if _, err := agent.Ask(messages.MergeMessages(messages.UserMessage("capital of Germany?"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+	for _, message := range completer.Requests[2].Messages {
+		if message.Content == "what is your favourite fruit?" {
+			t.Errorf("rolled back turn was sent again: %+v", completer.Requests[2].Messages)
+		}
+	}
+
+	if err := agent.Rollback(CheckpointID(len(agent.MessagesHistory) + 1)); err == nil {
+		t.Errorf("expected an error rolling back beyond the history")
+	}
+}
+
+func TestAgentReset(t *testing.T) {
+	completer := newMockCompleter(
+		toolCallResponse(functionCall("call_1", "echo", `{"text":"hi"}`)),
+		textResponse("hi"),
+		textResponse("fresh"),
+	)
+	agent := NewAgent(context.Background(), completer, "test-model", "be brief")
+	agent.AddTool("echo", "Echo text", map[string]jsonschema.Definition{
+		"text": {Type: jsonschema.String},
+	}, []string{"text"}, func(parameters map[string]string) string {
+		return parameters["text"]
+	})
+	agent.SetContext("reference notes")
+
+	messages := NewMessages()
+	if _, err := agent.Ask(messages.MergeMessages(messages.UserMessage("echo hi"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	agent.ResetConversation()
+	if len(agent.MessagesHistory) != 0 || agent.currentDepth != 0 || agent.totalToolCalls != 0 || agent.toolCallTrace != nil || agent.toolResultTrace != nil {
+		t.Errorf("conversation state left after ResetConversation")
+	}
+	if !reflect.DeepEqual(agent.LastResponseMeta(), ResponseMeta{}) {
+		t.Errorf("last response meta left after ResetConversation")
+	}
+	if len(agent.Tools) != 1 || agent.contextText == "" {
+		t.Errorf("ResetConversation removed the configuration")
+	}
+
+	if _, err := agent.Ask(messages.MergeMessages(messages.UserMessage("hello"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+	if sent := completer.Requests[2].Messages; len(sent) != 3 || sent[2].Content != "hello" {
+		t.Errorf("request after reset = %+v, want system prompt, context and the new message", sent)
+	}
+
+	agent.ResetAll()
+	if len(agent.MessagesHistory) != 0 || len(agent.Tools) != 0 || agent.contextText != "" {
+		t.Errorf("state left after ResetAll")
+	}
+	if agent.SystemPrompt != "be brief" || agent.maxTotalToolCalls != 25 {
+		t.Errorf("ResetAll changed the settings")
+	}
+}
+
+func TestAgentMessageOptions(t *testing.T) {
+	completer := newMockCompleter(textResponse("Paris"), textResponse("Berlin"))
+	agent := NewAgent(context.Background(), completer, "test-model", "")
+
+	_, err := agent.AskMessages([]Message{{
+		Role:    openai.ChatMessageRoleUser,
+		Content: "capital of France?",
+		Options: map[string]interface{}{"source": "web"},
+	}})
+	if err != nil {
+		t.Fatalf("AskMessages error: %v", err)
+	}
+
+	var found bool
+	for _, message := range agent.History() {
+		if message.Content == "capital of France?" {
+			found = true
+			if message.Options["source"] != "web" {
+				t.Errorf("Options = %v, want source web", message.Options)
+			}
+		} else if message.Options != nil {
+			t.Errorf("unexpected Options %v on %q", message.Options, message.Content)
+		}
+	}
+	if !found {
+		t.Fatalf("user message missing from history: %+v", agent.History())
+	}
+
+	checkpoint := agent.Checkpoint()
+	if _, err := agent.Ask([]openai.ChatCompletionMessage{NewMessages().UserMessage("capital of Germany?")}); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+	last := len(agent.MessagesHistory) - 1
+	if err := agent.SetMessageOptions(last, map[string]interface{}{"id": 7}); err != nil {
+		t.Fatalf("SetMessageOptions error: %v", err)
+	}
+	if got := agent.History()[last].Options["id"]; got != 7 {
+		t.Errorf("Options id = %v, want 7", got)
+	}
+
+	if err := agent.Rollback(checkpoint); err != nil {
+		t.Fatalf("Rollback error: %v", err)
+	}
+	if len(agent.messageOptions) != 1 {
+		t.Errorf("options after rollback = %v, want only the first turn's", agent.messageOptions)
+	}
+
+	if err := agent.SetMessageOptions(len(agent.MessagesHistory), nil); err == nil {
+		t.Errorf("expected an error setting options beyond the history")
+	}
+}
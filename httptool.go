@@ -0,0 +1,255 @@
+package sapiens
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+const (
+	httpToolDefaultMaxResponseBytes = 64 * 1024
+	httpToolDefaultTimeout          = 15 * time.Second
+)
+
+type HTTPToolOptions struct {
+	// AllowedHosts restricts requests to these hosts. Entries may be exact
+	// host names or "*.example.com" wildcards. Empty allows any public host.
+	AllowedHosts []string
+	// AllowedMethods defaults to GET and POST.
+	AllowedMethods []string
+	// MaxResponseBytes caps how much of the body is read. Defaults to 64KB.
+	MaxResponseBytes int64
+	// Timeout bounds each request. Defaults to 15 seconds.
+	Timeout time.Duration
+	// AllowPrivateNetworks permits loopback, private, link-local (including
+	// cloud metadata endpoints) and other non-public addresses. Off by default.
+	AllowPrivateNetworks bool
+}
+
+type httpToolResponse struct {
+	Status    int               `json:"status"`
+	Headers   map[string]string `json:"headers"`
+	Body      string            `json:"body"`
+	Truncated bool              `json:"truncated"`
+}
+
+// NewHTTPTool returns an "http_request" tool that lets the model fetch URLs
+// within the limits configured in opts.
+func NewHTTPTool(opts HTTPToolOptions) AgentTool {
+	if opts.MaxResponseBytes <= 0 {
+		opts.MaxResponseBytes = httpToolDefaultMaxResponseBytes
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = httpToolDefaultTimeout
+	}
+	if len(opts.AllowedMethods) == 0 {
+		opts.AllowedMethods = []string{http.MethodGet, http.MethodPost}
+	}
+
+	client := newGuardedHTTPClient(opts)
+
+	return newAgentTool("http_request",
+		"Perform an HTTP request and return the status, key headers and a possibly truncated body",
+		map[string]jsonschema.Definition{
+			"url": {
+				Type:        jsonschema.String,
+				Description: "The absolute http or https URL to request",
+			},
+			"method": {
+				Type:        jsonschema.String,
+				Enum:        opts.AllowedMethods,
+				Description: "The HTTP method, defaults to GET",
+			},
+			"body": {
+				Type:        jsonschema.String,
+				Description: "Optional request body for POST requests",
+			},
+		},
+		[]string{"url"},
+		func(parameters map[string]string) string {
+			resp, err := doHTTPToolRequest(client, opts, parameters)
+			if err != nil {
+				return toolError(err)
+			}
+
+			encoded, err := json.Marshal(resp)
+			if err != nil {
+				return toolError(err)
+			}
+
+			return string(encoded)
+		},
+	)
+}
+
+func doHTTPToolRequest(client *http.Client, opts HTTPToolOptions, parameters map[string]string) (*httpToolResponse, error) {
+	method := strings.ToUpper(parameters["method"])
+	if method == "" {
+		method = http.MethodGet
+	}
+	if !containsFold(opts.AllowedMethods, method) {
+		return nil, fmt.Errorf("method %s is not allowed", method)
+	}
+
+	target, err := url.Parse(parameters["url"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	if err := checkHTTPToolURL(target, opts); err != nil {
+		return nil, err
+	}
+
+	var body io.Reader
+	if parameters["body"] != "" {
+		body = strings.NewReader(parameters["body"])
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Read one extra byte to know whether the body was cut off
+	data, err := io.ReadAll(io.LimitReader(resp.Body, opts.MaxResponseBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	result := &httpToolResponse{
+		Status:  resp.StatusCode,
+		Headers: map[string]string{},
+	}
+
+	for _, header := range []string{"Content-Type", "Content-Length", "Location", "Last-Modified"} {
+		if value := resp.Header.Get(header); value != "" {
+			result.Headers[header] = value
+		}
+	}
+
+	if int64(len(data)) > opts.MaxResponseBytes {
+		data = data[:opts.MaxResponseBytes]
+		result.Truncated = true
+	}
+	result.Body = string(data)
+
+	return result, nil
+}
+
+func checkHTTPToolURL(target *url.URL, opts HTTPToolOptions) error {
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return fmt.Errorf("scheme '%s' is not allowed", target.Scheme)
+	}
+
+	host := target.Hostname()
+	if host == "" {
+		return fmt.Errorf("url has no host")
+	}
+
+	if len(opts.AllowedHosts) > 0 && !hostAllowed(host, opts.AllowedHosts) {
+		return fmt.Errorf("host '%s' is not in the allowlist", host)
+	}
+
+	return nil
+}
+
+func hostAllowed(host string, allowed []string) bool {
+	host = strings.ToLower(host)
+
+	for _, entry := range allowed {
+		entry = strings.ToLower(entry)
+		if strings.HasPrefix(entry, "*.") {
+			if strings.HasSuffix(host, entry[1:]) {
+				return true
+			}
+			continue
+		}
+		if host == entry {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newGuardedHTTPClient builds a client that re-checks every redirect and
+// refuses to connect to non-public addresses after DNS resolution, so a
+// public host name cannot be used to reach internal services.
+func newGuardedHTTPClient(opts HTTPToolOptions) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: opts.Timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			if opts.AllowPrivateNetworks {
+				return nil
+			}
+
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			ip := net.ParseIP(host)
+			if ip == nil || !isPublicIP(ip) {
+				return fmt.Errorf("connection to non-public address %s is blocked", host)
+			}
+
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   opts.Timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return fmt.Errorf("stopped after %d redirects", len(via))
+			}
+			return checkHTTPToolURL(req.URL, opts)
+		},
+	}
+}
+
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+
+	// Carrier-grade NAT range, commonly used for internal infrastructure
+	if ip4 := ip.To4(); ip4 != nil && ip4[0] == 100 && ip4[1]&0xc0 == 64 {
+		return false
+	}
+
+	return true
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package sapiens
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTPToolBlocksPrivateNetworks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request should not reach a loopback server")
+	}))
+	defer server.Close()
+
+	tool := NewHTTPTool(HTTPToolOptions{})
+
+	out := tool.ToolFunction(map[string]string{"url": server.URL})
+	if !strings.Contains(out, "non-public address") {
+		t.Errorf("expected loopback request to be blocked, got %s", out)
+	}
+
+	out = tool.ToolFunction(map[string]string{"url": "http://169.254.169.254/latest/meta-data/"})
+	if !strings.Contains(out, "non-public address") {
+		t.Errorf("expected metadata request to be blocked, got %s", out)
+	}
+
+	out = tool.ToolFunction(map[string]string{"url": "file:///etc/passwd"})
+	if !strings.Contains(out, "scheme") {
+		t.Errorf("expected file scheme to be rejected, got %s", out)
+	}
+}
+
+func TestHTTPToolAllowlistAndTruncation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(strings.Repeat("a", 100)))
+	}))
+	defer server.Close()
+
+	tool := NewHTTPTool(HTTPToolOptions{
+		AllowedHosts:         []string{"127.0.0.1"},
+		AllowPrivateNetworks: true,
+		MaxResponseBytes:     10,
+	})
+
+	var resp httpToolResponse
+	out := tool.ToolFunction(map[string]string{"url": server.URL})
+	if err := json.Unmarshal([]byte(out), &resp); err != nil {
+		t.Fatalf("unexpected tool output: %s", out)
+	}
+
+	if resp.Status != http.StatusOK || len(resp.Body) != 10 || !resp.Truncated {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	if resp.Headers["Content-Type"] != "text/plain" {
+		t.Errorf("expected content type header, got %+v", resp.Headers)
+	}
+
+	out = tool.ToolFunction(map[string]string{"url": "http://example.com"})
+	if !strings.Contains(out, "allowlist") {
+		t.Errorf("expected host outside the allowlist to be rejected, got %s", out)
+	}
+
+	out = tool.ToolFunction(map[string]string{"url": server.URL, "method": "DELETE"})
+	if !strings.Contains(out, "not allowed") {
+		t.Errorf("expected DELETE to be rejected, got %s", out)
+	}
+}
+
+func TestHostAllowedWildcard(t *testing.T) {
+	allowed := []string{"*.example.com", "api.other.org"}
+
+	cases := map[string]bool{
+		"docs.example.com": true,
+		"example.com":      false,
+		"api.other.org":    true,
+		"evil.org":         false,
+	}
+
+	for host, want := range cases {
+		if got := hostAllowed(host, allowed); got != want {
+			t.Errorf("hostAllowed(%q) = %v, want %v", host, got, want)
+		}
+	}
+
+	if isPublicIP(net.ParseIP("100.64.0.1")) {
+		t.Error("expected carrier-grade NAT address to be non-public")
+	}
+}
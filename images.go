@@ -0,0 +1,77 @@
+package sapiens
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// ImageMessage builds a user message holding the text followed by one image
+// part per URL. Both http(s) URLs and data URLs, see ImageDataURL, work.
+func (a *Messages) ImageMessage(text string, imageURLs ...string) openai.ChatCompletionMessage {
+	parts := make([]openai.ChatMessagePart, 0, len(imageURLs)+1)
+	if text != "" {
+		parts = append(parts, openai.ChatMessagePart{
+			Type: openai.ChatMessagePartTypeText,
+			Text: text,
+		})
+	}
+
+	for _, url := range imageURLs {
+		parts = append(parts, openai.ChatMessagePart{
+			Type:     openai.ChatMessagePartTypeImageURL,
+			ImageURL: &openai.ChatMessageImageURL{URL: url},
+		})
+	}
+
+	return openai.ChatCompletionMessage{
+		Role:         openai.ChatMessageRoleUser,
+		MultiContent: parts,
+	}
+}
+
+// ImageDataURL encodes image bytes as a data URL, for sending local images
+// with ImageMessage or AskWithImages.
+func ImageDataURL(mimeType string, data []byte) string {
+	return fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(data))
+}
+
+// AskWithImages asks a question about one or more images. The images stay in
+// the conversation history, so tools called during the turn, and later
+// turns, are answered with the images still in view.
+func (a *Agent) AskWithImages(question string, imageURLs ...string) (openai.ChatCompletionResponse, error) {
+	messages := NewMessages()
+
+	return a.Ask(messages.MergeMessages(messages.ImageMessage(question, imageURLs...)))
+}
+
+// normalizeMultiContent prepares a message for the history. Its parts are
+// copied, so a caller reusing the slice can't change or duplicate the images
+// of earlier turns, and Content set next to MultiContent, which the API
+// rejects, becomes the leading text part.
+func normalizeMultiContent(message openai.ChatCompletionMessage) openai.ChatCompletionMessage {
+	if len(message.MultiContent) == 0 {
+		return message
+	}
+
+	parts := make([]openai.ChatMessagePart, 0, len(message.MultiContent)+1)
+	if message.Content != "" {
+		parts = append(parts, openai.ChatMessagePart{
+			Type: openai.ChatMessagePartTypeText,
+			Text: message.Content,
+		})
+		message.Content = ""
+	}
+
+	for _, part := range message.MultiContent {
+		if part.ImageURL != nil {
+			imageURL := *part.ImageURL
+			part.ImageURL = &imageURL
+		}
+		parts = append(parts, part)
+	}
+	message.MultiContent = parts
+
+	return message
+}
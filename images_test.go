@@ -0,0 +1,71 @@
+package sapiens
+
+import (
+	"context"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+func TestAgentAskWithImagesAcrossToolRounds(t *testing.T) {
+	client, script := newScriptedClient(t,
+		toolCallResponse(functionCall("call_1", "lookup_plant", `{"name":"monstera"}`)),
+		textResponse("It's a Monstera deliciosa; water it weekly."),
+		textResponse("Yes, it's toxic to cats."),
+	)
+
+	agent := NewAgent(context.Background(), client, "test-model", "you identify plants")
+	agent.AddTool("lookup_plant", "Look up a plant", map[string]jsonschema.Definition{
+		"name": {Type: jsonschema.String},
+	}, []string{"name"}, func(parameters map[string]string) string {
+		return `{"water":"weekly"}`
+	})
+
+	image := ImageDataURL("image/png", []byte("png"))
+	if image != "data:image/png;base64,cG5n" {
+		t.Fatalf("ImageDataURL = %q", image)
+	}
+
+	if _, err := agent.AskWithImages("identify this plant", image); err != nil {
+		t.Fatalf("AskWithImages error: %v", err)
+	}
+
+	// A follow-up that sets Content next to the parts must still be accepted
+	message := openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleUser,
+		Content: "is it safe for",
+		MultiContent: []openai.ChatMessagePart{
+			{Type: openai.ChatMessagePartTypeText, Text: "cats?"},
+		},
+	}
+	if _, err := agent.Ask([]openai.ChatCompletionMessage{message}); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	if len(script.Requests) != 3 {
+		t.Fatalf("sent %d requests, want 3", len(script.Requests))
+	}
+	for i, request := range script.Requests {
+		images := 0
+		for _, sent := range request.Messages {
+			for _, part := range sent.MultiContent {
+				if part.Type == openai.ChatMessagePartTypeImageURL {
+					images++
+					if part.ImageURL.URL != image {
+						t.Errorf("request %d: image URL = %q", i, part.ImageURL.URL)
+					}
+				}
+			}
+		}
+		if images != 1 {
+			t.Errorf("request %d carried %d images, want 1", i, images)
+		}
+	}
+
+	last := script.Requests[2].Messages
+	followUp := last[len(last)-1]
+	if followUp.Content != "" || len(followUp.MultiContent) != 2 || followUp.MultiContent[0].Text != "is it safe for" {
+		t.Errorf("follow-up sent as %+v", followUp)
+	}
+}
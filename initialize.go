@@ -0,0 +1,18 @@
+package sapiens
+
+import (
+	"context"
+	"fmt"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// initializeByListingModels checks that the endpoint is reachable and accepts
+// the credentials by listing models, which costs no tokens.
+func initializeByListingModels(ctx context.Context, client *openai.Client, provider string) error {
+	if _, err := client.ListModels(ctx); err != nil {
+		return fmt.Errorf("failed to initialize %s provider: %w", provider, err)
+	}
+
+	return nil
+}
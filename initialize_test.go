@@ -0,0 +1,74 @@
+package sapiens
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestProviderInitialize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("Authorization") != "Bearer good-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":{"message":"invalid api key"}}`))
+			return
+		}
+		if !strings.HasSuffix(r.URL.Path, "/models") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"object":"list","data":[{"id":"test-model","object":"model"}]}`))
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+
+	gemini := NewGemini("good-key")
+	gemini.BaseUrl = server.URL
+	if err := gemini.Initialize(ctx); err != nil {
+		t.Errorf("Initialize with a valid key: %v", err)
+	}
+
+	openai := NewOpenai("bad-key")
+	openai.BaseUrl = server.URL
+	if err := openai.Initialize(ctx); err == nil || !strings.Contains(err.Error(), "invalid api key") {
+		t.Errorf("Initialize with a bad key = %v, want the provider's error", err)
+	}
+
+	ollama := NewOllama("http://127.0.0.1:1/v1/", "", "llama3")
+	if err := ollama.Initialize(ctx); err == nil {
+		t.Errorf("Initialize with an unreachable base URL succeeded")
+	}
+
+	_, script := newScriptedClient(t, textResponse("p"))
+	anthropic := NewAnthropic("key")
+	anthropic.BaseUrl = script.URL
+	if err := anthropic.Initialize(ctx); err != nil {
+		t.Fatalf("Anthropic Initialize: %v", err)
+	}
+	if request := script.Requests[0]; request.MaxTokens != 1 || request.Model != AnthropicDefaultModel {
+		t.Errorf("validation request = model %q, max_tokens %d", request.Model, request.MaxTokens)
+	}
+}
+
+func TestProviderDefaultEmbeddingModel(t *testing.T) {
+	tests := []struct {
+		name string
+		llm  interface{ GetDefaultEmbeddingModel() string }
+		want string
+	}{
+		{"openai", NewOpenai("key"), "text-embedding-3-small"},
+		{"gemini", NewGemini("key"), "gemini-embedding-exp-03-07"},
+		{"ollama", NewOllama("http://localhost:11434", "", "llama3"), "nomic-embed-text"},
+		{"anthropic", NewAnthropic("key"), ""},
+	}
+
+	for _, tt := range tests {
+		if got := tt.llm.GetDefaultEmbeddingModel(); got != tt.want {
+			t.Errorf("%s: GetDefaultEmbeddingModel() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -3,6 +3,8 @@ package sapiens
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	mcp_client "github.com/mark3labs/mcp-go/client"
 	mcp_transport "github.com/mark3labs/mcp-go/client/transport"
@@ -10,15 +12,41 @@ import (
 	"github.com/sashabaranov/go-openai/jsonschema"
 )
 
+// mcpInitTimeout bounds the initialize handshake in NewMcpClient when the
+// caller's context has no deadline of its own.
+var mcpInitTimeout = 30 * time.Second
+
+// DefaultMcpMaxConcurrentCalls bounds the tool calls an McpClient runs at once
+// unless WithMaxConcurrentCalls says otherwise.
+const DefaultMcpMaxConcurrentCalls = 4
+
+// McpOption configures an McpClient created by NewMcpClient.
+type McpOption func(*McpClient)
+
+// WithMaxConcurrentCalls limits how many CallTool requests are in flight on
+// the server at once, protecting small MCP servers from a model requesting
+// many tools in parallel. Further calls wait for a free slot. Zero or less
+// removes the limit.
+func WithMaxConcurrentCalls(n int) McpOption {
+	return func(m *McpClient) {
+		m.callSlots = nil
+		if n > 0 {
+			m.callSlots = make(chan struct{}, n)
+		}
+	}
+}
+
 type McpClient struct {
 	Ctx       context.Context
 	BaseUrl   string
 	Client    *mcp_client.Client
 	Connected bool
 	Tools     []mcp.Tool
+	Redact    func(string) string // applied to tool arguments and results in debug output
+	callSlots chan struct{}       // bounds concurrent CallTool requests when set
 }
 
-func NewMcpClient(ctx context.Context, mcp_sse_url string) (*McpClient, error) {
+func NewMcpClient(ctx context.Context, mcp_sse_url string, opts ...McpOption) (*McpClient, error) {
 	fmt.Printf("DEBUG: Creating MCP client for URL: %s\n", mcp_sse_url)
 	
 	mcp_server_transport, mcp_server_transport_err := mcp_transport.NewSSE(mcp_sse_url)
@@ -31,14 +59,24 @@ func NewMcpClient(ctx context.Context, mcp_sse_url string) (*McpClient, error) {
 	fmt.Printf("DEBUG: MCP client instance created\n")
 
 	fmt.Printf("DEBUG: Starting MCP client...\n")
-	if err := mcp_client_instance.Start(context.Background()); err != nil {
+	// The SSE stream lives as long as the context passed to Start, so it
+	// gets ctx itself rather than the initialization deadline below.
+	if err := mcp_client_instance.Start(ctx); err != nil {
 		return nil, fmt.Errorf("error starting MCP client: %w", err)
 	}
 	fmt.Printf("DEBUG: MCP client started successfully\n")
 
+	initCtx := ctx
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		initCtx, cancel = context.WithTimeout(ctx, mcpInitTimeout)
+		defer cancel()
+	}
+
 	fmt.Printf("DEBUG: Initializing MCP client...\n")
-	initResp, err := mcp_client_instance.Initialize(context.Background(), mcp.InitializeRequest{})
+	initResp, err := mcp_client_instance.Initialize(initCtx, mcp.InitializeRequest{})
 	if err != nil {
+		mcp_client_instance.Close()
 		return nil, fmt.Errorf("error initializing MCP client: %w", err)
 	}
 	fmt.Printf("DEBUG: MCP client initialized successfully. Response: %+v\n", initResp)
@@ -46,19 +84,29 @@ func NewMcpClient(ctx context.Context, mcp_sse_url string) (*McpClient, error) {
 	mcpClient := &McpClient{
 		BaseUrl:   mcp_sse_url,
 		Client:    mcp_client_instance,
-		Ctx:       context.Background(),
+		Ctx:       ctx,
 		Connected: true,
+		callSlots: make(chan struct{}, DefaultMcpMaxConcurrentCalls),
+	}
+	for _, opt := range opts {
+		opt(mcpClient)
 	}
 
 	// Cache available tools
-	if err := mcpClient.refreshTools(); err != nil {
+	if err := mcpClient.refreshTools(initCtx); err != nil {
 		fmt.Printf("Warning: could not load MCP tools: %v\n", err)
 	}
 
 	return mcpClient, nil
 }
 
+// ListTools lists the server's tools using the client's context.
 func (m *McpClient) ListTools() (*mcp.ListToolsResult, error) {
+	return m.ListToolsContext(m.Ctx)
+}
+
+// ListToolsContext lists the server's tools, aborting when ctx is cancelled.
+func (m *McpClient) ListToolsContext(ctx context.Context) (*mcp.ListToolsResult, error) {
 	if !m.Connected {
 		return nil, fmt.Errorf("MCP client is not connected")
 	}
@@ -69,7 +117,7 @@ func (m *McpClient) ListTools() (*mcp.ListToolsResult, error) {
 		}, nil
 	}
 
-	listToolsResult, listToolsResultErr := m.Client.ListTools(context.Background(), mcp.ListToolsRequest{})
+	listToolsResult, listToolsResultErr := m.Client.ListTools(ctx, mcp.ListToolsRequest{})
 	if listToolsResultErr != nil {
 		m.Connected = false
 		return nil, fmt.Errorf("error listing MCP tools: %w", listToolsResultErr)
@@ -78,28 +126,160 @@ func (m *McpClient) ListTools() (*mcp.ListToolsResult, error) {
 	return listToolsResult, listToolsResultErr
 }
 
+// CallTool calls a tool using the client's context.
 func (m *McpClient) CallTool(request mcp.CallToolParams) (*mcp.CallToolResult, error) {
+	return m.CallToolContext(m.Ctx, request)
+}
+
+// CallToolContext calls a tool, aborting the call when ctx is cancelled.
+func (m *McpClient) CallToolContext(ctx context.Context, request mcp.CallToolParams) (*mcp.CallToolResult, error) {
 	if !m.Connected {
 		return nil, fmt.Errorf("MCP client is not connected")
 	}
 
-	fmt.Printf("DEBUG: Calling MCP tool '%s' with args: %+v\n", request.Name, request.Arguments)
+	if m.callSlots != nil {
+		select {
+		case m.callSlots <- struct{}{}:
+			defer func() { <-m.callSlots }()
+		case <-ctx.Done():
+			return nil, fmt.Errorf("error waiting for a free MCP call slot for tool '%s': %w", request.Name, ctx.Err())
+		}
+	}
 
-	callToolResult, callToolResultErr := m.Client.CallTool(context.Background(), mcp.CallToolRequest{
+	fmt.Printf("DEBUG: Calling MCP tool '%s' with args: %s\n", request.Name, m.redact(fmt.Sprintf("%+v", request.Arguments)))
+
+	callToolResult, callToolResultErr := m.Client.CallTool(ctx, mcp.CallToolRequest{
 		Params: request,
 	})
 
 	if callToolResultErr != nil {
-		fmt.Printf("DEBUG: MCP tool call error: %v\n", callToolResultErr)
+		fmt.Printf("DEBUG: MCP tool call error: %s\n", m.redact(callToolResultErr.Error()))
 		return nil, fmt.Errorf("error calling MCP tool '%s': %w", request.Name, callToolResultErr)
 	}
 
-	fmt.Printf("DEBUG: MCP tool call successful. Result: %+v\n", callToolResult)
+	fmt.Printf("DEBUG: MCP tool call successful. Result: %s\n", m.redact(fmt.Sprintf("%+v", callToolResult)))
 	return callToolResult, callToolResultErr
 }
 
-func (m *McpClient) refreshTools() error {
-	toolsResult, err := m.ListTools()
+// ListResources lists the server's resources using the client's context.
+func (m *McpClient) ListResources() (*mcp.ListResourcesResult, error) {
+	return m.ListResourcesContext(m.Ctx)
+}
+
+// ListResourcesContext lists the server's resources, aborting when ctx is
+// cancelled.
+func (m *McpClient) ListResourcesContext(ctx context.Context) (*mcp.ListResourcesResult, error) {
+	if !m.Connected {
+		return nil, fmt.Errorf("MCP client is not connected")
+	}
+
+	listResourcesResult, err := m.Client.ListResources(ctx, mcp.ListResourcesRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing MCP resources: %w", err)
+	}
+
+	return listResourcesResult, nil
+}
+
+// ReadResource reads a resource using the client's context.
+func (m *McpClient) ReadResource(uri string) (*mcp.ReadResourceResult, error) {
+	return m.ReadResourceContext(m.Ctx, uri)
+}
+
+// ReadResourceContext reads a resource, aborting when ctx is cancelled.
+func (m *McpClient) ReadResourceContext(ctx context.Context, uri string) (*mcp.ReadResourceResult, error) {
+	if !m.Connected {
+		return nil, fmt.Errorf("MCP client is not connected")
+	}
+
+	request := mcp.ReadResourceRequest{}
+	request.Params.URI = uri
+
+	readResourceResult, err := m.Client.ReadResource(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("error reading MCP resource '%s': %w", uri, err)
+	}
+
+	return readResourceResult, nil
+}
+
+// ListPrompts lists the server's prompt templates using the client's context.
+func (m *McpClient) ListPrompts() (*mcp.ListPromptsResult, error) {
+	return m.ListPromptsContext(m.Ctx)
+}
+
+// ListPromptsContext lists the server's prompt templates, aborting when ctx
+// is cancelled.
+func (m *McpClient) ListPromptsContext(ctx context.Context) (*mcp.ListPromptsResult, error) {
+	if !m.Connected {
+		return nil, fmt.Errorf("MCP client is not connected")
+	}
+
+	listPromptsResult, err := m.Client.ListPrompts(ctx, mcp.ListPromptsRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing MCP prompts: %w", err)
+	}
+
+	return listPromptsResult, nil
+}
+
+// GetPrompt fills in a prompt template using the client's context.
+func (m *McpClient) GetPrompt(name string, args map[string]interface{}) (*mcp.GetPromptResult, error) {
+	return m.GetPromptContext(m.Ctx, name, args)
+}
+
+// GetPromptContext fills in a prompt template with args, aborting when ctx is
+// cancelled. MCP prompt arguments are strings, so other values are formatted
+// with fmt.Sprint.
+func (m *McpClient) GetPromptContext(ctx context.Context, name string, args map[string]interface{}) (*mcp.GetPromptResult, error) {
+	if !m.Connected {
+		return nil, fmt.Errorf("MCP client is not connected")
+	}
+
+	request := mcp.GetPromptRequest{}
+	request.Params.Name = name
+	if len(args) > 0 {
+		request.Params.Arguments = make(map[string]string, len(args))
+		for key, value := range args {
+			request.Params.Arguments[key] = fmt.Sprint(value)
+		}
+	}
+
+	getPromptResult, err := m.Client.GetPrompt(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("error getting MCP prompt '%s': %w", name, err)
+	}
+
+	return getPromptResult, nil
+}
+
+// resourceText joins the text contents of a resource. Binary contents are
+// skipped; a resource without any text is an error.
+func resourceText(uri string, result *mcp.ReadResourceResult) (string, error) {
+	var texts []string
+	for _, content := range result.Contents {
+		if text, ok := mcp.AsTextResourceContents(content); ok {
+			texts = append(texts, text.Text)
+		}
+	}
+
+	if len(texts) == 0 {
+		return "", fmt.Errorf("MCP resource '%s' has no text content", uri)
+	}
+
+	return strings.Join(texts, "\n\n"), nil
+}
+
+func (m *McpClient) redact(text string) string {
+	if m.Redact == nil {
+		return text
+	}
+
+	return m.Redact(text)
+}
+
+func (m *McpClient) refreshTools(ctx context.Context) error {
+	toolsResult, err := m.ListToolsContext(ctx)
 	if err != nil {
 		return err
 	}
@@ -121,8 +301,9 @@ func (m *McpClient) IsConnected() bool {
 func (m *McpClient) Disconnect() error {
 	if m.Client != nil {
 		m.Connected = false
-		// Note: The mcp-go library doesn't seem to have a Close() method
-		// This would be where we'd close the connection if available
+		// Closing the transport ends the SSE stream and fails calls still
+		// waiting for a response
+		return m.Client.Close()
 	}
 	return nil
 }
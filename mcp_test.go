@@ -2,13 +2,19 @@ package sapiens
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	mcp_client "github.com/mark3labs/mcp-go/client"
 	mcp_transport "github.com/mark3labs/mcp-go/client/transport"
 	"github.com/mark3labs/mcp-go/mcp"
+	mcp_server "github.com/mark3labs/mcp-go/server"
 	"github.com/sashabaranov/go-openai/jsonschema"
 )
 
@@ -156,3 +162,265 @@ func TestMcpFull(t *testing.T) {
 
 	fmt.Printf("Completed testing %d tools\n", len(tools.Tools))
 }
+
+// newSilentMcpServer starts an SSE server that announces its message endpoint
+// but never answers requests sent to it. When handshake is set it answers
+// initialize and tools/list and only ignores tool calls.
+func newSilentMcpServer(t *testing.T, handshake bool) *httptest.Server {
+	t.Helper()
+
+	events := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			var request struct {
+				ID     json.RawMessage `json:"id"`
+				Method string          `json:"method"`
+			}
+			json.NewDecoder(r.Body).Decode(&request)
+
+			if handshake {
+				switch request.Method {
+				case "initialize":
+					events <- fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":{"protocolVersion":"2024-11-05","capabilities":{},"serverInfo":{"name":"silent","version":"1.0.0"}}}`, request.ID)
+				case "tools/list":
+					events <- fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":{"tools":[]}}`, request.ID)
+				}
+			}
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: endpoint\ndata: /message\n\n")
+		w.(http.Flusher).Flush()
+
+		for {
+			select {
+			case event := <-events:
+				fmt.Fprintf(w, "event: message\ndata: %s\n\n", event)
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestNewMcpClientInitTimeout(t *testing.T) {
+	server := newSilentMcpServer(t, false)
+
+	previous := mcpInitTimeout
+	mcpInitTimeout = 100 * time.Millisecond
+	defer func() { mcpInitTimeout = previous }()
+
+	started := time.Now()
+	_, err := NewMcpClient(context.Background(), server.URL+"/sse")
+	if err == nil {
+		t.Fatal("expected initialization to time out")
+	}
+
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("initialization took %s, expected the default timeout to apply", elapsed)
+	}
+}
+
+func TestNewMcpClientUsesContextDeadline(t *testing.T) {
+	server := newSilentMcpServer(t, false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	if _, err := NewMcpClient(ctx, server.URL+"/sse"); err == nil {
+		t.Fatal("expected initialization to fail once the context deadline passed")
+	}
+
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("initialization took %s, expected the context deadline to apply", elapsed)
+	}
+}
+
+func TestMcpClientCallToolHonoursContext(t *testing.T) {
+	server := newSilentMcpServer(t, true)
+
+	agentCtx, cancelAgent := context.WithCancel(context.Background())
+	defer cancelAgent()
+
+	mcpClient, err := NewMcpClient(agentCtx, server.URL+"/sse")
+	if err != nil {
+		t.Fatalf("NewMcpClient error: %v", err)
+	}
+	defer mcpClient.Client.Close()
+
+	if mcpClient.Ctx != agentCtx {
+		t.Error("expected the client to keep the context it was created with")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	_, err = mcpClient.CallToolContext(ctx, mcp.CallToolParams{Name: "slow"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the call to be aborted by the context, got %v", err)
+	}
+
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("call took %s, expected cancellation to abort it", elapsed)
+	}
+}
+
+func TestMcpClientLimitsConcurrentCalls(t *testing.T) {
+	server := newSilentMcpServer(t, true)
+
+	mcpClient, err := NewMcpClient(context.Background(), server.URL+"/sse", WithMaxConcurrentCalls(1))
+	if err != nil {
+		t.Fatalf("NewMcpClient error: %v", err)
+	}
+	defer mcpClient.Client.Close()
+
+	// The silent server never answers, so the first call holds the only slot
+	firstCtx, cancelFirst := context.WithCancel(context.Background())
+	firstDone := make(chan struct{})
+	go func() {
+		mcpClient.CallToolContext(firstCtx, mcp.CallToolParams{Name: "slow"})
+		close(firstDone)
+	}()
+
+	for deadline := time.Now().Add(5 * time.Second); len(mcpClient.callSlots) == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("first call never started")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = mcpClient.CallToolContext(ctx, mcp.CallToolParams{Name: "queued"})
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "free MCP call slot") {
+		t.Fatalf("expected the second call to wait for a slot, got %v", err)
+	}
+
+	cancelFirst()
+	<-firstDone
+	if len(mcpClient.callSlots) != 0 {
+		t.Errorf("slot not released after the call ended")
+	}
+}
+
+func TestWithMaxConcurrentCalls(t *testing.T) {
+	client := &McpClient{}
+	WithMaxConcurrentCalls(2)(client)
+	if cap(client.callSlots) != 2 {
+		t.Errorf("limit = %d, want 2", cap(client.callSlots))
+	}
+
+	WithMaxConcurrentCalls(0)(client)
+	if client.callSlots != nil {
+		t.Errorf("expected no limit")
+	}
+}
+
+// newMcpTestServer starts an MCP server with an echo tool, a text and a
+// binary resource, and a "review" prompt taking a "language" argument.
+func newMcpTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mcpServer := mcp_server.NewMCPServer("test", "1.0.0", mcp_server.WithResourceCapabilities(false, false))
+	mcpServer.AddTool(mcp.NewTool("echo", mcp.WithString("text")),
+		func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return mcp.NewToolResultText(fmt.Sprint(request.GetArguments()["text"])), nil
+		})
+	mcpServer.AddResource(mcp.NewResource("docs://handbook", "handbook", mcp.WithMIMEType("text/plain")),
+		func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+			return []mcp.ResourceContents{
+				mcp.TextResourceContents{URI: request.Params.URI, MIMEType: "text/plain", Text: "Refunds take 5 days."},
+			}, nil
+		})
+	mcpServer.AddResource(mcp.NewResource("docs://logo", "logo", mcp.WithMIMEType("image/png")),
+		func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+			return []mcp.ResourceContents{
+				mcp.BlobResourceContents{URI: request.Params.URI, MIMEType: "image/png", Blob: "iVBORw0KGgo="},
+			}, nil
+		})
+
+	mcpServer.AddPrompt(mcp.NewPrompt("review", mcp.WithArgument("language", mcp.RequiredArgument())),
+		func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+			language := request.Params.Arguments["language"]
+			return mcp.NewGetPromptResult("Code review", []mcp.PromptMessage{
+				mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent("You review "+language+" code.")),
+				mcp.NewPromptMessage(mcp.RoleAssistant, mcp.NewTextContent("Send me the "+language+" code.")),
+			}), nil
+		})
+
+	server := mcp_server.NewTestServer(mcpServer)
+	t.Cleanup(func() {
+		// Open SSE streams would keep Close waiting when a test fails early
+		server.CloseClientConnections()
+		server.Close()
+	})
+
+	return server
+}
+
+func TestMcpClientResources(t *testing.T) {
+	server := newMcpTestServer(t)
+
+	mcpClient, err := NewMcpClient(context.Background(), server.URL+"/sse")
+	if err != nil {
+		t.Fatalf("NewMcpClient error: %v", err)
+	}
+	defer mcpClient.Client.Close()
+
+	resources, err := mcpClient.ListResources()
+	if err != nil {
+		t.Fatalf("ListResources error: %v", err)
+	}
+	var uris []string
+	for _, resource := range resources.Resources {
+		uris = append(uris, resource.URI)
+	}
+	if len(uris) != 2 || !strings.Contains(strings.Join(uris, " "), "docs://handbook") {
+		t.Errorf("resources = %v", uris)
+	}
+
+	result, err := mcpClient.ReadResource("docs://handbook")
+	if err != nil {
+		t.Fatalf("ReadResource error: %v", err)
+	}
+	if text, err := resourceText("docs://handbook", result); err != nil || text != "Refunds take 5 days." {
+		t.Errorf("resource text = %q, %v", text, err)
+	}
+
+	if _, err := mcpClient.ReadResource("docs://missing"); err == nil || !strings.Contains(err.Error(), "docs://missing") {
+		t.Errorf("expected an error reading a missing resource, got %v", err)
+	}
+}
+
+func TestAgentAttachMCPResource(t *testing.T) {
+	server := newMcpTestServer(t)
+
+	agent := NewAgent(context.Background(), &mockCompleter{}, "test-model", "You are helpful")
+	if err := agent.AttachMCPResource("docs://handbook"); err == nil {
+		t.Error("expected an error without an MCP server")
+	}
+
+	if err := agent.AddMCP(server.URL+"/sse", nil); err != nil {
+		t.Fatalf("AddMCP error: %v", err)
+	}
+	defer agent.McpClient.Client.Close()
+
+	if err := agent.AttachMCPResource("docs://handbook"); err != nil {
+		t.Fatalf("AttachMCPResource error: %v", err)
+	}
+	if block := agent.contextBlockLocked(); !strings.Contains(block, "docs://handbook") || !strings.Contains(block, "Refunds take 5 days.") {
+		t.Errorf("context block = %q", block)
+	}
+
+	if err := agent.AttachMCPResource("docs://logo"); err == nil || !strings.Contains(err.Error(), "no text content") {
+		t.Errorf("expected a binary resource to be rejected, got %v", err)
+	}
+}
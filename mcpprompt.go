@@ -0,0 +1,87 @@
+package sapiens
+
+import (
+	"fmt"
+	"strings"
+
+	mcp "github.com/mark3labs/mcp-go/mcp"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// MCPPromptMessages fills in a prompt template of the MCP server added with
+// AddMCP and returns its messages, ready to be passed to Ask:
+//
+//	messages, err := agent.MCPPromptMessages("code_review", map[string]interface{}{"language": "go"})
+//	response, err := agent.Ask(messages)
+func (a *Agent) MCPPromptMessages(name string, args map[string]interface{}) ([]openai.ChatCompletionMessage, error) {
+	a.mu.Lock()
+	mcpClient, ctx := a.McpClient, a.Context
+	a.mu.Unlock()
+
+	if mcpClient == nil {
+		return nil, fmt.Errorf("no MCP server added")
+	}
+
+	result, err := mcpClient.GetPromptContext(ctx, name, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return promptMessages(name, result)
+}
+
+// SetSystemPromptFromMCP fills in a prompt template of the MCP server added
+// with AddMCP and makes the text of its messages the system prompt.
+func (a *Agent) SetSystemPromptFromMCP(name string, args map[string]interface{}) error {
+	messages, err := a.MCPPromptMessages(name, args)
+	if err != nil {
+		return err
+	}
+
+	var texts []string
+	for _, message := range messages {
+		if len(message.MultiContent) > 0 {
+			return fmt.Errorf("MCP prompt '%s' has non-text content and cannot be a system prompt", name)
+		}
+		texts = append(texts, message.Content)
+	}
+
+	a.mu.Lock()
+	a.SystemPrompt = strings.Join(texts, "\n\n")
+	a.mu.Unlock()
+
+	return nil
+}
+
+// promptMessages converts the messages of an MCP prompt. Text, images and
+// embedded text resources are supported.
+func promptMessages(name string, result *mcp.GetPromptResult) ([]openai.ChatCompletionMessage, error) {
+	messages := make([]openai.ChatCompletionMessage, 0, len(result.Messages))
+	for i, promptMessage := range result.Messages {
+		message := openai.ChatCompletionMessage{Role: openai.ChatMessageRoleUser}
+		if promptMessage.Role == mcp.RoleAssistant {
+			message.Role = openai.ChatMessageRoleAssistant
+		}
+
+		if text, ok := mcp.AsTextContent(promptMessage.Content); ok {
+			message.Content = text.Text
+		} else if image, ok := mcp.AsImageContent(promptMessage.Content); ok {
+			message.MultiContent = []openai.ChatMessagePart{{
+				Type:     openai.ChatMessagePartTypeImageURL,
+				ImageURL: &openai.ChatMessageImageURL{URL: fmt.Sprintf("data:%s;base64,%s", image.MIMEType, image.Data)},
+			}}
+		} else if resource, ok := mcp.AsEmbeddedResource(promptMessage.Content); ok {
+			text, ok := mcp.AsTextResourceContents(resource.Resource)
+			if !ok {
+				return nil, fmt.Errorf("message %d of MCP prompt '%s' embeds a binary resource", i+1, name)
+			}
+			message.Content = text.Text
+		} else {
+			return nil, fmt.Errorf("message %d of MCP prompt '%s' has unsupported content", i+1, name)
+		}
+
+		messages = append(messages, message)
+	}
+
+	return messages, nil
+}
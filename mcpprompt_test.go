@@ -0,0 +1,104 @@
+package sapiens
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestMcpClientPrompts(t *testing.T) {
+	server := newMcpTestServer(t)
+
+	mcpClient, err := NewMcpClient(context.Background(), server.URL+"/sse")
+	if err != nil {
+		t.Fatalf("NewMcpClient error: %v", err)
+	}
+	defer mcpClient.Client.Close()
+
+	prompts, err := mcpClient.ListPrompts()
+	if err != nil {
+		t.Fatalf("ListPrompts error: %v", err)
+	}
+	if len(prompts.Prompts) != 1 || prompts.Prompts[0].Name != "review" {
+		t.Errorf("prompts = %+v", prompts.Prompts)
+	}
+
+	result, err := mcpClient.GetPrompt("review", map[string]interface{}{"language": "Go"})
+	if err != nil {
+		t.Fatalf("GetPrompt error: %v", err)
+	}
+	if len(result.Messages) != 2 {
+		t.Fatalf("messages = %+v", result.Messages)
+	}
+	if text, ok := mcp.AsTextContent(result.Messages[0].Content); !ok || text.Text != "You review Go code." {
+		t.Errorf("first message = %+v", result.Messages[0].Content)
+	}
+
+	if _, err := mcpClient.GetPrompt("missing", nil); err == nil || !strings.Contains(err.Error(), "'missing'") {
+		t.Errorf("expected an error getting a missing prompt, got %v", err)
+	}
+}
+
+func TestAgentMCPPrompts(t *testing.T) {
+	server := newMcpTestServer(t)
+
+	agent := NewAgent(context.Background(), &mockCompleter{}, "test-model", "You are helpful")
+	if _, err := agent.MCPPromptMessages("review", nil); err == nil {
+		t.Error("expected an error without an MCP server")
+	}
+
+	if err := agent.AddMCP(server.URL+"/sse", nil); err != nil {
+		t.Fatalf("AddMCP error: %v", err)
+	}
+	defer agent.McpClient.Client.Close()
+
+	messages, err := agent.MCPPromptMessages("review", map[string]interface{}{"language": "Rust"})
+	if err != nil {
+		t.Fatalf("MCPPromptMessages error: %v", err)
+	}
+	if len(messages) != 2 ||
+		messages[0].Role != openai.ChatMessageRoleUser || messages[0].Content != "You review Rust code." ||
+		messages[1].Role != openai.ChatMessageRoleAssistant || messages[1].Content != "Send me the Rust code." {
+		t.Errorf("messages = %+v", messages)
+	}
+
+	if err := agent.SetSystemPromptFromMCP("review", map[string]interface{}{"language": "Go"}); err != nil {
+		t.Fatalf("SetSystemPromptFromMCP error: %v", err)
+	}
+	if agent.SystemPrompt != "You review Go code.\n\nSend me the Go code." {
+		t.Errorf("system prompt = %q", agent.SystemPrompt)
+	}
+
+	if err := agent.SetSystemPromptFromMCP("missing", nil); err == nil {
+		t.Error("expected an error for a missing prompt")
+	}
+	if agent.SystemPrompt != "You review Go code.\n\nSend me the Go code." {
+		t.Errorf("failed lookup changed the system prompt to %q", agent.SystemPrompt)
+	}
+}
+
+func TestPromptMessages(t *testing.T) {
+	result := mcp.NewGetPromptResult("", []mcp.PromptMessage{
+		mcp.NewPromptMessage(mcp.RoleUser, mcp.NewImageContent("aGk=", "image/png")),
+		mcp.NewPromptMessage(mcp.RoleUser, mcp.NewEmbeddedResource(mcp.TextResourceContents{URI: "docs://a", Text: "embedded"})),
+	})
+
+	messages, err := promptMessages("mixed", result)
+	if err != nil {
+		t.Fatalf("promptMessages error: %v", err)
+	}
+	if len(messages[0].MultiContent) != 1 || messages[0].MultiContent[0].ImageURL.URL != "data:image/png;base64,aGk=" {
+		t.Errorf("image message = %+v", messages[0])
+	}
+	if messages[1].Content != "embedded" {
+		t.Errorf("resource message = %+v", messages[1])
+	}
+
+	result.Messages = append(result.Messages, mcp.NewPromptMessage(mcp.RoleUser, mcp.NewAudioContent("aGk=", "audio/wav")))
+	if _, err := promptMessages("mixed", result); err == nil || !strings.Contains(err.Error(), "message 3") {
+		t.Errorf("expected audio to be rejected, got %v", err)
+	}
+}
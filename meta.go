@@ -0,0 +1,84 @@
+package sapiens
+
+import (
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// ResponseMeta describes the completion a provider produced: the model
+// snapshot that actually served the request, which may differ from the alias
+// asked for, and the backend configuration fingerprint.
+type ResponseMeta struct {
+	ID                string
+	Model             string
+	SystemFingerprint string
+	Created           time.Time
+	FinishReasons     []openai.FinishReason // one per choice
+}
+
+// LastResponseMeta returns the metadata of the most recent completion,
+// including the intermediate rounds of a tool-calling turn. It is zero until
+// the agent receives its first completion.
+func (a *Agent) LastResponseMeta() ResponseMeta {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	meta := a.lastResponseMeta
+	meta.FinishReasons = append([]openai.FinishReason(nil), meta.FinishReasons...)
+
+	return meta
+}
+
+// LastReasoning returns the reasoning, or thinking, the model returned
+// separately from its answers during the last turn, for providers that send
+// it as reasoning_content. The reasoning of each completion of the turn,
+// including the rounds that requested tool calls, is separated by a blank
+// line. It is empty when the provider returned none.
+func (a *Agent) LastReasoning() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.reasoning
+}
+
+// LastToolResults returns the outcome of every tool call of the last turn,
+// in order, as AskStructured does in Response.ToolResults. It gives callers
+// of Ask the raw tool output, such as the JSON a weather tool returned, next
+// to the model's answer, which may not repeat it.
+func (a *Agent) LastToolResults() []ToolResult {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return append([]ToolResult(nil), a.toolResultTrace...)
+}
+
+// appendReasoningLocked records the reasoning of a completion of the current
+// turn. The caller must hold a.mu.
+func (a *Agent) appendReasoningLocked(response openai.ChatCompletionResponse) {
+	if len(response.Choices) == 0 || response.Choices[0].Message.ReasoningContent == "" {
+		return
+	}
+
+	if a.reasoning != "" {
+		a.reasoning += "\n\n"
+	}
+	a.reasoning += response.Choices[0].Message.ReasoningContent
+}
+
+func newResponseMeta(response openai.ChatCompletionResponse) ResponseMeta {
+	meta := ResponseMeta{
+		ID:                response.ID,
+		Model:             response.Model,
+		SystemFingerprint: response.SystemFingerprint,
+	}
+	if response.Created != 0 {
+		meta.Created = time.Unix(response.Created, 0)
+	}
+
+	for _, choice := range response.Choices {
+		meta.FinishReasons = append(meta.FinishReasons, choice.FinishReason)
+	}
+
+	return meta
+}
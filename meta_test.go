@@ -0,0 +1,141 @@
+package sapiens
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestAgentLastResponseMeta(t *testing.T) {
+	first := textResponse("Paris")
+	first.ID = "chatcmpl-1"
+	first.Model = "gemini-2.0-flash-001"
+	first.SystemFingerprint = "fp_a1"
+	first.Created = 1700000000
+
+	second := textResponse("Berlin")
+	second.Model = "gemini-2.0-flash-002"
+	second.SystemFingerprint = "fp_b2"
+	second.Created = 1700000100
+	second.Choices[0].FinishReason = openai.FinishReasonStop
+
+	agent := NewAgent(context.Background(), newMockCompleter(first, second), "gemini-2.0-flash", "")
+	if meta := agent.LastResponseMeta(); !reflect.DeepEqual(meta, ResponseMeta{}) {
+		t.Errorf("meta before any completion = %+v", meta)
+	}
+
+	message := NewMessages()
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("capital of France?"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+	meta := agent.LastResponseMeta()
+	if meta.ID != "chatcmpl-1" || meta.Model != "gemini-2.0-flash-001" || meta.SystemFingerprint != "fp_a1" || !meta.Created.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("meta = %+v", meta)
+	}
+
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("capital of Germany?"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+	meta = agent.LastResponseMeta()
+	if meta.Model != "gemini-2.0-flash-002" || meta.SystemFingerprint != "fp_b2" {
+		t.Errorf("model change not reflected: %+v", meta)
+	}
+	if !reflect.DeepEqual(meta.FinishReasons, []openai.FinishReason{openai.FinishReasonStop}) {
+		t.Errorf("finish reasons = %v", meta.FinishReasons)
+	}
+}
+
+func TestAgentSetSeed(t *testing.T) {
+	answer := textResponse("4")
+	answer.SystemFingerprint = "fp_seeded"
+	completer := newMockCompleter(textResponse("4"), answer)
+
+	agent := NewAgent(context.Background(), completer, "test-model", "")
+	message := NewMessages()
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("2+2?"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+	if completer.Requests[0].Seed != nil {
+		t.Errorf("expected no seed by default, got %d", *completer.Requests[0].Seed)
+	}
+
+	agent.SetSeed(42)
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("2+2?"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+	if seed := completer.Requests[1].Seed; seed == nil || *seed != 42 {
+		t.Errorf("seed = %v, want 42", seed)
+	}
+	if fingerprint := agent.LastResponseMeta().SystemFingerprint; fingerprint != "fp_seeded" {
+		t.Errorf("system fingerprint = %q", fingerprint)
+	}
+}
+
+func TestAgentLastReasoning(t *testing.T) {
+	toolRound := toolCallResponse(functionCall("call_1", "weather", `{"city":"Paris"}`))
+	toolRound.Choices[0].Message.ReasoningContent = "The user wants current weather, so I need the weather tool."
+	answer := textResponse("Sunny.")
+	answer.Choices[0].Message.ReasoningContent = "The tool says sunny."
+
+	agent := NewAgent(context.Background(), newMockCompleter(toolRound, answer, textResponse("Hi!")), "test-model", "")
+	agent.AddTool("weather", "Get the weather", nil, nil, func(parameters map[string]string) string {
+		return `{"sky":"sunny"}`
+	})
+
+	message := NewMessages()
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("weather in Paris?"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+	want := "The user wants current weather, so I need the weather tool.\n\nThe tool says sunny."
+	if got := agent.LastReasoning(); got != want {
+		t.Errorf("LastReasoning = %q, want %q", got, want)
+	}
+
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("hello"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+	if got := agent.LastReasoning(); got != "" {
+		t.Errorf("reasoning of the previous turn kept: %q", got)
+	}
+}
+
+func TestAgentLastToolResults(t *testing.T) {
+	completer := newMockCompleter(
+		toolCallResponse(functionCall("call_1", "weather", `{"city":"Paris"}`)),
+		textResponse("It's sunny and 21 degrees in Paris."),
+		textResponse("Hello!"),
+	)
+
+	agent := NewAgent(context.Background(), completer, "test-model", "")
+	agent.AddTool("weather", "Get the weather", nil, nil, func(parameters map[string]string) string {
+		return `{"sky":"sunny","celsius":21}`
+	})
+
+	message := NewMessages()
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("weather in Paris?"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	results := agent.LastToolResults()
+	if len(results) != 1 || results[0].ToolCallID != "call_1" || results[0].Name != "weather" || results[0].Result != `{"sky":"sunny","celsius":21}` {
+		t.Fatalf("tool results = %+v", results)
+	}
+	if want := map[string]interface{}{"sky": "sunny", "celsius": float64(21)}; !reflect.DeepEqual(results[0].Parsed, want) {
+		t.Errorf("parsed = %#v", results[0].Parsed)
+	}
+
+	results[0].Result = "changed"
+	if agent.LastToolResults()[0].Result == "changed" {
+		t.Error("LastToolResults returned the agent's own slice")
+	}
+
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("hi"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+	if results := agent.LastToolResults(); len(results) != 0 {
+		t.Errorf("tool results of a turn without tools = %+v", results)
+	}
+}
@@ -0,0 +1,150 @@
+package sapiens
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// MetricsCollector receives measurements from the Agent. Implementations must
+// be safe for concurrent use. Adapting it to Prometheus or another metrics
+// system only requires forwarding these two calls.
+type MetricsCollector interface {
+	// ObserveCompletion is called after every chat completion request,
+	// including each round of a tool-call loop.
+	ObserveCompletion(model string, latency time.Duration, usage openai.Usage, err error)
+	// ObserveToolCall is called after every regular or MCP tool execution.
+	ObserveToolCall(name string, latency time.Duration, err error)
+}
+
+// NoopMetrics discards all measurements. It is the Agent default.
+type NoopMetrics struct{}
+
+func (NoopMetrics) ObserveCompletion(string, time.Duration, openai.Usage, error) {}
+
+func (NoopMetrics) ObserveToolCall(string, time.Duration, error) {}
+
+// DefaultLatencyBuckets are the upper bounds, in seconds, of the latency histogram.
+var DefaultLatencyBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+type MetricsSnapshot struct {
+	Requests         int64
+	Errors           map[string]int64
+	LatencyBuckets   []float64
+	LatencyCounts    []int64 // cumulative count per bucket, with a final +Inf bucket
+	LatencySum       time.Duration
+	PromptTokens     int64
+	CompletionTokens int64
+	ToolCalls        map[string]int64
+	ToolErrors       map[string]int64
+}
+
+// InMemoryMetrics is a simple MetricsCollector keeping counters in memory.
+type InMemoryMetrics struct {
+	mu               sync.Mutex
+	requests         int64
+	errors           map[string]int64
+	latencyCounts    []int64
+	latencySum       time.Duration
+	promptTokens     int64
+	completionTokens int64
+	toolCalls        map[string]int64
+	toolErrors       map[string]int64
+}
+
+func NewInMemoryMetrics() *InMemoryMetrics {
+	return &InMemoryMetrics{
+		errors:        make(map[string]int64),
+		latencyCounts: make([]int64, len(DefaultLatencyBuckets)+1),
+		toolCalls:     make(map[string]int64),
+		toolErrors:    make(map[string]int64),
+	}
+}
+
+func (m *InMemoryMetrics) ObserveCompletion(model string, latency time.Duration, usage openai.Usage, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests++
+	m.latencySum += latency
+	m.promptTokens += int64(usage.PromptTokens)
+	m.completionTokens += int64(usage.CompletionTokens)
+
+	seconds := latency.Seconds()
+	for i, bound := range DefaultLatencyBuckets {
+		if seconds <= bound {
+			m.latencyCounts[i]++
+		}
+	}
+	m.latencyCounts[len(DefaultLatencyBuckets)]++
+
+	if err != nil {
+		m.errors[ErrorType(err)]++
+	}
+}
+
+func (m *InMemoryMetrics) ObserveToolCall(name string, latency time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.toolCalls[name]++
+	if err != nil {
+		m.toolErrors[name]++
+	}
+}
+
+// Snapshot returns a copy of the current counters.
+func (m *InMemoryMetrics) Snapshot() MetricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snapshot := MetricsSnapshot{
+		Requests:         m.requests,
+		Errors:           make(map[string]int64, len(m.errors)),
+		LatencyBuckets:   append([]float64(nil), DefaultLatencyBuckets...),
+		LatencyCounts:    append([]int64(nil), m.latencyCounts...),
+		LatencySum:       m.latencySum,
+		PromptTokens:     m.promptTokens,
+		CompletionTokens: m.completionTokens,
+		ToolCalls:        make(map[string]int64, len(m.toolCalls)),
+		ToolErrors:       make(map[string]int64, len(m.toolErrors)),
+	}
+
+	for k, v := range m.errors {
+		snapshot.Errors[k] = v
+	}
+	for k, v := range m.toolCalls {
+		snapshot.ToolCalls[k] = v
+	}
+	for k, v := range m.toolErrors {
+		snapshot.ToolErrors[k] = v
+	}
+
+	return snapshot
+}
+
+// ErrorType classifies an error into a short label suitable for a metric
+// dimension, such as "timeout", "canceled" or "http_429".
+func ErrorType(err error) string {
+	var apiErr *openai.APIError
+	var reqErr *openai.RequestError
+
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.As(err, &apiErr):
+		return fmt.Sprintf("http_%d", apiErr.HTTPStatusCode)
+	case errors.As(err, &reqErr):
+		return fmt.Sprintf("http_%d", reqErr.HTTPStatusCode)
+	}
+
+	return "other"
+}
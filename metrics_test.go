@@ -0,0 +1,69 @@
+package sapiens
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+func TestAgentMetrics(t *testing.T) {
+	first := toolCallResponse(functionCall("call_1", "echo", `{"text":"hi"}`))
+	first.Usage = openai.Usage{PromptTokens: 10, CompletionTokens: 5}
+	second := textResponse("done")
+	second.Usage = openai.Usage{PromptTokens: 20, CompletionTokens: 7}
+
+	agent := NewAgent(context.Background(), newMockCompleter(first, second), "test-model", "you are a test agent")
+	agent.AddTool("echo", "Echo text", map[string]jsonschema.Definition{
+		"text": {Type: jsonschema.String},
+	}, []string{"text"}, func(parameters map[string]string) string {
+		return parameters["text"]
+	})
+
+	metrics := NewInMemoryMetrics()
+	agent.SetMetricsCollector(metrics)
+
+	message := NewMessages()
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("echo hi"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	// The mock has no responses left, so this request fails
+	agent.Ask(message.MergeMessages(message.UserMessage("again")))
+
+	snapshot := metrics.Snapshot()
+
+	if snapshot.Requests != 3 {
+		t.Errorf("expected 3 requests, got %d", snapshot.Requests)
+	}
+	if snapshot.Errors["other"] != 1 {
+		t.Errorf("expected 1 error, got %+v", snapshot.Errors)
+	}
+	if snapshot.PromptTokens != 30 || snapshot.CompletionTokens != 12 {
+		t.Errorf("unexpected token counts: %d/%d", snapshot.PromptTokens, snapshot.CompletionTokens)
+	}
+	if snapshot.ToolCalls["echo"] != 1 {
+		t.Errorf("expected 1 echo tool call, got %+v", snapshot.ToolCalls)
+	}
+	if last := snapshot.LatencyCounts[len(snapshot.LatencyCounts)-1]; last != 3 {
+		t.Errorf("expected 3 observations in the +Inf bucket, got %d", last)
+	}
+}
+
+func TestErrorType(t *testing.T) {
+	cases := map[string]error{
+		"timeout":  context.DeadlineExceeded,
+		"canceled": context.Canceled,
+		"http_429": &openai.APIError{HTTPStatusCode: 429},
+		"http_503": &openai.RequestError{HTTPStatusCode: 503, Err: errors.New("unavailable")},
+		"other":    errors.New("boom"),
+	}
+
+	for want, err := range cases {
+		if got := ErrorType(err); got != want {
+			t.Errorf("ErrorType(%v) = %q, want %q", err, got, want)
+		}
+	}
+}
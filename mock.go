@@ -0,0 +1,176 @@
+package sapiens
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// MockCompleter is a ChatCompleter answering from a script instead of a
+// provider, so agents, their tool-call loop included, can be tested offline
+// and deterministically:
+//
+//	completer := NewMockCompleter(
+//		MockToolCallResponse(MockToolCall("call_1", "weather", `{"city":"Paris"}`)),
+//		MockTextResponse("It's sunny in Paris."),
+//	)
+//	agent := NewAgent(ctx, completer, "test-model", "")
+//
+// A request is answered by the first rule registered with When that matches
+// it, otherwise by the next queued reply. Running out of replies is an error.
+type MockCompleter struct {
+	mu       sync.Mutex
+	queue    []mockReply
+	rules    []mockRule
+	Requests []openai.ChatCompletionRequest // every request received, in order
+}
+
+type mockReply struct {
+	response openai.ChatCompletionResponse
+	err      error
+}
+
+type mockRule struct {
+	match func(openai.ChatCompletionRequest) bool
+	reply mockReply
+}
+
+// NewMockCompleter returns a MockCompleter answering with the responses, in
+// order.
+func NewMockCompleter(responses ...openai.ChatCompletionResponse) *MockCompleter {
+	return (&MockCompleter{}).Enqueue(responses...)
+}
+
+// Enqueue adds responses to the end of the queue.
+func (m *MockCompleter) Enqueue(responses ...openai.ChatCompletionResponse) *MockCompleter {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, response := range responses {
+		m.queue = append(m.queue, mockReply{response: response})
+	}
+
+	return m
+}
+
+// EnqueueError adds a failing reply, e.g. an *openai.APIError with a status
+// code, to the end of the queue.
+func (m *MockCompleter) EnqueueError(err error) *MockCompleter {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.queue = append(m.queue, mockReply{err: err})
+
+	return m
+}
+
+// When answers every request matching match with response, ahead of the
+// queue. Rules are tried in the order they were added.
+func (m *MockCompleter) When(match func(request openai.ChatCompletionRequest) bool, response openai.ChatCompletionResponse) *MockCompleter {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.rules = append(m.rules, mockRule{match: match, reply: mockReply{response: response}})
+
+	return m
+}
+
+// Remaining returns how many queued replies are left, to check that a test
+// used its whole script.
+func (m *MockCompleter) Remaining() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return len(m.queue)
+}
+
+// CreateChatCompletion records the request and returns the scripted reply.
+func (m *MockCompleter) CreateChatCompletion(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Requests = append(m.Requests, request)
+
+	if err := ctx.Err(); err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+
+	for _, rule := range m.rules {
+		if rule.match(request) {
+			return rule.reply.response, rule.reply.err
+		}
+	}
+
+	if len(m.queue) == 0 {
+		return openai.ChatCompletionResponse{}, errors.New("no scripted response left")
+	}
+
+	reply := m.queue[0]
+	m.queue = m.queue[1:]
+
+	return reply.response, reply.err
+}
+
+// CreateChatCompletionStream fails: streaming needs a server, see the
+// OpenAI-compatible test servers in the package tests.
+func (m *MockCompleter) CreateChatCompletionStream(ctx context.Context, request openai.ChatCompletionRequest) (*openai.ChatCompletionStream, error) {
+	return nil, errors.New("streaming is not supported by MockCompleter")
+}
+
+// LastUserMessageContains matches requests whose last user message contains
+// text, for use with When.
+func LastUserMessageContains(text string) func(openai.ChatCompletionRequest) bool {
+	return func(request openai.ChatCompletionRequest) bool {
+		for i := len(request.Messages) - 1; i >= 0; i-- {
+			if request.Messages[i].Role == openai.ChatMessageRoleUser {
+				return strings.Contains(request.Messages[i].Content, text)
+			}
+		}
+		return false
+	}
+}
+
+// MockTextResponse builds a completion answering with content.
+func MockTextResponse(content string) openai.ChatCompletionResponse {
+	return openai.ChatCompletionResponse{
+		Choices: []openai.ChatCompletionChoice{
+			{
+				Message: openai.ChatCompletionMessage{
+					Role:    openai.ChatMessageRoleAssistant,
+					Content: content,
+				},
+				FinishReason: openai.FinishReasonStop,
+			},
+		},
+	}
+}
+
+// MockToolCallResponse builds a completion requesting the tool calls.
+func MockToolCallResponse(calls ...openai.ToolCall) openai.ChatCompletionResponse {
+	return openai.ChatCompletionResponse{
+		Choices: []openai.ChatCompletionChoice{
+			{
+				Message: openai.ChatCompletionMessage{
+					Role:      openai.ChatMessageRoleAssistant,
+					ToolCalls: calls,
+				},
+				FinishReason: openai.FinishReasonToolCalls,
+			},
+		},
+	}
+}
+
+// MockToolCall builds a call of the named tool with JSON arguments.
+func MockToolCall(id, name, arguments string) openai.ToolCall {
+	return openai.ToolCall{
+		ID:   id,
+		Type: openai.ToolTypeFunction,
+		Function: openai.FunctionCall{
+			Name:      name,
+			Arguments: arguments,
+		},
+	}
+}
@@ -0,0 +1,62 @@
+package sapiens
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestMockCompleter(t *testing.T) {
+	completer := NewMockCompleter(
+		MockToolCallResponse(MockToolCall("call_1", "weather", `{"city":"Paris"}`)),
+		MockTextResponse("It's sunny in Paris."),
+	).When(LastUserMessageContains("hello"), MockTextResponse("Hi there!"))
+
+	agent := NewAgent(context.Background(), completer, "test-model", "")
+	agent.AddTool("weather", "Get the weather", nil, nil, func(parameters map[string]string) string {
+		return `{"sky":"sunny"}`
+	})
+
+	message := NewMessages()
+	var answers []string
+	for _, prompt := range []string{"hello", "weather in Paris?", "hello again"} {
+		resp, err := agent.Ask(message.MergeMessages(message.UserMessage(prompt)))
+		if err != nil {
+			t.Fatalf("Ask(%q) error: %v", prompt, err)
+		}
+		answers = append(answers, resp.Choices[0].Message.Content)
+	}
+
+	if completer.Remaining() != 0 || len(completer.Requests) != 4 {
+		t.Errorf("remaining = %d, requests = %d", completer.Remaining(), len(completer.Requests))
+	}
+	if want := []string{"Hi there!", "It's sunny in Paris.", "Hi there!"}; !reflect.DeepEqual(answers, want) {
+		t.Errorf("answers = %q, want %q", answers, want)
+	}
+
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("more?"))); err == nil {
+		t.Error("expected an error once the script is used up")
+	}
+
+	completer.EnqueueError(&openai.APIError{HTTPStatusCode: http.StatusTooManyRequests})
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("more?"))); !isRateLimited(err) {
+		t.Errorf("expected the scripted 429, got %v", err)
+	}
+}
+
+func TestMockCompleterHonoursContext(t *testing.T) {
+	completer := NewMockCompleter(MockTextResponse("unused"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := completer.CreateChatCompletion(ctx, openai.ChatCompletionRequest{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if completer.Remaining() != 1 {
+		t.Error("a cancelled request consumed a reply")
+	}
+}
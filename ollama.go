@@ -1,10 +1,16 @@
 package sapiens
 
-import openai "github.com/sashabaranov/go-openai"
+import (
+	"context"
+
+	openai "github.com/sashabaranov/go-openai"
+)
 
 const (
-	OllamaBaseUrl      = ""
-	OllamaDefaultModel = ""
+	OllamaBaseUrl               = ""
+	OllamaDefaultModel          = ""
+	OllamaLocalBaseUrl          = "http://localhost:11434/v1/" // OpenAI-compatible endpoint of a local Ollama server
+	OllamaDefaultEmbeddingModel = "nomic-embed-text"
 )
 
 type OllamaInterface struct {
@@ -30,6 +36,7 @@ func (g *OllamaInterface) Client() *openai.Client {
 	client_config := openai.DefaultConfig(g.AuthToken)
 
 	client_config.BaseURL = g.BaseUrl
+	client_config.HTTPClient = newExtraBodyClient(nil)
 
 	client := openai.NewClientWithConfig(client_config)
 
@@ -40,3 +47,23 @@ func (g *OllamaInterface) Client() *openai.Client {
 func (g *OllamaInterface) GetDefaultModel() string {
 	return g.DefaultModel
 }
+
+// GetDefaultEmbeddingModel returns the embedding model usually pulled on
+// Ollama servers; the chat model returned by GetDefaultModel cannot embed text.
+// It is informational: the package has no embeddings API, the model is for
+// use with the server's.
+func (g *OllamaInterface) GetDefaultEmbeddingModel() string {
+	return OllamaDefaultEmbeddingModel
+}
+
+// ToolResultFormat returns how Ollama expects tool results: tool messages
+// answering the calls by ID.
+func (g *OllamaInterface) ToolResultFormat() ToolResultFormat {
+	return ToolResultsAsTool
+}
+
+// Initialize validates the API key and base URL by listing the available
+// models, so bad credentials fail fast instead of on the first Ask.
+func (g *OllamaInterface) Initialize(ctx context.Context) error {
+	return initializeByListingModels(ctx, g.Client(), "Ollama")
+}
@@ -1,9 +1,14 @@
 package sapiens
 
-import openai "github.com/sashabaranov/go-openai"
+import (
+	"context"
+
+	openai "github.com/sashabaranov/go-openai"
+)
 
 const (
-	OpenaiDefaultModel = "gpt-4.1-2025-04-14"
+	OpenaiDefaultModel          = "gpt-4.1-2025-04-14"
+	OpenaiDefaultEmbeddingModel = "text-embedding-3-small"
 )
 
 type OpenaiInterface struct {
@@ -11,6 +16,7 @@ type OpenaiInterface struct {
 	DefaultModel string
 	OrgId        string
 	AuthToken    string
+	ExtraBody    map[string]interface{} // provider specific fields added to every chat completion request
 }
 
 func NewOpenai(authToken string) *OpenaiInterface {
@@ -27,7 +33,13 @@ func (g *OpenaiInterface) Client() *openai.Client {
 
 	client_config := openai.DefaultConfig(g.AuthToken)
 
-	client_config.BaseURL = g.BaseUrl
+	// Keep the official endpoint unless a custom one is set
+	if g.BaseUrl != "" {
+		client_config.BaseURL = g.BaseUrl
+	}
+
+	// Always installed, as it also sends the file parts of FileMessage
+	client_config.HTTPClient = newExtraBodyClient(g.ExtraBody)
 
 	client := openai.NewClientWithConfig(client_config)
 
@@ -38,3 +50,28 @@ func (g *OpenaiInterface) Client() *openai.Client {
 func (g *OpenaiInterface) GetDefaultModel() string {
 	return g.DefaultModel
 }
+
+// SetReasoningEffort sets how much o-series models reason before answering:
+// "low", "medium" or "high". Call it before Client.
+func (g *OpenaiInterface) SetReasoningEffort(effort string) {
+	setExtraBody(&g.ExtraBody, "reasoning_effort", effort)
+}
+
+// GetDefaultEmbeddingModel returns the model to use for embeddings; the chat
+// model returned by GetDefaultModel cannot embed text. It is informational:
+// the package has no embeddings API, the model is for use with the provider's.
+func (g *OpenaiInterface) GetDefaultEmbeddingModel() string {
+	return OpenaiDefaultEmbeddingModel
+}
+
+// ToolResultFormat returns how OpenAI expects tool results: tool messages
+// answering the calls by ID.
+func (g *OpenaiInterface) ToolResultFormat() ToolResultFormat {
+	return ToolResultsAsTool
+}
+
+// Initialize validates the API key and base URL by listing the available
+// models, so bad credentials fail fast instead of on the first Ask.
+func (g *OpenaiInterface) Initialize(ctx context.Context) error {
+	return initializeByListingModels(ctx, g.Client(), "OpenAI")
+}
@@ -0,0 +1,132 @@
+package sapiens
+
+import (
+	"strings"
+	"sync"
+)
+
+// SystemPromptBuilder composes a system prompt from named sections, such as
+// role, rules and output format, that are edited and toggled independently.
+// Sections are rendered in the order they were first set, separated by a
+// blank line.
+type SystemPromptBuilder struct {
+	mu       sync.Mutex
+	agent    *Agent // receives the prompt on Build, if set
+	sections []promptSection
+}
+
+type promptSection struct {
+	name     string
+	text     string
+	disabled bool
+}
+
+// NewSystemPromptBuilder returns a builder that is not bound to an agent.
+func NewSystemPromptBuilder() *SystemPromptBuilder {
+	return &SystemPromptBuilder{}
+}
+
+// SystemPromptBuilder returns the agent's builder. Build makes its rendering
+// the agent's system prompt; sections persist between calls, so a later
+// toggle and Build updates the prompt for the following turns.
+func (a *Agent) SystemPromptBuilder() *SystemPromptBuilder {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.promptBuilder == nil {
+		a.promptBuilder = &SystemPromptBuilder{agent: a}
+	}
+
+	return a.promptBuilder
+}
+
+// SetSection sets the text of a section, adding an enabled section at the
+// end when there is none with that name.
+func (b *SystemPromptBuilder) SetSection(name, text string) *SystemPromptBuilder {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if section := b.sectionLocked(name); section != nil {
+		section.text = text
+		return b
+	}
+	b.sections = append(b.sections, promptSection{name: name, text: text})
+
+	return b
+}
+
+// EnableSection includes a section in the prompt again.
+func (b *SystemPromptBuilder) EnableSection(name string) *SystemPromptBuilder {
+	return b.setEnabled(name, true)
+}
+
+// DisableSection leaves a section out of the prompt, keeping its text and
+// position for when it is enabled again.
+func (b *SystemPromptBuilder) DisableSection(name string) *SystemPromptBuilder {
+	return b.setEnabled(name, false)
+}
+
+// RemoveSection deletes a section.
+func (b *SystemPromptBuilder) RemoveSection(name string) *SystemPromptBuilder {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i := range b.sections {
+		if b.sections[i].name == name {
+			b.sections = append(b.sections[:i:i], b.sections[i+1:]...)
+			break
+		}
+	}
+
+	return b
+}
+
+// Render returns the text of the enabled, non-empty sections.
+func (b *SystemPromptBuilder) Render() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var texts []string
+	for _, section := range b.sections {
+		if !section.disabled && section.text != "" {
+			texts = append(texts, section.text)
+		}
+	}
+
+	return strings.Join(texts, "\n\n")
+}
+
+// Build renders the prompt and, for an agent's builder, makes it the agent's
+// system prompt.
+func (b *SystemPromptBuilder) Build() string {
+	prompt := b.Render()
+
+	if b.agent != nil {
+		b.agent.mu.Lock()
+		b.agent.SystemPrompt = prompt
+		b.agent.mu.Unlock()
+	}
+
+	return prompt
+}
+
+func (b *SystemPromptBuilder) setEnabled(name string, enabled bool) *SystemPromptBuilder {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if section := b.sectionLocked(name); section != nil {
+		section.disabled = !enabled
+	}
+
+	return b
+}
+
+func (b *SystemPromptBuilder) sectionLocked(name string) *promptSection {
+	for i := range b.sections {
+		if b.sections[i].name == name {
+			return &b.sections[i]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package sapiens
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSystemPromptBuilder(t *testing.T) {
+	builder := NewSystemPromptBuilder().
+		SetSection("role", "You are a support agent.").
+		SetSection("rules", "Never share internal notes.").
+		SetSection("output", "Answer in JSON.")
+
+	if got, want := builder.Render(), "You are a support agent.\n\nNever share internal notes.\n\nAnswer in JSON."; got != want {
+		t.Errorf("Render = %q, want %q", got, want)
+	}
+
+	builder.DisableSection("output").SetSection("rules", "Be brief.")
+	if got, want := builder.Build(), "You are a support agent.\n\nBe brief."; got != want {
+		t.Errorf("Build = %q, want %q", got, want)
+	}
+
+	builder.EnableSection("output").RemoveSection("role").DisableSection("missing")
+	if got, want := builder.Render(), "Be brief.\n\nAnswer in JSON."; got != want {
+		t.Errorf("Render = %q, want %q", got, want)
+	}
+}
+
+func TestAgentSystemPromptBuilder(t *testing.T) {
+	completer := newMockCompleter(textResponse("{}"), textResponse("ok"))
+	agent := NewAgent(context.Background(), completer, "test-model", "")
+
+	if agent.SystemPromptBuilder() != agent.SystemPromptBuilder() {
+		t.Fatal("expected the agent to keep its builder")
+	}
+
+	agent.SystemPromptBuilder().
+		SetSection("role", "You are a support agent.").
+		SetSection("output", "Answer in JSON.").
+		Build()
+
+	message := NewMessages()
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("hi"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+	if got := completer.Requests[0].Messages[0].Content; got != "You are a support agent.\n\nAnswer in JSON." {
+		t.Errorf("system prompt sent = %q", got)
+	}
+
+	agent.SystemPromptBuilder().DisableSection("output").Build()
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("hi"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+	// Each turn records the system prompt it was sent with ahead of its prompt
+	messages := completer.Requests[1].Messages
+	if got := messages[len(messages)-2].Content; got != "You are a support agent." {
+		t.Errorf("system prompt sent after disabling a section = %q", got)
+	}
+}
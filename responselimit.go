@@ -0,0 +1,70 @@
+package sapiens
+
+import (
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// SetMaxResponseLength caps the content of every answer at chars characters,
+// as a backstop for models that ignore max_tokens. Longer content is cut and
+// its choice gets FinishReason "length", which AskStructured reports as
+// Response.Truncated. Streamed content is cut at the same point. Zero
+// disables the cap.
+func (a *Agent) SetMaxResponseLength(chars int) {
+	a.mu.Lock()
+	a.maxResponseLength = chars
+	a.mu.Unlock()
+}
+
+// truncateResponse cuts the content of each choice to limit characters and
+// marks the choices it cut.
+func truncateResponse(response *openai.ChatCompletionResponse, limit int) {
+	if limit <= 0 {
+		return
+	}
+
+	for i := range response.Choices {
+		if content, cut := truncateRunes(response.Choices[i].Message.Content, limit); cut {
+			response.Choices[i].Message.Content = content
+			response.Choices[i].FinishReason = openai.FinishReasonLength
+		}
+	}
+}
+
+func truncateRunes(text string, limit int) (string, bool) {
+	count := 0
+	for i := range text {
+		if count == limit {
+			return text[:i], true
+		}
+		count++
+	}
+
+	return text, false
+}
+
+// capContentHandler passes on streamed content only up to limit characters
+// per completion.
+func capContentHandler(onContent contentHandler, limit int) contentHandler {
+	if onContent == nil || limit <= 0 {
+		return onContent
+	}
+
+	var passed string
+	return func(_, content string) {
+		capped, _ := truncateRunes(content, limit)
+
+		// A new completion, after tool calls, starts its content afresh
+		if !strings.HasPrefix(capped, passed) {
+			passed = ""
+		}
+		if len(capped) == len(passed) {
+			return
+		}
+
+		delta := capped[len(passed):]
+		passed = capped
+		onContent(delta, capped)
+	}
+}
@@ -0,0 +1,63 @@
+package sapiens
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestAgentMaxResponseLength(t *testing.T) {
+	completer := newMockCompleter(textResponse("Héllo, wonderful world"), textResponse("Short."), textResponse("Another long answer"))
+	agent := NewAgent(context.Background(), completer, "test-model", "")
+	agent.SetMaxResponseLength(10)
+
+	message := NewMessages()
+	resp, err := agent.Ask(message.MergeMessages(message.UserMessage("greet me")))
+	if err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+	if got := resp.Choices[0].Message.Content; got != "Héllo, won" {
+		t.Errorf("content = %q, want %q", got, "Héllo, won")
+	}
+	if resp.Choices[0].FinishReason != openai.FinishReasonLength {
+		t.Errorf("finish reason = %q, want length", resp.Choices[0].FinishReason)
+	}
+
+	structured, err := agent.AskStructured(message.MergeMessages(message.UserMessage("be brief")))
+	if err != nil {
+		t.Fatalf("AskStructured error: %v", err)
+	}
+	if structured.Content != "Short." || structured.Truncated {
+		t.Errorf("short answer should be untouched, got %q truncated=%v", structured.Content, structured.Truncated)
+	}
+
+	structured, err = agent.AskStructured(message.MergeMessages(message.UserMessage("go on")))
+	if err != nil {
+		t.Fatalf("AskStructured error: %v", err)
+	}
+	if structured.Content != "Another lo" || !structured.Truncated {
+		t.Errorf("expected a truncated answer, got %q truncated=%v", structured.Content, structured.Truncated)
+	}
+}
+
+func TestCapContentHandler(t *testing.T) {
+	var deltas, contents []string
+	onContent := capContentHandler(func(delta, content string) {
+		deltas = append(deltas, delta)
+		contents = append(contents, content)
+	}, 8)
+
+	// Two completions of one turn, the second starting after tool calls
+	for _, content := range []string{"Let ", "Let me check", "Let me check again", "It is ", "It is sunny today"} {
+		onContent("", content)
+	}
+
+	if want := []string{"Let ", "me c", "It is ", "su"}; !reflect.DeepEqual(deltas, want) {
+		t.Errorf("deltas = %q, want %q", deltas, want)
+	}
+	if want := []string{"Let ", "Let me c", "It is ", "It is su"}; !reflect.DeepEqual(contents, want) {
+		t.Errorf("contents = %q, want %q", contents, want)
+	}
+}
@@ -0,0 +1,177 @@
+package sapiens
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// defaultRetryDelay is the wait before the first retry when a RetryPolicy
+// sets none.
+const defaultRetryDelay = time.Second
+
+// RetryPolicy retries completions the provider rejected with 429 Too Many
+// Requests. The zero value does not retry.
+type RetryPolicy struct {
+	MaxRetries int           `json:"max_retries,omitempty"` // retries after the first attempt
+	Delay      time.Duration `json:"delay,omitempty"`       // wait before the first retry, doubled on every retry; one second when zero
+}
+
+// jsonRetryPolicy is the JSON form of RetryPolicy, with the delay written as
+// a string such as "500ms".
+type jsonRetryPolicy struct {
+	MaxRetries int          `json:"max_retries,omitempty"`
+	Delay      jsonDuration `json:"delay,omitempty"`
+}
+
+func (p RetryPolicy) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonRetryPolicy{MaxRetries: p.MaxRetries, Delay: jsonDuration(p.Delay)})
+}
+
+func (p *RetryPolicy) UnmarshalJSON(data []byte) error {
+	var policy jsonRetryPolicy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return err
+	}
+
+	*p = RetryPolicy{MaxRetries: policy.MaxRetries, Delay: time.Duration(policy.Delay)}
+	return nil
+}
+
+// SetRetryPolicy makes the agent retry rate limited completions, every
+// completion of a turn separately, instead of failing the turn. When the
+// provider says how long to wait with a Retry-After header, that wait is used
+// instead of the policy's delay.
+func (a *Agent) SetRetryPolicy(policy RetryPolicy) {
+	a.mu.Lock()
+	a.retryPolicy = policy
+	a.mu.Unlock()
+}
+
+// run calls attempt until it succeeds, fails with an error other than a rate
+// limit, the retries are used up or ctx is done, and returns its last error.
+// Retrying stops early when the wait would outlast ctx's deadline.
+func (p RetryPolicy) run(ctx context.Context, attempt func() error) error {
+	delay := p.Delay
+	if delay <= 0 {
+		delay = defaultRetryDelay
+	}
+
+	for retry := 0; ; retry++ {
+		err := attempt()
+		if err == nil || retry >= p.MaxRetries || !isRateLimited(err) {
+			return err
+		}
+
+		wait := delay
+		if retryAfter, ok := retryAfterOf(err); ok {
+			wait = retryAfter
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		delay *= 2
+	}
+}
+
+// RetryAfterError is a completion error of a provider that said how long to
+// wait before trying again, in a Retry-After header. It wraps the provider's
+// error, so errors.As still finds the *openai.APIError.
+type RetryAfterError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *RetryAfterError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RetryAfterError) Unwrap() error {
+	return e.Err
+}
+
+// retryAfterOf returns the wait the provider asked for with err, if any.
+func retryAfterOf(err error) (time.Duration, bool) {
+	var retryAfterErr *RetryAfterError
+	if !errors.As(err, &retryAfterErr) {
+		return 0, false
+	}
+
+	return retryAfterErr.RetryAfter, true
+}
+
+// retryAfterHint receives the Retry-After of the response to a request made
+// with its context, see withRetryAfterHint.
+type retryAfterHint struct {
+	recorded atomic.Bool
+	delay    atomic.Int64
+}
+
+type retryAfterHintKey struct{}
+
+// withRetryAfterHint returns a context whose requests, when sent by the
+// providers' clients, record the Retry-After of their response in the hint.
+func withRetryAfterHint(ctx context.Context) (context.Context, *retryAfterHint) {
+	hint := &retryAfterHint{}
+	return context.WithValue(ctx, retryAfterHintKey{}, hint), hint
+}
+
+// wrap adds the recorded Retry-After, if any, to the error of the request.
+func (h *retryAfterHint) wrap(err error) error {
+	if err == nil || !h.recorded.Load() {
+		return err
+	}
+
+	return &RetryAfterError{Err: err, RetryAfter: time.Duration(h.delay.Load())}
+}
+
+// noteRetryAfter records the Retry-After of a 429 or 503 response in the hint
+// of the request's context.
+func noteRetryAfter(ctx context.Context, resp *http.Response) {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return
+	}
+
+	hint, ok := ctx.Value(retryAfterHintKey{}).(*retryAfterHint)
+	if !ok {
+		return
+	}
+
+	if delay, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+		hint.delay.Store(int64(delay))
+		hint.recorded.Store(true)
+	}
+}
+
+// parseRetryAfter parses a Retry-After value, either seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+
+	return max(date.Sub(now), 0), true
+}
@@ -0,0 +1,159 @@
+package sapiens
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestAgentRetryPolicy(t *testing.T) {
+	completer := &echoCompleter{limited: map[string]bool{"hi": true}}
+	agent := NewAgent(context.Background(), completer, "test-model", "")
+
+	message := NewMessages()
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("hi"))); !isRateLimited(err) {
+		t.Fatalf("expected the 429 without a retry policy, got %v", err)
+	}
+
+	completer.limited["hi"] = true
+	agent.SetRetryPolicy(RetryPolicy{MaxRetries: 2, Delay: time.Millisecond})
+	resp, err := agent.Ask(message.MergeMessages(message.UserMessage("hi")))
+	if err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+	if resp.Choices[0].Message.Content != "echo: hi" || len(completer.requests) != 3 {
+		t.Errorf("response %q after %d requests", resp.Choices[0].Message.Content, len(completer.requests))
+	}
+}
+
+func TestRetryPolicyRun(t *testing.T) {
+	rateLimitError := &openai.APIError{HTTPStatusCode: http.StatusTooManyRequests}
+
+	var attempts int
+	rateLimit := func() error {
+		attempts++
+		return rateLimitError
+	}
+
+	if err := (RetryPolicy{MaxRetries: 2, Delay: time.Millisecond}).run(context.Background(), rateLimit); err != rateLimitError || attempts != 3 {
+		t.Errorf("err = %v after %d attempts, want the 429 after 3", err, attempts)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	attempts = 0
+	if err := (RetryPolicy{MaxRetries: 5, Delay: time.Hour}).run(ctx, rateLimit); err != rateLimitError || attempts != 1 {
+		t.Errorf("err = %v after %d attempts, want a cancelled context to stop retrying", err, attempts)
+	}
+}
+
+func TestAgentRetryPolicyHonoursRetryAfter(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"error":{"message":"rate limited","type":"requests"}}`)
+			return
+		}
+		json.NewEncoder(w).Encode(textResponse("done"))
+	}))
+	defer server.Close()
+
+	gemini := NewGemini("test-token")
+	gemini.BaseUrl = server.URL
+	agent := NewAgent(context.Background(), gemini.Client(), "test-model", "")
+
+	// Without the server's hint the retry would wait an hour
+	agent.SetRetryPolicy(RetryPolicy{MaxRetries: 1, Delay: time.Hour})
+
+	started := time.Now()
+	message := NewMessages()
+	resp, err := agent.Ask(message.MergeMessages(message.UserMessage("hi")))
+	if err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+	if resp.Choices[0].Message.Content != "done" || requests != 2 {
+		t.Errorf("response %q after %d requests", resp.Choices[0].Message.Content, requests)
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("retry took %s, expected Retry-After to set the wait", elapsed)
+	}
+}
+
+func TestRetryAfterError(t *testing.T) {
+	ctx, hint := withRetryAfterHint(context.Background())
+	noteRetryAfter(ctx, &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"7"}}})
+
+	apiErr := &openai.APIError{HTTPStatusCode: http.StatusTooManyRequests}
+	err := hint.wrap(apiErr)
+	if retryAfter, ok := retryAfterOf(err); !ok || retryAfter != 7*time.Second {
+		t.Errorf("retry after = %s, %v", retryAfter, ok)
+	}
+	if !isRateLimited(err) {
+		t.Error("expected the wrapped error to still be a rate limit")
+	}
+
+	if hint.wrap(nil) != nil {
+		t.Error("wrap must keep a nil error nil")
+	}
+	if _, ok := retryAfterOf(apiErr); ok {
+		t.Error("expected no Retry-After on a plain error")
+	}
+
+	// Other statuses and requests without a hint are ignored
+	ctx, hint = withRetryAfterHint(context.Background())
+	noteRetryAfter(ctx, &http.Response{StatusCode: http.StatusBadRequest, Header: http.Header{"Retry-After": {"7"}}})
+	noteRetryAfter(context.Background(), &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"7"}}})
+	if hint.wrap(apiErr) != apiErr {
+		t.Error("expected no Retry-After to be recorded")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := map[string]struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		"seconds":   {"120", 2 * time.Minute, true},
+		"http date": {"Wed, 01 Jan 2025 12:00:30 GMT", 30 * time.Second, true},
+		"past date": {"Wed, 01 Jan 2025 11:00:00 GMT", 0, true},
+		"empty":     {"", 0, false},
+		"negative":  {"-5", 0, false},
+		"garbage":   {"soon", 0, false},
+	}
+
+	for name, test := range tests {
+		got, ok := parseRetryAfter(test.value, now)
+		if got != test.want || ok != test.ok {
+			t.Errorf("%s: parseRetryAfter(%q) = %s, %v, want %s, %v", name, test.value, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestRetryPolicyGivesUpPastDeadline(t *testing.T) {
+	rateLimitError := &RetryAfterError{Err: &openai.APIError{HTTPStatusCode: http.StatusTooManyRequests}, RetryAfter: time.Hour}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var attempts int
+	started := time.Now()
+	err := (RetryPolicy{MaxRetries: 3}).run(ctx, func() error {
+		attempts++
+		return rateLimitError
+	})
+	if err != rateLimitError || attempts != 1 || time.Since(started) > time.Second {
+		t.Errorf("err = %v after %d attempts, want to give up at once when Retry-After outlasts the deadline", err, attempts)
+	}
+}
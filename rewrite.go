@@ -0,0 +1,56 @@
+package sapiens
+
+import (
+	"context"
+	"fmt"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// PromptRewriter transforms the user's prompt before it is sent, e.g. to
+// translate it or expand acronyms.
+type PromptRewriter func(ctx context.Context, original string) (string, error)
+
+// rewrittenPrompt replaces the content of a message of the conversation in
+// requests.
+type rewrittenPrompt struct {
+	index   int // of the message in the conversation
+	content string
+}
+
+// SetPromptRewriter makes every turn send the rewritten version of its last
+// user message to the model. The history keeps the original, and the model
+// never sees it. A turn whose prompt cannot be rewritten fails before anything
+// is sent or recorded. Pass nil to send prompts unchanged.
+func (a *Agent) SetPromptRewriter(rewriter PromptRewriter) {
+	a.mu.Lock()
+	a.promptRewriter = rewriter
+	a.mu.Unlock()
+}
+
+// rewriteLastUserMessage returns the position among messages of the last user
+// message with text content and its rewritten content, or -1 when there is
+// no rewriter or no such message.
+func (a *Agent) rewriteLastUserMessage(ctx context.Context, messages []openai.ChatCompletionMessage) (int, string, error) {
+	a.mu.Lock()
+	rewriter := a.promptRewriter
+	a.mu.Unlock()
+
+	if rewriter == nil {
+		return -1, "", nil
+	}
+
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role != openai.ChatMessageRoleUser || messages[i].Content == "" {
+			continue
+		}
+
+		rewritten, err := rewriter(ctx, messages[i].Content)
+		if err != nil {
+			return -1, "", fmt.Errorf("failed to rewrite prompt: %w", err)
+		}
+		return i, rewritten, nil
+	}
+
+	return -1, "", nil
+}
@@ -0,0 +1,69 @@
+package sapiens
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAgentPromptRewriter(t *testing.T) {
+	completer := newMockCompleter(
+		toolCallResponse(functionCall("call_1", "lookup", "{}")),
+		textResponse("Order 42 has shipped."),
+		textResponse("You're welcome."),
+	)
+
+	agent := NewAgent(context.Background(), completer, "test-model", "You are helpful")
+	agent.AddTool("lookup", "Look up the order", nil, nil, func(map[string]string) string { return "shipped" })
+
+	var originals []string
+	agent.SetPromptRewriter(func(ctx context.Context, original string) (string, error) {
+		originals = append(originals, original)
+		return strings.ReplaceAll(original, "¿Dónde está mi pedido?", "Where is my order?"), nil
+	})
+
+	message := NewMessages()
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("¿Dónde está mi pedido?"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	// Both rounds of the turn send the rewritten prompt
+	for i, request := range completer.Requests {
+		if got := request.Messages[1].Content; got != "Where is my order?" {
+			t.Errorf("request %d sent the prompt %q", i, got)
+		}
+	}
+	if got := agent.MessagesHistory[1].Content; got != "¿Dónde está mi pedido?" {
+		t.Errorf("history holds %q, want the original prompt", got)
+	}
+
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("gracias"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+	last := completer.Requests[2].Messages
+	if last[1].Content != "¿Dónde está mi pedido?" || last[len(last)-1].Content != "gracias" {
+		t.Errorf("earlier prompts must be sent as recorded, got %+v", last)
+	}
+	if len(originals) != 2 {
+		t.Errorf("rewriter called %d times, want once per turn", len(originals))
+	}
+}
+
+func TestAgentPromptRewriterError(t *testing.T) {
+	completer := newMockCompleter(textResponse("never sent"))
+	agent := NewAgent(context.Background(), completer, "test-model", "")
+
+	failure := errors.New("translator unavailable")
+	agent.SetPromptRewriter(func(ctx context.Context, original string) (string, error) {
+		return "", failure
+	})
+
+	message := NewMessages()
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("hola"))); !errors.Is(err, failure) {
+		t.Fatalf("expected the rewriter's error, got %v", err)
+	}
+	if len(completer.Requests) != 0 || len(agent.MessagesHistory) != 0 {
+		t.Errorf("a failed rewrite sent %d requests and recorded %d messages", len(completer.Requests), len(agent.MessagesHistory))
+	}
+}
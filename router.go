@@ -0,0 +1,119 @@
+package sapiens
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+const routerPrompt = `Classify the query below into exactly one of these routes: %s.
+
+Query:
+%s
+
+Respond with the name of the route best suited to answer the query and a short reason.`
+
+type routeDecision struct {
+	Route  string `json:"route"`
+	Reason string `json:"reason"`
+}
+
+// Router delegates queries to specialist agents, using a classifier agent
+// to pick the route for each query.
+type Router struct {
+	Classifier *Agent
+	Routes     map[string]*Agent
+}
+
+// NewRouter returns a Router that asks classifier to choose one of routes.
+// The classifier is switched to stateless mode so that every query is
+// classified on its own.
+func NewRouter(classifier *Agent, routes map[string]*Agent) *Router {
+	classifier.SetStateless(true)
+
+	return &Router{
+		Classifier: classifier,
+		Routes:     routes,
+	}
+}
+
+// Route classifies query and returns the answer of the chosen specialist.
+func (r *Router) Route(ctx context.Context, query string) (*Response, error) {
+	route, err := r.classify(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	specialist := r.Routes[route]
+
+	specialist.askMu.Lock()
+	defer specialist.askMu.Unlock()
+
+	message := NewMessages()
+	response, err := specialist.askStructured(askOptions{ctx: ctx}, message.MergeMessages(message.UserMessage(query)))
+	if err != nil {
+		return response, fmt.Errorf("route '%s': %w", route, err)
+	}
+
+	return response, nil
+}
+
+func (r *Router) classify(ctx context.Context, query string) (string, error) {
+	if len(r.Routes) == 0 {
+		return "", fmt.Errorf("router has no routes")
+	}
+
+	names := make([]string, 0, len(r.Routes))
+	for name := range r.Routes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	responseFormat := &openai.ChatCompletionResponseFormat{
+		Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+		JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+			Name: "route",
+			Schema: &jsonschema.Definition{
+				Type: jsonschema.Object,
+				Properties: map[string]jsonschema.Definition{
+					"route":  {Type: jsonschema.String, Enum: names},
+					"reason": {Type: jsonschema.String},
+				},
+				Required:             []string{"route", "reason"},
+				AdditionalProperties: false,
+			},
+			Strict: true,
+		},
+	}
+
+	r.Classifier.askMu.Lock()
+	defer r.Classifier.askMu.Unlock()
+
+	message := NewMessages()
+	resp, err := r.Classifier.ask(askOptions{ctx: ctx, responseFormat: responseFormat}, message.MergeMessages(
+		message.UserMessage(fmt.Sprintf(routerPrompt, strings.Join(names, ", "), query)),
+	))
+	if err != nil {
+		return "", fmt.Errorf("classification failed: %w", err)
+	}
+
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("classification failed: no choices in response")
+	}
+
+	var decision routeDecision
+	if err := json.Unmarshal([]byte(stripMarkdownFences(resp.Choices[0].Message.Content)), &decision); err != nil {
+		return "", fmt.Errorf("classification failed: %w", err)
+	}
+
+	if _, ok := r.Routes[decision.Route]; !ok {
+		return "", fmt.Errorf("classifier chose unknown route '%s'", decision.Route)
+	}
+
+	return decision.Route, nil
+}
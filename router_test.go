@@ -0,0 +1,57 @@
+package sapiens
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRouterRoute(t *testing.T) {
+	classifier := newMockCompleter(textResponse(`{"route":"billing","reason":"asks about an invoice"}`))
+	billing := newMockCompleter(textResponse("Your invoice was sent on Monday."))
+	technical := newMockCompleter()
+
+	router := NewRouter(
+		NewAgent(context.Background(), classifier, "test-model", "you route support queries"),
+		map[string]*Agent{
+			"billing":   NewAgent(context.Background(), billing, "test-model", "you handle billing"),
+			"technical": NewAgent(context.Background(), technical, "test-model", "you handle technical issues"),
+		},
+	)
+
+	resp, err := router.Route(context.Background(), "where is my invoice?")
+	if err != nil {
+		t.Fatalf("Route error: %v", err)
+	}
+
+	if resp.Content != "Your invoice was sent on Monday." {
+		t.Errorf("unexpected content: %q", resp.Content)
+	}
+
+	if len(technical.Requests) != 0 {
+		t.Error("the technical agent should not have been asked")
+	}
+
+	format := classifier.Requests[0].ResponseFormat
+	if format == nil || format.JSONSchema == nil || format.JSONSchema.Name != "route" {
+		t.Fatalf("expected the classifier to use a route schema, got %+v", format)
+	}
+
+	if last := billing.Requests[0].Messages; last[len(last)-1].Content != "where is my invoice?" {
+		t.Errorf("expected the query to be forwarded unchanged, got %+v", last)
+	}
+}
+
+func TestRouterUnknownRoute(t *testing.T) {
+	classifier := newMockCompleter(textResponse(`{"route":"sales","reason":"wants a quote"}`))
+
+	router := NewRouter(
+		NewAgent(context.Background(), classifier, "test-model", "you route support queries"),
+		map[string]*Agent{"billing": NewAgent(context.Background(), newMockCompleter(), "test-model", "")},
+	)
+
+	_, err := router.Route(context.Background(), "how much for 10 seats?")
+	if err == nil || !strings.Contains(err.Error(), "unknown route 'sales'") {
+		t.Fatalf("expected unknown route error, got %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package sapiens
+
+import (
+	"fmt"
+
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+// SchemaBuilder builds an object schema one property at a time, keeping
+// track of which properties are required:
+//
+//	schema := NewObjectSchema().
+//		StringProp("location", "City and country", true).
+//		EnumProp("unit", []string{"c", "f"}, false).
+//		Build()
+//
+// The result can be passed to SetResponseSchema, or split into its
+// Properties and Required for AddTool.
+type SchemaBuilder struct {
+	description string
+	properties  map[string]jsonschema.Definition
+	required    []string
+}
+
+func NewObjectSchema() *SchemaBuilder {
+	return &SchemaBuilder{properties: make(map[string]jsonschema.Definition)}
+}
+
+// Description sets the description of the object itself.
+func (b *SchemaBuilder) Description(description string) *SchemaBuilder {
+	b.description = description
+	return b
+}
+
+func (b *SchemaBuilder) StringProp(name, description string, required bool) *SchemaBuilder {
+	return b.Prop(name, jsonschema.Definition{Type: jsonschema.String, Description: description}, required)
+}
+
+func (b *SchemaBuilder) NumberProp(name, description string, required bool) *SchemaBuilder {
+	return b.Prop(name, jsonschema.Definition{Type: jsonschema.Number, Description: description}, required)
+}
+
+func (b *SchemaBuilder) IntegerProp(name, description string, required bool) *SchemaBuilder {
+	return b.Prop(name, jsonschema.Definition{Type: jsonschema.Integer, Description: description}, required)
+}
+
+func (b *SchemaBuilder) BooleanProp(name, description string, required bool) *SchemaBuilder {
+	return b.Prop(name, jsonschema.Definition{Type: jsonschema.Boolean, Description: description}, required)
+}
+
+// EnumProp adds a string property restricted to values.
+func (b *SchemaBuilder) EnumProp(name string, values []string, required bool) *SchemaBuilder {
+	return b.Prop(name, jsonschema.Definition{Type: jsonschema.String, Enum: append([]string(nil), values...)}, required)
+}
+
+// ObjectProp adds a nested object property built by object.
+func (b *SchemaBuilder) ObjectProp(name, description string, object *SchemaBuilder, required bool) *SchemaBuilder {
+	definition := object.Build()
+	if description != "" {
+		definition.Description = description
+	}
+
+	return b.Prop(name, definition, required)
+}
+
+// ArrayProp adds an array property whose elements match items, e.g.
+// jsonschema.Definition{Type: jsonschema.String} or another builder's Build().
+func (b *SchemaBuilder) ArrayProp(name, description string, items jsonschema.Definition, required bool) *SchemaBuilder {
+	return b.Prop(name, jsonschema.Definition{Type: jsonschema.Array, Description: description, Items: &items}, required)
+}
+
+// Prop adds a property with an arbitrary definition. Adding a property again
+// replaces it.
+func (b *SchemaBuilder) Prop(name string, definition jsonschema.Definition, required bool) *SchemaBuilder {
+	b.properties[name] = definition
+
+	for i, existing := range b.required {
+		if existing == name {
+			b.required = append(b.required[:i], b.required[i+1:]...)
+			break
+		}
+	}
+	if required {
+		b.required = append(b.required, name)
+	}
+
+	return b
+}
+
+// Build returns the object schema. Later changes to the builder do not
+// affect schemas already built.
+func (b *SchemaBuilder) Build() jsonschema.Definition {
+	properties := make(map[string]jsonschema.Definition, len(b.properties))
+	for name, definition := range b.properties {
+		properties[name] = definition
+	}
+
+	return jsonschema.Definition{
+		Type:        jsonschema.Object,
+		Description: b.description,
+		Properties:  properties,
+		Required:    append([]string(nil), b.required...),
+	}
+}
+
+// ValidateSchema checks that every required property of an object is defined
+// in its properties and that every array defines its items, recursively. The
+// model can never satisfy a schema failing these checks.
+func ValidateSchema(schema jsonschema.Definition) error {
+	return validateSchemaAt(schema, "")
+}
+
+func validateSchemaAt(schema jsonschema.Definition, path string) error {
+	switch schema.Type {
+	case jsonschema.Object:
+		for _, name := range schema.Required {
+			if _, ok := schema.Properties[name]; !ok {
+				return fmt.Errorf("required property '%s' is not defined", schemaPath(path, name))
+			}
+		}
+
+		for name, property := range schema.Properties {
+			if err := validateSchemaAt(property, schemaPath(path, name)); err != nil {
+				return err
+			}
+		}
+	case jsonschema.Array:
+		if schema.Items == nil {
+			if path == "" {
+				return fmt.Errorf("the schema is an array but does not define its items")
+			}
+			return fmt.Errorf("array '%s' does not define its items", path)
+		}
+
+		return validateSchemaAt(*schema.Items, path+"[]")
+	}
+
+	return nil
+}
+
+func schemaPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+
+	return path + "." + name
+}
@@ -0,0 +1,152 @@
+package sapiens
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+func TestSchemaBuilder(t *testing.T) {
+	address := NewObjectSchema().
+		StringProp("city", "City name", true).
+		StringProp("zip", "Postal code", false)
+
+	got := NewObjectSchema().
+		Description("A weather query").
+		StringProp("location", "City and country", true).
+		EnumProp("unit", []string{"c", "f"}, false).
+		IntegerProp("days", "Forecast length", true).
+		ObjectProp("address", "Where to deliver", address, false).
+		ArrayProp("tags", "Labels", jsonschema.Definition{Type: jsonschema.String}, false).
+		BooleanProp("days", "Replaced, now optional", false).
+		Build()
+
+	want := jsonschema.Definition{
+		Type:        jsonschema.Object,
+		Description: "A weather query",
+		Properties: map[string]jsonschema.Definition{
+			"location": {Type: jsonschema.String, Description: "City and country"},
+			"unit":     {Type: jsonschema.String, Enum: []string{"c", "f"}},
+			"days":     {Type: jsonschema.Boolean, Description: "Replaced, now optional"},
+			"address": {
+				Type:        jsonschema.Object,
+				Description: "Where to deliver",
+				Properties: map[string]jsonschema.Definition{
+					"city": {Type: jsonschema.String, Description: "City name"},
+					"zip":  {Type: jsonschema.String, Description: "Postal code"},
+				},
+				Required: []string{"city"},
+			},
+			"tags": {Type: jsonschema.Array, Description: "Labels", Items: &jsonschema.Definition{Type: jsonschema.String}},
+		},
+		Required: []string{"location"},
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Build() = %#v, want %#v", got, want)
+	}
+
+	if err := validateToolParameters("weather", got.Properties, got.Required); err != nil {
+		t.Errorf("built schema is invalid: %v", err)
+	}
+}
+
+func TestAgentSetResponseSchemaFromBuilder(t *testing.T) {
+	agent := NewAgent(context.Background(), newMockCompleter(), "test-model", "")
+	schema := NewObjectSchema().StringProp("answer", "The answer", true).Build()
+
+	format := agent.SetResponseSchema("answer", "", true, schema)
+
+	got, ok := format.JSONSchema.Schema.(*jsonschema.Definition)
+	if !ok || !reflect.DeepEqual(*got, schema) {
+		t.Errorf("response schema = %#v, want %#v", format.JSONSchema.Schema, schema)
+	}
+}
+
+func TestValidateSchema(t *testing.T) {
+	tests := map[string]struct {
+		schema jsonschema.Definition
+		want   string
+	}{
+		"valid": {
+			schema: NewObjectSchema().
+				StringProp("name", "", true).
+				ArrayProp("items", "", NewObjectSchema().IntegerProp("qty", "", true).Build(), true).
+				Build(),
+		},
+		"missing required property": {
+			schema: jsonschema.Definition{
+				Type:       jsonschema.Object,
+				Properties: map[string]jsonschema.Definition{"name": {Type: jsonschema.String}},
+				Required:   []string{"name", "age"},
+			},
+			want: "required property 'age' is not defined",
+		},
+		"nested missing required property": {
+			schema: NewObjectSchema().
+				ArrayProp("items", "", jsonschema.Definition{Type: jsonschema.Object, Required: []string{"qty"}}, true).
+				Build(),
+			want: "required property 'items[].qty' is not defined",
+		},
+		"array without items": {
+			schema: NewObjectSchema().
+				ObjectProp("order", "", NewObjectSchema().Prop("lines", jsonschema.Definition{Type: jsonschema.Array}, false), true).
+				Build(),
+			want: "array 'order.lines' does not define its items",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := ValidateSchema(tt.schema)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("ValidateSchema() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("ValidateSchema() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAgentInvalidResponseSchema(t *testing.T) {
+	completer := newMockCompleter(textResponse(`{"age":3}`), textResponse(`{"age":4}`))
+	agent := NewAgent(context.Background(), completer, "test-model", "")
+
+	invalid := jsonschema.Definition{
+		Type:       jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{"name": {Type: jsonschema.String}},
+		Required:   []string{"age"},
+	}
+	if format := agent.SetResponseSchema("person", "", true, invalid); format != nil {
+		t.Errorf("SetResponseSchema of an invalid schema = %+v, want nil", format)
+	}
+
+	message := NewMessages()
+	_, err := agent.Ask(message.MergeMessages(message.UserMessage("how old?")))
+	if err == nil || !strings.Contains(err.Error(), "required property 'age' is not defined") {
+		t.Fatalf("Ask error = %v, want the validation error", err)
+	}
+	if len(completer.Requests) != 0 {
+		t.Errorf("expected no request with an invalid schema, got %d", len(completer.Requests))
+	}
+
+	// A call with its own schema is not affected
+	type Age struct {
+		Age int `json:"age"`
+	}
+	if age, err := AskJSON[Age](agent, message.MergeMessages(message.UserMessage("how old?"))); err != nil || age.Age != 3 {
+		t.Errorf("AskJSON = %+v, %v", age, err)
+	}
+
+	agent.SetResponseSchema("age", "", true, Age{})
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("how old?"))); err != nil {
+		t.Errorf("Ask error after setting a valid schema: %v", err)
+	}
+}
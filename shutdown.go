@@ -0,0 +1,50 @@
+package sapiens
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrAgentShutdown is returned by turns started after Shutdown.
+var ErrAgentShutdown = errors.New("agent is shut down")
+
+// Shutdown stops the agent accepting new turns, which fail with
+// ErrAgentShutdown, waits for the turn in progress, including its tool calls,
+// to finish and disconnects the MCP server. Turns queued behind the one in
+// progress are rejected rather than run.
+//
+// When ctx is done before the turn finishes, the MCP server is disconnected
+// anyway, failing the turn's pending MCP calls, and ctx's error is returned.
+// Cancel the agent's context as well to abort the turn's model requests.
+func (a *Agent) Shutdown(ctx context.Context) error {
+	a.mu.Lock()
+	a.shutdown = true
+	a.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		a.askMu.Lock()
+		a.askMu.Unlock()
+		close(drained)
+	}()
+
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = fmt.Errorf("failed to drain the turn in progress: %w", ctx.Err())
+	}
+
+	a.mu.Lock()
+	mcpClient := a.McpClient
+	a.mu.Unlock()
+
+	if mcpClient != nil {
+		if disconnectErr := mcpClient.Disconnect(); disconnectErr != nil && err == nil {
+			err = fmt.Errorf("failed to disconnect the MCP server: %w", disconnectErr)
+		}
+	}
+
+	return err
+}
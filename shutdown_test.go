@@ -0,0 +1,104 @@
+package sapiens
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAgentShutdownDrainsTurnInProgress(t *testing.T) {
+	agent := NewAgent(context.Background(), newMockCompleter(
+		toolCallResponse(functionCall("call_1", "wait", "{}")),
+		textResponse("done"),
+	), "test-model", "")
+
+	started, release := make(chan struct{}), make(chan struct{})
+	agent.AddTool("wait", "Waits", nil, nil, func(map[string]string) string {
+		close(started)
+		<-release
+		return "ok"
+	})
+
+	messages := NewMessages()
+	askErr := make(chan error, 1)
+	go func() {
+		_, err := agent.Ask(messages.MergeMessages(messages.UserMessage("hi")))
+		askErr <- err
+	}()
+	<-started
+
+	shutdownErr := make(chan error, 1)
+	go func() { shutdownErr <- agent.Shutdown(context.Background()) }()
+
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		agent.mu.Lock()
+		shutdown := agent.shutdown
+		agent.mu.Unlock()
+		if shutdown {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Shutdown never started")
+		}
+	}
+
+	select {
+	case err := <-shutdownErr:
+		t.Fatalf("Shutdown returned before the turn finished: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-askErr; err != nil {
+		t.Errorf("turn in progress failed: %v", err)
+	}
+	if err := <-shutdownErr; err != nil {
+		t.Errorf("Shutdown error: %v", err)
+	}
+
+	if _, err := agent.Ask(messages.MergeMessages(messages.UserMessage("again"))); !errors.Is(err, ErrAgentShutdown) {
+		t.Errorf("expected ErrAgentShutdown after Shutdown, got %v", err)
+	}
+}
+
+func TestAgentShutdownDeadline(t *testing.T) {
+	agent := NewAgent(context.Background(), newMockCompleter(
+		toolCallResponse(functionCall("call_1", "wait", "{}")),
+		textResponse("done"),
+	), "test-model", "")
+
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	agent.AddTool("wait", "Waits", nil, nil, func(map[string]string) string {
+		close(started)
+		<-release
+		return "ok"
+	})
+
+	messages := NewMessages()
+	go agent.Ask(messages.MergeMessages(messages.UserMessage("hi")))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := agent.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline to cut the drain short, got %v", err)
+	}
+}
+
+func TestAgentShutdownDisconnectsMCP(t *testing.T) {
+	server := newMcpTestServer(t)
+
+	agent := NewAgent(context.Background(), newMockCompleter(), "test-model", "")
+	if err := agent.AddMCP(server.URL+"/sse", nil); err != nil {
+		t.Fatalf("AddMCP error: %v", err)
+	}
+
+	if err := agent.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown error: %v", err)
+	}
+	if agent.McpClient.IsConnected() {
+		t.Error("expected the MCP client to be disconnected")
+	}
+}
@@ -0,0 +1,50 @@
+package sapiens
+
+import (
+	"errors"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// ErrStopped is returned by a turn interrupted with Stop.
+var ErrStopped = errors.New("turn stopped")
+
+// Stop interrupts the turn in progress, e.g. for a "stop generating" button.
+// The turn aborts its current completion or, between tool calls, before the
+// next one, and Ask returns ErrStopped with a response holding the content
+// received so far: the partially streamed answer, or the content of the
+// latest completion. Turns queued behind it are not affected. Stop reports
+// whether a turn was in progress.
+func (a *Agent) Stop() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.stopTurn == nil {
+		return false
+	}
+	a.stopTurn()
+
+	return true
+}
+
+// stoppedResponse builds the response of a stopped turn from the latest
+// completion and the content streamed since. Tool calls that were not run are
+// dropped.
+func stoppedResponse(last openai.ChatCompletionResponse, partial string) openai.ChatCompletionResponse {
+	response := last
+	response.Choices = nil
+
+	content := partial
+	if content == "" && len(last.Choices) > 0 {
+		content = last.Choices[0].Message.Content
+	}
+
+	response.Choices = []openai.ChatCompletionChoice{{
+		Message: openai.ChatCompletionMessage{
+			Role:    openai.ChatMessageRoleAssistant,
+			Content: content,
+		},
+	}}
+
+	return response
+}
@@ -0,0 +1,87 @@
+package sapiens
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestAgentStopBetweenToolCalls(t *testing.T) {
+	toolRound := toolCallResponse(
+		functionCall("call_1", "search", `{"q":"a"}`),
+		functionCall("call_2", "search", `{"q":"b"}`),
+	)
+	toolRound.Choices[0].Message.Content = "Searching twice."
+	completer := newMockCompleter(toolRound, textResponse("never sent"))
+
+	agent := NewAgent(context.Background(), completer, "test-model", "")
+	if agent.Stop() {
+		t.Error("Stop reported a turn in progress before any Ask")
+	}
+
+	var searches int
+	agent.AddTool("search", "Search", nil, nil, func(map[string]string) string {
+		searches++
+		if !agent.Stop() {
+			t.Error("Stop did not find the turn in progress")
+		}
+		return "result"
+	})
+
+	messages := NewMessages()
+	response, err := agent.Ask(messages.MergeMessages(messages.UserMessage("search")))
+	if !errors.Is(err, ErrStopped) {
+		t.Fatalf("expected ErrStopped, got %v", err)
+	}
+	if searches != 1 || len(completer.Requests) != 1 {
+		t.Errorf("searches = %d, completions = %d, expected the turn to stop after the first tool", searches, len(completer.Requests))
+	}
+	if len(response.Choices) != 1 || response.Choices[0].Message.Content != "Searching twice." || len(response.Choices[0].Message.ToolCalls) != 0 {
+		t.Errorf("stopped response = %+v", response.Choices)
+	}
+
+	if agent.Stop() {
+		t.Error("Stop reported a turn in progress after the turn ended")
+	}
+
+	completer.Enqueue(textResponse("fine"))
+	if _, err := agent.Ask(messages.MergeMessages(messages.UserMessage("again"))); err != nil {
+		t.Errorf("the next turn failed after a stop: %v", err)
+	}
+}
+
+func TestAgentStopKeepsStreamedContent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		chunk, _ := json.Marshal(contentChunks("The answer is")[0])
+		fmt.Fprintf(w, "data: %s\n\n", chunk)
+		w.(http.Flusher).Flush()
+
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	config := openai.DefaultConfig("test-token")
+	config.BaseURL = server.URL
+	agent := NewAgent(context.Background(), openai.NewClientWithConfig(config), "test-model", "")
+
+	onContent := func(_, _ string) { agent.Stop() }
+
+	messages := NewMessages()
+	agent.askMu.Lock()
+	response, err := agent.ask(askOptions{onContent: onContent}, messages.MergeMessages(messages.UserMessage("question")))
+	agent.askMu.Unlock()
+
+	if !errors.Is(err, ErrStopped) {
+		t.Fatalf("expected ErrStopped, got %v", err)
+	}
+	if len(response.Choices) != 1 || response.Choices[0].Message.Content != "The answer is" {
+		t.Errorf("stopped response = %+v", response.Choices)
+	}
+}
@@ -0,0 +1,177 @@
+package sapiens
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// contentHandler receives streamed content for the first choice of a
+// completion: the new delta and everything received so far.
+type contentHandler func(delta, content string)
+
+// minRepeatedDelta is the length from which a content delta repeated
+// verbatim by the next chunk is taken as a duplicated chunk rather than text.
+// Gemini's OpenAI-compatible endpoint occasionally sends a chunk twice; its
+// chunks span several words, while legitimately repeated deltas are single
+// short tokens such as "\n" or "ha".
+const minRepeatedDelta = 16
+
+// streamAccumulator assembles streamed chunks into a complete response.
+// Content and tool calls are collected per choice, and tool call fragments
+// are joined by their index.
+type streamAccumulator struct {
+	response    openai.ChatCompletionResponse
+	lastContent map[int]string // latest content delta per choice
+}
+
+// add merges a chunk and returns the content it added to the first choice.
+func (s *streamAccumulator) add(chunk openai.ChatCompletionStreamResponse) string {
+	if chunk.ID != "" {
+		s.response.ID = chunk.ID
+	}
+	if chunk.Model != "" {
+		s.response.Model = chunk.Model
+	}
+	if chunk.Created != 0 {
+		s.response.Created = chunk.Created
+	}
+	if chunk.SystemFingerprint != "" {
+		s.response.SystemFingerprint = chunk.SystemFingerprint
+	}
+	if chunk.Usage != nil {
+		s.response.Usage = *chunk.Usage
+	}
+
+	var delta string
+	for _, streamChoice := range chunk.Choices {
+		choice := s.choice(streamChoice.Index)
+
+		if streamChoice.Delta.Role != "" {
+			choice.Message.Role = streamChoice.Delta.Role
+		}
+		content := streamChoice.Delta.Content
+		if len(content) >= minRepeatedDelta && content == s.lastContent[streamChoice.Index] {
+			content = ""
+		}
+		if content != "" {
+			if s.lastContent == nil {
+				s.lastContent = make(map[int]string)
+			}
+			s.lastContent[streamChoice.Index] = content
+		}
+
+		choice.Message.Content += content
+		choice.Message.ReasoningContent += streamChoice.Delta.ReasoningContent
+		choice.Message.Refusal += streamChoice.Delta.Refusal
+
+		for _, toolCall := range streamChoice.Delta.ToolCalls {
+			mergeToolCall(&choice.Message, toolCall)
+		}
+
+		if streamChoice.FinishReason != "" {
+			choice.FinishReason = streamChoice.FinishReason
+		}
+
+		if streamChoice.Index == 0 {
+			delta += content
+		}
+	}
+
+	return delta
+}
+
+func (s *streamAccumulator) choice(index int) *openai.ChatCompletionChoice {
+	for i := range s.response.Choices {
+		if s.response.Choices[i].Index == index {
+			return &s.response.Choices[i]
+		}
+	}
+
+	s.response.Choices = append(s.response.Choices, openai.ChatCompletionChoice{
+		Index:   index,
+		Message: openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant},
+	})
+
+	return &s.response.Choices[len(s.response.Choices)-1]
+}
+
+// content returns the content received so far for the first choice.
+func (s *streamAccumulator) content() string {
+	for _, choice := range s.response.Choices {
+		if choice.Index == 0 {
+			return choice.Message.Content
+		}
+	}
+
+	return ""
+}
+
+// hasToolCalls reports whether the first choice has received tool calls.
+func (s *streamAccumulator) hasToolCalls() bool {
+	for _, choice := range s.response.Choices {
+		if choice.Index == 0 {
+			return len(choice.Message.ToolCalls) > 0
+		}
+	}
+
+	return false
+}
+
+// mergeToolCall appends a streamed tool call fragment to the message. The
+// first fragment of a call carries its id and name, later ones only more of
+// its arguments.
+func mergeToolCall(message *openai.ChatCompletionMessage, fragment openai.ToolCall) {
+	index := len(message.ToolCalls)
+	if fragment.Index != nil {
+		index = *fragment.Index
+	} else if fragment.ID == "" && index > 0 {
+		index--
+	}
+
+	for len(message.ToolCalls) <= index {
+		message.ToolCalls = append(message.ToolCalls, openai.ToolCall{Type: openai.ToolTypeFunction})
+	}
+
+	toolCall := &message.ToolCalls[index]
+	if fragment.ID != "" {
+		toolCall.ID = fragment.ID
+	}
+	if fragment.Type != "" {
+		toolCall.Type = fragment.Type
+	}
+	toolCall.Function.Name += fragment.Function.Name
+	toolCall.Function.Arguments += fragment.Function.Arguments
+}
+
+// streamCompletion sends the request with streaming enabled, passes content
+// to onContent as it arrives and returns the assembled response.
+func (a *Agent) streamCompletion(ctx context.Context, request openai.ChatCompletionRequest, onContent contentHandler) (openai.ChatCompletionResponse, error) {
+	request.Stream = true
+
+	stream, err := a.Llm.CreateChatCompletionStream(ctx, request)
+	if err != nil {
+		return openai.ChatCompletionResponse{}, err
+	}
+	defer stream.Close()
+
+	var accumulator streamAccumulator
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return accumulator.response, nil
+		}
+		if err != nil {
+			return accumulator.response, err
+		}
+
+		// Content following a tool call is not the answer, which only comes
+		// once the tool results are back; a structured stream would otherwise
+		// decode it as a partial value
+		if delta := accumulator.add(chunk); delta != "" && !accumulator.hasToolCalls() {
+			onContent(delta, accumulator.content())
+		}
+	}
+}
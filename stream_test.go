@@ -0,0 +1,142 @@
+package sapiens
+
+import (
+	"context"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestStreamAccumulatorToolCalls(t *testing.T) {
+	var accumulator streamAccumulator
+
+	chunks := []openai.ChatCompletionStreamResponse{
+		{ID: "chatcmpl-1", Model: "test-model", Choices: []openai.ChatCompletionStreamChoice{{Delta: openai.ChatCompletionStreamChoiceDelta{
+			Role:      openai.ChatMessageRoleAssistant,
+			ToolCalls: []openai.ToolCall{{Index: intPtr(0), ID: "call_1", Type: openai.ToolTypeFunction, Function: openai.FunctionCall{Name: "get_weather"}}},
+		}}}},
+		{Choices: []openai.ChatCompletionStreamChoice{{Delta: openai.ChatCompletionStreamChoiceDelta{
+			ToolCalls: []openai.ToolCall{{Index: intPtr(0), Function: openai.FunctionCall{Arguments: `{"location":`}}},
+		}}}},
+		{Choices: []openai.ChatCompletionStreamChoice{{Delta: openai.ChatCompletionStreamChoiceDelta{
+			ToolCalls: []openai.ToolCall{
+				{Index: intPtr(0), Function: openai.FunctionCall{Arguments: `"Paris"}`}},
+				{Index: intPtr(1), ID: "call_2", Type: openai.ToolTypeFunction, Function: openai.FunctionCall{Name: "get_time", Arguments: `{}`}},
+			},
+		}}}},
+		{Choices: []openai.ChatCompletionStreamChoice{{FinishReason: openai.FinishReasonToolCalls}}},
+	}
+
+	for _, chunk := range chunks {
+		if delta := accumulator.add(chunk); delta != "" {
+			t.Errorf("unexpected content delta %q", delta)
+		}
+	}
+
+	response := accumulator.response
+	if response.ID != "chatcmpl-1" || len(response.Choices) != 1 {
+		t.Fatalf("unexpected response: %+v", response)
+	}
+
+	choice := response.Choices[0]
+	if choice.FinishReason != openai.FinishReasonToolCalls {
+		t.Errorf("unexpected finish reason %q", choice.FinishReason)
+	}
+
+	calls := choice.Message.ToolCalls
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 tool calls, got %+v", calls)
+	}
+	if calls[0].ID != "call_1" || calls[0].Function.Name != "get_weather" || calls[0].Function.Arguments != `{"location":"Paris"}` {
+		t.Errorf("unexpected first tool call: %+v", calls[0])
+	}
+	if calls[1].ID != "call_2" || calls[1].Function.Name != "get_time" {
+		t.Errorf("unexpected second tool call: %+v", calls[1])
+	}
+}
+
+func TestAgentStreamRunsToolLoop(t *testing.T) {
+	toolRound := []openai.ChatCompletionStreamResponse{
+		{Choices: []openai.ChatCompletionStreamChoice{{Delta: openai.ChatCompletionStreamChoiceDelta{
+			ToolCalls: []openai.ToolCall{{Index: intPtr(0), ID: "call_1", Type: openai.ToolTypeFunction, Function: openai.FunctionCall{Name: "get_weather", Arguments: `{"location":"Paris"}`}}},
+		}}}},
+	}
+
+	client, script := newStreamingClient(t, toolRound, contentChunks("It is ", "sunny."))
+	agent := NewAgent(context.Background(), client, "test-model", "you are a weather reporter")
+
+	var location string
+	agent.AddTool("get_weather", "Get the weather", map[string]jsonschema.Definition{
+		"location": {Type: jsonschema.String},
+	}, []string{"location"}, func(parameters map[string]string) string {
+		location = parameters["location"]
+		return `{"condition":"sunny"}`
+	})
+
+	var deltas []string
+	agent.askMu.Lock()
+	message := NewMessages()
+	resp, err := agent.ask(askOptions{onContent: func(delta, content string) {
+		deltas = append(deltas, delta)
+	}}, message.MergeMessages(message.UserMessage("weather in paris?")))
+	agent.askMu.Unlock()
+	if err != nil {
+		t.Fatalf("ask error: %v", err)
+	}
+
+	if location != "Paris" {
+		t.Errorf("expected the streamed tool call to run, got location %q", location)
+	}
+
+	if resp.Choices[0].Message.Content != "It is sunny." {
+		t.Errorf("unexpected assembled content %q", resp.Choices[0].Message.Content)
+	}
+
+	if len(deltas) != 2 || deltas[0] != "It is " || deltas[1] != "sunny." {
+		t.Errorf("unexpected deltas %q", deltas)
+	}
+
+	if len(script.Requests) != 2 || !script.Requests[0].Stream || !script.Requests[1].Stream {
+		t.Errorf("expected both rounds to be streamed, got %d requests", len(script.Requests))
+	}
+}
+
+func TestAgentStreamDropsDuplicatedChunks(t *testing.T) {
+	// Recorded from a stream that repeated its second chunk, ending with an
+	// empty finishing chunk and a usage-only chunk before [DONE]
+	recorded := contentChunks("The capital of France ", "is Paris, which is also ", "is Paris, which is also ", "its largest city.", "\n", "\n")
+	recorded = append(recorded,
+		openai.ChatCompletionStreamResponse{Choices: []openai.ChatCompletionStreamChoice{{FinishReason: openai.FinishReasonStop}}},
+		openai.ChatCompletionStreamResponse{Usage: &openai.Usage{PromptTokens: 12, CompletionTokens: 14, TotalTokens: 26}},
+	)
+
+	client, _ := newStreamingClient(t, recorded)
+	agent := NewAgent(context.Background(), client, "test-model", "")
+
+	var deltas []string
+	agent.askMu.Lock()
+	message := NewMessages()
+	resp, err := agent.ask(askOptions{onContent: func(delta, content string) {
+		deltas = append(deltas, delta)
+	}}, message.MergeMessages(message.UserMessage("capital of france?")))
+	agent.askMu.Unlock()
+	if err != nil {
+		t.Fatalf("ask error: %v", err)
+	}
+
+	want := "The capital of France is Paris, which is also its largest city.\n\n"
+	if got := resp.Choices[0].Message.Content; got != want {
+		t.Errorf("assembled content = %q, want %q", got, want)
+	}
+	if len(deltas) != 5 {
+		t.Errorf("got %d deltas, want 5: %q", len(deltas), deltas)
+	}
+	if resp.Choices[0].FinishReason != openai.FinishReasonStop || resp.Usage.TotalTokens != 26 {
+		t.Errorf("finish reason %q, usage %+v", resp.Choices[0].FinishReason, resp.Usage)
+	}
+}
@@ -0,0 +1,344 @@
+package sapiens
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+// AskJSON sends the messages with a response schema generated from T and
+// decodes the final answer into T. The schema applies to this call only.
+func AskJSON[T any](agent *Agent, messages []openai.ChatCompletionMessage) (T, error) {
+	return askJSON[T](agent, askOptions{}, messages)
+}
+
+// AskStructuredStream behaves like AskJSON but streams the answer. As JSON
+// arrives, onUpdate receives a best-effort T decoded from the partial output,
+// with unfinished strings, arrays and objects closed. Once the stream ends it
+// receives the complete value, which is also returned.
+func AskStructuredStream[T any](agent *Agent, messages []openai.ChatCompletionMessage, onUpdate func(T)) (T, error) {
+	var last string
+
+	onContent := func(_, content string) {
+		partial, ok := completePartialJSON(stripMarkdownFences(content))
+		if !ok || partial == last {
+			return
+		}
+
+		var value T
+		if err := unmarshalStructured(partial, &value); err != nil {
+			return
+		}
+
+		last = partial
+		onUpdate(value)
+	}
+
+	result, err := askJSON[T](agent, askOptions{onContent: onContent}, messages)
+	if err != nil {
+		return result, err
+	}
+
+	onUpdate(result)
+
+	return result, nil
+}
+
+// askJSON runs a turn with a response schema generated from T and decodes the
+// final answer into T.
+func askJSON[T any](agent *Agent, opts askOptions, messages []openai.ChatCompletionMessage) (T, error) {
+	var result T
+
+	schema, err := generateSchema(result)
+	if err != nil {
+		return result, fmt.Errorf("failed to generate schema: %w", err)
+	}
+	if err := ValidateSchema(*schema); err != nil {
+		return result, fmt.Errorf("invalid response schema: %w", err)
+	}
+
+	opts.responseFormat = &openai.ChatCompletionResponseFormat{
+		Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+		JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+			Name:   schemaName(reflect.TypeOf(result)),
+			Schema: wrapArraySchema(schema),
+			Strict: true,
+		},
+	}
+
+	agent.askMu.Lock()
+	defer agent.askMu.Unlock()
+
+	resp, err := agent.ask(opts, messages)
+	if err != nil {
+		return result, err
+	}
+
+	if len(resp.Choices) == 0 {
+		return result, fmt.Errorf("no choices in response")
+	}
+
+	if err := unmarshalStructured(resp.Choices[0].Message.Content, &result); err != nil {
+		return result, fmt.Errorf("failed to parse structured response: %w", err)
+	}
+
+	return result, nil
+}
+
+// schemaCache holds the schemas generated for Go types, keyed by
+// reflect.Type, so repeated structured requests skip the reflection.
+var schemaCache sync.Map
+
+// generateSchema returns the JSON schema of v's type, generating it on first
+// use. The result is a deep copy of the cached schema, so callers may modify
+// it.
+func generateSchema(v interface{}) (*jsonschema.Definition, error) {
+	t := reflect.TypeOf(v)
+	if cached, ok := schemaCache.Load(t); ok && t != nil {
+		schema := copySchema(cached.(jsonschema.Definition))
+		return &schema, nil
+	}
+
+	schema, err := jsonschema.GenerateSchemaForType(v)
+	if err != nil {
+		return nil, err
+	}
+	if t != nil {
+		schemaCache.Store(t, copySchema(*schema))
+	}
+
+	return schema, nil
+}
+
+// copySchema returns a deep copy of a schema definition.
+func copySchema(schema jsonschema.Definition) jsonschema.Definition {
+	copied := schema
+	copied.Enum = append([]string(nil), schema.Enum...)
+	copied.Required = append([]string(nil), schema.Required...)
+
+	if schema.Properties != nil {
+		copied.Properties = make(map[string]jsonschema.Definition, len(schema.Properties))
+		for name, property := range schema.Properties {
+			copied.Properties[name] = copySchema(property)
+		}
+	}
+
+	if schema.Items != nil {
+		items := copySchema(*schema.Items)
+		copied.Items = &items
+	}
+
+	switch additional := schema.AdditionalProperties.(type) {
+	case jsonschema.Definition:
+		copied.AdditionalProperties = copySchema(additional)
+	case *jsonschema.Definition:
+		if additional != nil {
+			definition := copySchema(*additional)
+			copied.AdditionalProperties = &definition
+		}
+	}
+
+	return copied
+}
+
+// wrapArraySchema wraps a schema whose root is an array in an object with a
+// single "items" property, since providers only accept object roots for
+// structured output. unmarshalStructured unwraps the answer again.
+func wrapArraySchema(schema *jsonschema.Definition) *jsonschema.Definition {
+	if schema == nil || schema.Type != jsonschema.Array {
+		return schema
+	}
+
+	return &jsonschema.Definition{
+		Type:                 jsonschema.Object,
+		Properties:           map[string]jsonschema.Definition{"items": *schema},
+		Required:             []string{"items"},
+		AdditionalProperties: false,
+	}
+}
+
+// unmarshalStructured decodes a structured answer into target. An answer
+// wrapped by wrapArraySchema is unwrapped when target is a slice.
+func unmarshalStructured(content string, target interface{}) error {
+	content = stripMarkdownFences(content)
+	codec := currentCodec()
+
+	err := codec.Unmarshal([]byte(content), target)
+	if err == nil || !isSliceTarget(target) {
+		return err
+	}
+
+	var wrapped struct {
+		Items json.RawMessage `json:"items"`
+	}
+	if json.Unmarshal([]byte(content), &wrapped) != nil || wrapped.Items == nil {
+		return err
+	}
+
+	return codec.Unmarshal(wrapped.Items, target)
+}
+
+func isSliceTarget(target interface{}) bool {
+	value := reflect.ValueOf(target)
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return false
+		}
+		value = value.Elem()
+	}
+
+	return value.Kind() == reflect.Slice || value.Kind() == reflect.Array
+}
+
+// schemaName derives a response schema name from a Go type. Providers only
+// accept letters, digits, underscores and dashes.
+func schemaName(t reflect.Type) string {
+	for t != nil && (t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice) {
+		t = t.Elem()
+	}
+
+	name := "response"
+	if t != nil && t.Name() != "" {
+		name = t.Name()
+	}
+
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r == '-' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// stripMarkdownFences removes a surrounding ```json ... ``` block, which some
+// providers (notably Gemini) wrap around JSON output.
+func stripMarkdownFences(content string) string {
+	trimmed := strings.TrimSpace(content)
+	if !strings.HasPrefix(trimmed, "```") {
+		return content
+	}
+
+	trimmed = strings.TrimPrefix(trimmed, "```")
+	if newline := strings.Index(trimmed, "\n"); newline >= 0 {
+		trimmed = trimmed[newline+1:]
+	} else {
+		trimmed = strings.TrimPrefix(trimmed, "json")
+	}
+
+	trimmed = strings.TrimSuffix(strings.TrimSpace(trimmed), "```")
+
+	return strings.TrimSpace(trimmed)
+}
+
+// completePartialJSON turns a truncated JSON document into a valid one by
+// closing an unfinished string and any open arrays and objects. Incomplete
+// trailing tokens, such as a key without a value or a half-written literal,
+// are dropped. It reports false when nothing valid can be recovered.
+func completePartialJSON(partial string) (string, bool) {
+	partial = strings.TrimLeft(partial, " \t\r\n")
+	if partial == "" {
+		return "", false
+	}
+
+	if closed := closePartialJSON(partial); json.Valid([]byte(closed)) {
+		return closed, true
+	}
+
+	// Fall back to the longest prefix ending at a value boundary: just before
+	// the last comma or just after the last opening bracket.
+	scan := scanPartialJSON(partial)
+	var cuts []string
+	if scan.lastComma >= 0 {
+		cuts = append(cuts, partial[:scan.lastComma])
+	}
+	if scan.lastOpen >= 0 {
+		cuts = append(cuts, partial[:scan.lastOpen+1])
+	}
+	if len(cuts) == 2 && len(cuts[1]) > len(cuts[0]) {
+		cuts[0], cuts[1] = cuts[1], cuts[0]
+	}
+
+	for _, cut := range cuts {
+		if closed := closePartialJSON(cut); json.Valid([]byte(closed)) {
+			return closed, true
+		}
+	}
+
+	return "", false
+}
+
+type partialJSONScan struct {
+	closers   []byte // closing brackets for the containers left open
+	inString  bool
+	escaped   bool
+	lastComma int // offset of the last comma outside a string, or -1
+	lastOpen  int // offset of the last '{' or '[' outside a string, or -1
+}
+
+func scanPartialJSON(partial string) partialJSONScan {
+	scan := partialJSONScan{lastComma: -1, lastOpen: -1}
+
+	for i := 0; i < len(partial); i++ {
+		c := partial[i]
+
+		if scan.inString {
+			switch {
+			case scan.escaped:
+				scan.escaped = false
+			case c == '\\':
+				scan.escaped = true
+			case c == '"':
+				scan.inString = false
+			}
+			continue
+		}
+
+		switch c {
+		case '"':
+			scan.inString = true
+		case '{':
+			scan.closers = append(scan.closers, '}')
+			scan.lastOpen = i
+		case '[':
+			scan.closers = append(scan.closers, ']')
+			scan.lastOpen = i
+		case '}', ']':
+			if len(scan.closers) > 0 {
+				scan.closers = scan.closers[:len(scan.closers)-1]
+			}
+		case ',':
+			scan.lastComma = i
+		}
+	}
+
+	return scan
+}
+
+func closePartialJSON(partial string) string {
+	scan := scanPartialJSON(partial)
+
+	closed := partial
+	if scan.inString {
+		if scan.escaped {
+			closed = closed[:len(closed)-1]
+		}
+		closed += `"`
+	}
+
+	closed = strings.TrimRight(closed, " \t\r\n")
+	closed = strings.TrimSuffix(closed, ",")
+	if strings.HasSuffix(closed, ":") {
+		closed += "null"
+	}
+
+	for i := len(scan.closers) - 1; i >= 0; i-- {
+		closed += string(scan.closers[i])
+	}
+
+	return closed
+}
@@ -0,0 +1,147 @@
+package sapiens
+
+import (
+	"fmt"
+	"sort"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// ToolCostOptions controls how the Cost of regular tools is presented to the
+// model, to steer it towards cheaper tools when several can do the job.
+type ToolCostOptions struct {
+	// Order lists the tools by ascending cost. Tools without a cost come first.
+	Order bool
+	// Annotate appends "(low cost)", "(medium cost)" or "(high cost)" to the
+	// description of each tool with a cost, relative to the other tools.
+	Annotate bool
+}
+
+// SetToolCostOptions sets how tool costs are presented to the model.
+func (a *Agent) SetToolCostOptions(opts ToolCostOptions) {
+	a.mu.Lock()
+	a.toolCostOptions = opts
+	a.mu.Unlock()
+}
+
+// SetToolCost sets the relative cost of a registered regular tool, e.g. its
+// price per call or latency. Zero means unknown.
+func (a *Agent) SetToolCost(name string, cost float64) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for i := range a.Tools {
+		if a.Tools[i].ToolDefinition.Function.Name == name {
+			a.Tools[i].Cost = cost
+			return nil
+		}
+	}
+
+	return fmt.Errorf("tool '%s' not found", name)
+}
+
+// CheapestToolCallFilter returns a filter for SetToolCallFilter that, when a
+// response calls several tools of one group of interchangeable tools, keeps
+// only the calls to the cheapest of them. Calls to tools outside the groups
+// or without a cost are kept. The costs are those set when the filter is
+// created.
+func (a *Agent) CheapestToolCallFilter(groups ...[]string) ToolCallFilter {
+	a.mu.Lock()
+	costs := make(map[string]float64, len(a.Tools))
+	for _, tool := range a.Tools {
+		if tool.Cost > 0 && tool.ToolDefinition.Function != nil {
+			costs[tool.ToolDefinition.Function.Name] = tool.Cost
+		}
+	}
+	a.mu.Unlock()
+
+	groupOf := make(map[string]int)
+	for i, group := range groups {
+		for _, name := range group {
+			if _, ok := costs[name]; ok {
+				groupOf[name] = i
+			}
+		}
+	}
+
+	return func(calls []openai.ToolCall) []openai.ToolCall {
+		cheapest := make(map[int]string)
+		for _, call := range calls {
+			group, ok := groupOf[call.Function.Name]
+			if !ok {
+				continue
+			}
+			if current, seen := cheapest[group]; !seen || costs[call.Function.Name] < costs[current] {
+				cheapest[group] = call.Function.Name
+			}
+		}
+
+		kept := calls[:0]
+		for _, call := range calls {
+			if group, ok := groupOf[call.Function.Name]; ok && cheapest[group] != call.Function.Name {
+				continue
+			}
+			kept = append(kept, call)
+		}
+
+		return kept
+	}
+}
+
+// toolDefinitionsLocked returns the definitions of the regular tools as
+// presented to the model. The caller must hold a.mu.
+func (a *Agent) toolDefinitionsLocked() []openai.Tool {
+	tools := append([]AgentTool(nil), a.Tools...)
+	opts := a.toolCostOptions
+
+	if opts.Order {
+		sort.SliceStable(tools, func(i, j int) bool {
+			return tools[i].Cost < tools[j].Cost
+		})
+	}
+
+	lowest, highest := 0.0, 0.0
+	for _, tool := range tools {
+		if tool.Cost <= 0 {
+			continue
+		}
+		if lowest == 0 || tool.Cost < lowest {
+			lowest = tool.Cost
+		}
+		if tool.Cost > highest {
+			highest = tool.Cost
+		}
+	}
+
+	definitions := make([]openai.Tool, len(tools))
+	for i, tool := range tools {
+		definitions[i] = tool.ToolDefinition
+		if !opts.Annotate || tool.Cost <= 0 || tool.ToolDefinition.Function == nil {
+			continue
+		}
+
+		// Copy the function so the hint is not stored in the registered tool
+		function := *tool.ToolDefinition.Function
+		function.Description += " (" + costHint(tool.Cost, lowest, highest) + ")"
+		definitions[i].Function = &function
+	}
+
+	return definitions
+}
+
+// costHint places cost in the lower, middle or upper third of the range of
+// tool costs.
+func costHint(cost, lowest, highest float64) string {
+	if highest == lowest {
+		return "low cost"
+	}
+
+	switch position := (cost - lowest) / (highest - lowest); {
+	case position < 1.0/3:
+		return "low cost"
+	case position < 2.0/3:
+		return "medium cost"
+	default:
+		return "high cost"
+	}
+}
@@ -0,0 +1,68 @@
+package sapiens
+
+import (
+	"fmt"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// AddToolExample adds an example exchange in which the model answers query by
+// calling a tool, getting result and then answering with answer. Like the
+// examples of SetFewShotExamples, it is sent with every request before the
+// conversation and not stored in MessagesHistory. The result is formatted as
+// the agent's tool results are, see SetToolResultFormat, so weaker models
+// see the exact pattern they are expected to follow. An empty call ID is
+// filled in.
+func (a *Agent) AddToolExample(query string, call ToolCall, result string, answer string) {
+	a.mu.Lock()
+	a.fewShotExamples = append(a.fewShotExamples[:len(a.fewShotExamples):len(a.fewShotExamples)], FewShotExample{
+		User:       query,
+		Assistant:  answer,
+		ToolCall:   &call,
+		ToolResult: result,
+	})
+	a.mu.Unlock()
+}
+
+// exampleMessagesLocked returns the messages of the index-th few-shot
+// example. The caller must hold a.mu.
+func (a *Agent) exampleMessagesLocked(index int, example FewShotExample) []openai.ChatCompletionMessage {
+	messages := []openai.ChatCompletionMessage{NewMessages().UserMessage(example.User)}
+
+	if example.ToolCall != nil {
+		id := example.ToolCall.ID
+		if id == "" {
+			id = fmt.Sprintf("example_%d", index+1)
+		}
+
+		messages = append(messages, openai.ChatCompletionMessage{
+			Role: openai.ChatMessageRoleAssistant,
+			ToolCalls: []openai.ToolCall{{
+				ID:   id,
+				Type: openai.ToolTypeFunction,
+				Function: openai.FunctionCall{
+					Name:      example.ToolCall.Name,
+					Arguments: example.ToolCall.Arguments,
+				},
+			}},
+		})
+
+		if a.toolResultFormat.Role == openai.ChatMessageRoleTool {
+			result := openai.ChatCompletionMessage{
+				Role:    openai.ChatMessageRoleTool,
+				Name:    example.ToolCall.Name,
+				Content: example.ToolResult,
+			}
+			if a.toolResultFormat.RequiresToolCallID {
+				result.ToolCallID = id
+			}
+			messages = append(messages, result)
+		} else {
+			messages = append(messages, NewMessages().UserMessage(
+				fmt.Sprintf("Tool '%s' returned: %s", example.ToolCall.Name, example.ToolResult),
+			))
+		}
+	}
+
+	return append(messages, NewMessages().AgentMessage(example.Assistant))
+}
@@ -0,0 +1,63 @@
+package sapiens
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestAgentAddToolExample(t *testing.T) {
+	completer := newMockCompleter(textResponse("ok"), textResponse("ok"))
+	agent := NewAgent(context.Background(), completer, "test-model", "You are helpful.")
+	agent.AddToolExample("weather in Paris?", ToolCall{Name: "get_weather", Arguments: `{"city":"Paris"}`}, `{"temp":21}`, "It is 21°C in Paris.")
+
+	messages := NewMessages()
+	if _, err := agent.Ask(messages.MergeMessages(messages.UserMessage("weather in Rome?"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	call := openai.ChatCompletionMessage{
+		Role: openai.ChatMessageRoleAssistant,
+		ToolCalls: []openai.ToolCall{{
+			ID:       "example_1",
+			Type:     openai.ToolTypeFunction,
+			Function: openai.FunctionCall{Name: "get_weather", Arguments: `{"city":"Paris"}`},
+		}},
+	}
+	want := []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleSystem, Content: "You are helpful."},
+		messages.UserMessage("weather in Paris?"),
+		call,
+		messages.UserMessage(`Tool 'get_weather' returned: {"temp":21}`),
+		messages.AgentMessage("It is 21°C in Paris."),
+		messages.UserMessage("weather in Rome?"),
+	}
+	if got := completer.Requests[0].Messages; !reflect.DeepEqual(got, want) {
+		t.Errorf("messages = %+v, want %+v", got, want)
+	}
+	for _, message := range agent.MessagesHistory {
+		if message.Content == "weather in Paris?" {
+			t.Errorf("example stored in the history")
+		}
+	}
+
+	if err := agent.SetToolResultFormat(ToolResultsAsTool); err != nil {
+		t.Fatalf("SetToolResultFormat error: %v", err)
+	}
+	agent.Reset()
+	if _, err := agent.Ask(messages.MergeMessages(messages.UserMessage("weather in Rome?"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	want[3] = openai.ChatCompletionMessage{
+		Role:       openai.ChatMessageRoleTool,
+		Name:       "get_weather",
+		Content:    `{"temp":21}`,
+		ToolCallID: "example_1",
+	}
+	if got := completer.Requests[1].Messages; !reflect.DeepEqual(got, want) {
+		t.Errorf("messages = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,145 @@
+package sapiens
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// truncateToolResult shortens a tool result to about limit bytes, marking how
+// much was cut. JSON arrays and objects keep their leading elements and stay
+// valid JSON; other results are cut at a UTF-8 boundary.
+func truncateToolResult(result string, limit int) string {
+	if limit <= 0 || len(result) <= limit {
+		return result
+	}
+
+	trimmed := strings.TrimSpace(result)
+	if json.Valid([]byte(trimmed)) {
+		if truncated, ok := truncateJSON(trimmed, len(result), limit); ok {
+			return truncated
+		}
+	}
+
+	return truncateText(result, len(result), limit)
+}
+
+func truncationMarker(dropped int) string {
+	return fmt.Sprintf("...[truncated %d bytes]", dropped)
+}
+
+func truncateText(text string, original, limit int) string {
+	keep := limit - len(truncationMarker(original))
+	if keep < 0 {
+		keep = 0
+	}
+	if keep > len(text) {
+		keep = len(text)
+	}
+	for keep > 0 && keep < len(text) && !utf8.RuneStart(text[keep]) {
+		keep--
+	}
+
+	return text[:keep] + truncationMarker(original-keep)
+}
+
+func truncateJSON(document string, original, limit int) (string, bool) {
+	switch document[0] {
+	case '[':
+		var elements []json.RawMessage
+		if err := json.Unmarshal([]byte(document), &elements); err != nil {
+			return "", false
+		}
+		return truncateJSONArray(elements, original, limit), true
+	case '{':
+		keys, values, err := decodeOrderedObject(document)
+		if err != nil {
+			return "", false
+		}
+		return truncateJSONObject(keys, values, original, limit), true
+	case '"':
+		var text string
+		if err := json.Unmarshal([]byte(document), &text); err != nil {
+			return "", false
+		}
+		encoded, _ := json.Marshal(truncateText(text, original, limit-2))
+		return string(encoded), true
+	}
+
+	return "", false
+}
+
+func truncateJSONArray(elements []json.RawMessage, original, limit int) string {
+	// Reserve room for the marker element, its separator and the brackets
+	budget := limit - len(truncationMarker(original)) - 5
+
+	var out bytes.Buffer
+	out.WriteByte('[')
+	for _, element := range elements {
+		if out.Len()+len(element)+1 > budget {
+			break
+		}
+		out.Write(element)
+		out.WriteByte(',')
+	}
+
+	marker, _ := json.Marshal(truncationMarker(original - out.Len()))
+	out.Write(marker)
+	out.WriteByte(']')
+
+	return out.String()
+}
+
+func truncateJSONObject(keys []string, values []json.RawMessage, original, limit int) string {
+	// Reserve room for the "_truncated" member, its separator and the braces
+	budget := limit - len(truncationMarker(original)) - 18
+
+	var out bytes.Buffer
+	out.WriteByte('{')
+	for i, key := range keys {
+		encodedKey, _ := json.Marshal(key)
+		if out.Len()+len(encodedKey)+len(values[i])+2 > budget {
+			break
+		}
+		out.Write(encodedKey)
+		out.WriteByte(':')
+		out.Write(values[i])
+		out.WriteByte(',')
+	}
+
+	marker, _ := json.Marshal(truncationMarker(original - out.Len()))
+	out.WriteString(`"_truncated":`)
+	out.Write(marker)
+	out.WriteByte('}')
+
+	return out.String()
+}
+
+// decodeOrderedObject decodes the members of a JSON object in document order.
+func decodeOrderedObject(document string) ([]string, []json.RawMessage, error) {
+	decoder := json.NewDecoder(strings.NewReader(document))
+	if _, err := decoder.Token(); err != nil {
+		return nil, nil, err
+	}
+
+	var keys []string
+	var values []json.RawMessage
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, nil, err
+		}
+
+		keys = append(keys, token.(string))
+		values = append(values, value)
+	}
+
+	return keys, values, nil
+}
@@ -0,0 +1,74 @@
+package sapiens
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+func TestTruncateToolResult(t *testing.T) {
+	if got := truncateToolResult("short", 100); got != "short" {
+		t.Errorf("small results should be unchanged, got %q", got)
+	}
+
+	text := strings.Repeat("é", 100)
+	truncated := truncateToolResult(text, 60)
+	if !strings.HasSuffix(truncated, "bytes]") || !strings.Contains(truncated, "...[truncated ") {
+		t.Errorf("expected a truncation marker, got %q", truncated)
+	}
+	if len(truncated) > 60 || !json.Valid([]byte(`"`+truncated+`"`)) {
+		t.Errorf("expected at most 60 bytes cut at a rune boundary, got %d bytes", len(truncated))
+	}
+
+	items := make([]map[string]int, 100)
+	for i := range items {
+		items[i] = map[string]int{"id": i}
+	}
+	array, _ := json.Marshal(items)
+
+	truncated = truncateToolResult(string(array), 200)
+	var elements []json.RawMessage
+	if err := json.Unmarshal([]byte(truncated), &elements); err != nil {
+		t.Fatalf("truncated array is not valid JSON: %v\n%s", err, truncated)
+	}
+	if len(truncated) > 200 || len(elements) < 2 || string(elements[0]) != `{"id":0}` {
+		t.Errorf("unexpected truncated array (%d bytes): %s", len(truncated), truncated)
+	}
+
+	object := `{"status":"ok","body":"` + strings.Repeat("x", 500) + `","count":3}`
+	truncated = truncateToolResult(object, 100)
+	var members map[string]interface{}
+	if err := json.Unmarshal([]byte(truncated), &members); err != nil {
+		t.Fatalf("truncated object is not valid JSON: %v\n%s", err, truncated)
+	}
+	if members["status"] != "ok" || members["_truncated"] == nil {
+		t.Errorf("unexpected truncated object: %s", truncated)
+	}
+}
+
+func TestAgentMaxToolResultSize(t *testing.T) {
+	completer := newMockCompleter(
+		toolCallResponse(functionCall("call_1", "dump", `{}`)),
+		textResponse("done"),
+	)
+
+	agent := NewAgent(context.Background(), completer, "test-model", "")
+	agent.SetMaxToolResultSize(50)
+	agent.AddTool("dump", "Dump everything", map[string]jsonschema.Definition{}, nil, func(parameters map[string]string) string {
+		return strings.Repeat("a", 1000)
+	})
+
+	message := NewMessages()
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("dump it"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	sent := completer.Requests[1].Messages
+	toolResult := strings.TrimPrefix(sent[len(sent)-1].Content, "Tool 'dump' returned: ")
+	if len(toolResult) > 50 || !strings.HasSuffix(toolResult, "bytes]") {
+		t.Errorf("expected a truncated tool result, got %q", toolResult)
+	}
+}
@@ -0,0 +1,61 @@
+package sapiens
+
+import (
+	"fmt"
+
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+// validateToolParameters checks that a tool's parameters form a schema the
+// providers accept, so mistakes surface when the tool is registered instead
+// of as a 400 on the first request that carries it.
+func validateToolParameters(tool string, tool_parameters map[string]jsonschema.Definition, required_params []string) error {
+	if err := validateProperties(tool_parameters, required_params, ""); err != nil {
+		return fmt.Errorf("invalid schema for tool '%s': %w", tool, err)
+	}
+
+	return nil
+}
+
+func validateProperties(properties map[string]jsonschema.Definition, required []string, prefix string) error {
+	for name, definition := range properties {
+		if name == "" {
+			return fmt.Errorf("parameter with an empty name")
+		}
+
+		if err := validateDefinition(definition, prefix+name); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range required {
+		if _, ok := properties[name]; !ok {
+			return fmt.Errorf("required parameter '%s%s' is not defined", prefix, name)
+		}
+	}
+
+	return nil
+}
+
+func validateDefinition(definition jsonschema.Definition, path string) error {
+	if len(definition.Enum) > 0 && definition.Type != jsonschema.String {
+		return fmt.Errorf("parameter '%s' has an enum but is of type '%s'; enums are only supported on strings", path, definition.Type)
+	}
+
+	switch definition.Type {
+	case jsonschema.String, jsonschema.Number, jsonschema.Integer, jsonschema.Boolean, jsonschema.Null:
+	case jsonschema.Object:
+		return validateProperties(definition.Properties, definition.Required, path+".")
+	case jsonschema.Array:
+		if definition.Items == nil {
+			return fmt.Errorf("parameter '%s' is an array but does not define its items", path)
+		}
+		return validateDefinition(*definition.Items, path+"[]")
+	case "":
+		return fmt.Errorf("parameter '%s' has no type", path)
+	default:
+		return fmt.Errorf("parameter '%s' has unknown type '%s'", path, definition.Type)
+	}
+
+	return nil
+}
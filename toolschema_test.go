@@ -0,0 +1,81 @@
+package sapiens
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+func TestAgentAddToolValidatesSchema(t *testing.T) {
+	noop := func(parameters map[string]string) string { return "{}" }
+
+	cases := []struct {
+		name       string
+		parameters map[string]jsonschema.Definition
+		required   []string
+		wantErr    string
+	}{
+		{
+			name:       "array without items",
+			parameters: map[string]jsonschema.Definition{"tags": {Type: jsonschema.Array}},
+			wantErr:    "parameter 'tags' is an array but does not define its items",
+		},
+		{
+			name:       "enum on integer",
+			parameters: map[string]jsonschema.Definition{"level": {Type: jsonschema.Integer, Enum: []string{"1", "2"}}},
+			wantErr:    "parameter 'level' has an enum",
+		},
+		{
+			name:       "missing type",
+			parameters: map[string]jsonschema.Definition{"query": {Description: "search query"}},
+			wantErr:    "parameter 'query' has no type",
+		},
+		{
+			name: "nested array without items",
+			parameters: map[string]jsonschema.Definition{"filter": {
+				Type:       jsonschema.Object,
+				Properties: map[string]jsonschema.Definition{"ids": {Type: jsonschema.Array}},
+			}},
+			wantErr: "parameter 'filter.ids' is an array",
+		},
+		{
+			name:       "undefined required parameter",
+			parameters: map[string]jsonschema.Definition{"location": {Type: jsonschema.String}},
+			required:   []string{"unit"},
+			wantErr:    "required parameter 'unit' is not defined",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			agent := NewAgent(context.Background(), newMockCompleter(), "test-model", "")
+
+			err := agent.AddTool("my_tool", "a tool", tc.parameters, tc.required, noop)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) || !strings.Contains(err.Error(), "my_tool") {
+				t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+			}
+
+			if len(agent.Tools) != 0 {
+				t.Error("an invalid tool should not be registered")
+			}
+		})
+	}
+}
+
+func TestAgentAddToolAcceptsValidSchema(t *testing.T) {
+	agent := NewAgent(context.Background(), newMockCompleter(), "test-model", "")
+
+	err := agent.AddTool("search", "search documents", map[string]jsonschema.Definition{
+		"query": {Type: jsonschema.String},
+		"unit":  {Type: jsonschema.String, Enum: []string{"celsius", "fahrenheit"}},
+		"tags":  {Type: jsonschema.Array, Items: &jsonschema.Definition{Type: jsonschema.String}},
+		"range": {Type: jsonschema.Object, Properties: map[string]jsonschema.Definition{
+			"from": {Type: jsonschema.Integer},
+		}, Required: []string{"from"}},
+	}, []string{"query"}, func(parameters map[string]string) string { return "{}" })
+	if err != nil {
+		t.Fatalf("AddTool error: %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package sapiens
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/sashabaranov/go-openai/jsonschema"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/embedded"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+type recordingProvider struct {
+	embedded.TracerProvider
+	mu    sync.Mutex
+	names []string
+}
+
+func (p *recordingProvider) Tracer(name string, options ...trace.TracerOption) trace.Tracer {
+	return &recordingTracer{provider: p}
+}
+
+type recordingTracer struct {
+	embedded.Tracer
+	provider *recordingProvider
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	t.provider.mu.Lock()
+	t.provider.names = append(t.provider.names, name)
+	t.provider.mu.Unlock()
+
+	span := &recordingSpan{provider: t.provider}
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+type recordingSpan struct {
+	noop.Span
+	provider *recordingProvider
+}
+
+func (s *recordingSpan) SpanContext() trace.SpanContext {
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{1},
+	})
+}
+
+func (s *recordingSpan) TracerProvider() trace.TracerProvider {
+	return s.provider
+}
+
+func TestAgentTracingSpans(t *testing.T) {
+	provider := &recordingProvider{}
+	_, root := provider.Tracer("test").Start(context.Background(), "request")
+	provider.names = nil
+
+	ctx := trace.ContextWithSpan(context.Background(), root)
+
+	completer := newMockCompleter(
+		toolCallResponse(functionCall("call_1", "echo", `{"text":"hi"}`)),
+		textResponse("done"),
+	)
+
+	agent := NewAgent(ctx, completer, "test-model", "you are a test agent")
+	agent.AddTool("echo", "Echo text", map[string]jsonschema.Definition{
+		"text": {Type: jsonschema.String},
+	}, []string{"text"}, func(parameters map[string]string) string {
+		return parameters["text"]
+	})
+
+	message := NewMessages()
+	if _, err := agent.Ask(message.MergeMessages(message.UserMessage("echo hi"))); err != nil {
+		t.Fatalf("Ask error: %v", err)
+	}
+
+	expected := []string{"sapiens.ask", "sapiens.completion", "sapiens.tool", "sapiens.completion"}
+	if len(provider.names) != len(expected) {
+		t.Fatalf("expected spans %v, got %v", expected, provider.names)
+	}
+	for i, name := range expected {
+		if provider.names[i] != name {
+			t.Errorf("span %d: expected %s, got %s", i, name, provider.names[i])
+		}
+	}
+}
+
+func TestAgentNoSpansWithoutTracer(t *testing.T) {
+	ctx, span := startSpan(context.Background(), "sapiens.ask")
+	if span != nil || ctx != context.Background() {
+		t.Error("expected no span without a parent span in the context")
+	}
+
+	endSpan(nil, nil)
+}
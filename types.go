@@ -0,0 +1,128 @@
+package sapiens
+
+import (
+	"encoding/json"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Message is a provider-agnostic representation of a conversation message.
+type Message struct {
+	Role       string
+	Content    string
+	Name       string
+	ToolCalls  []ToolCall
+	ToolCallID string
+	Options    map[string]interface{} // metadata kept with the history, never sent to the model
+}
+
+// ToolCall describes a single tool invocation requested by the model.
+type ToolCall struct {
+	ID        string
+	Name      string
+	Arguments string
+}
+
+// MessageFromOpenAI converts a go-openai message. The text parts of a
+// multi-part message are joined into Content; image parts have no
+// counterpart in Message and are left out.
+func MessageFromOpenAI(message openai.ChatCompletionMessage) Message {
+	converted := Message{
+		Role:       message.Role,
+		Content:    message.Content,
+		Name:       message.Name,
+		ToolCallID: message.ToolCallID,
+	}
+
+	if converted.Content == "" {
+		for _, part := range message.MultiContent {
+			if part.Type == openai.ChatMessagePartTypeText {
+				converted.Content += part.Text
+			}
+		}
+	}
+
+	for _, toolCall := range message.ToolCalls {
+		converted.ToolCalls = append(converted.ToolCalls, toolCallFromOpenAI(toolCall))
+	}
+
+	return converted
+}
+
+// ToOpenAI converts the message to the go-openai type sent to providers.
+// Options are left out.
+func (m Message) ToOpenAI() openai.ChatCompletionMessage {
+	message := openai.ChatCompletionMessage{
+		Role:       m.Role,
+		Content:    m.Content,
+		Name:       m.Name,
+		ToolCallID: m.ToolCallID,
+	}
+
+	for _, toolCall := range m.ToolCalls {
+		message.ToolCalls = append(message.ToolCalls, openai.ToolCall{
+			ID:   toolCall.ID,
+			Type: openai.ToolTypeFunction,
+			Function: openai.FunctionCall{
+				Name:      toolCall.Name,
+				Arguments: toolCall.Arguments,
+			},
+		})
+	}
+
+	return message
+}
+
+func toolCallFromOpenAI(toolCall openai.ToolCall) ToolCall {
+	return ToolCall{
+		ID:        toolCall.ID,
+		Name:      toolCall.Function.Name,
+		Arguments: toolCall.Function.Arguments,
+	}
+}
+
+// ToolResult is the outcome of one tool call of a turn.
+type ToolResult struct {
+	ToolCallID string
+	Name       string
+	Result     string        // the result as returned by the tool and sent to the model
+	Parsed     interface{}   // Result decoded as JSON, nil when it is not JSON
+	Err        error         // why the call failed or was not executed
+	Duration   time.Duration // how long the tool ran
+}
+
+func newToolResult(toolCall openai.ToolCall, result string, err error, duration time.Duration) ToolResult {
+	toolResult := ToolResult{
+		ToolCallID: toolCall.ID,
+		Name:       toolCall.Function.Name,
+		Result:     result,
+		Err:        err,
+		Duration:   duration,
+	}
+
+	if json.Valid([]byte(result)) {
+		json.Unmarshal([]byte(result), &toolResult.Parsed)
+	}
+
+	return toolResult
+}
+
+// Candidate is one of several alternative answers returned for a request.
+type Candidate struct {
+	Content    string
+	Structured interface{}
+}
+
+// Response is the unified result of an agent turn. Content and Structured
+// mirror the first of the Candidates.
+type Response struct {
+	Content     string
+	ToolCalls   []ToolCall
+	ToolResults []ToolResult
+	Citations   []Citation // the sources the answer cites, see Agent.LastCitations
+	Structured  interface{}
+	Candidates  []Candidate
+	Truncated   bool // Content was cut by SetMaxResponseLength or the model's token limit
+	Raw         openai.ChatCompletionResponse
+}
@@ -0,0 +1,37 @@
+package sapiens
+
+import (
+	"reflect"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestMessageOpenAIRoundTrip(t *testing.T) {
+	messages := []Message{
+		{Role: openai.ChatMessageRoleSystem, Content: "you are helpful"},
+		{Role: openai.ChatMessageRoleUser, Content: "weather in Paris?"},
+		{Role: openai.ChatMessageRoleAssistant, ToolCalls: []ToolCall{
+			{ID: "call_1", Name: "weather", Arguments: `{"city":"Paris"}`},
+			{ID: "call_2", Name: "time", Arguments: `{"tz":"CET"}`},
+		}},
+		{Role: openai.ChatMessageRoleTool, Name: "weather", ToolCallID: "call_1", Content: `{"temp":21}`},
+	}
+
+	for _, message := range messages {
+		converted := message.ToOpenAI()
+		if back := MessageFromOpenAI(converted); !reflect.DeepEqual(back, message) {
+			t.Errorf("round trip of %+v gave %+v", message, back)
+		}
+	}
+
+	assistant := messages[2].ToOpenAI()
+	if call := assistant.ToolCalls[1]; call.Type != openai.ToolTypeFunction || call.Function.Name != "time" || call.ID != "call_2" {
+		t.Errorf("converted tool call = %+v", call)
+	}
+
+	multi := NewMessages().ImageMessage("what is this?", "https://example.com/a.png")
+	if got := MessageFromOpenAI(multi); got.Content != "what is this?" || got.Role != openai.ChatMessageRoleUser {
+		t.Errorf("multi-part message converted to %+v", got)
+	}
+}
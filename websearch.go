@@ -0,0 +1,222 @@
+package sapiens
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+const (
+	WebSearchProviderTavily = "tavily"
+	WebSearchProviderSerper = "serper"
+
+	TavilyBaseUrl = "https://api.tavily.com/search"
+	SerperBaseUrl = "https://google.serper.dev/search"
+
+	webSearchDefaultResults = 5
+	webSearchTimeout        = 30 * time.Second
+)
+
+type SearchResult struct {
+	Title   string `json:"title"`
+	Url     string `json:"url"`
+	Snippet string `json:"snippet"`
+}
+
+// SearchBackend performs a web search. Implement it to plug in a search
+// provider that is not bundled with the package.
+type SearchBackend interface {
+	Search(ctx context.Context, query string, maxResults int) ([]SearchResult, error)
+}
+
+type TavilySearch struct {
+	BaseUrl    string
+	AuthToken  string
+	HTTPClient *http.Client
+}
+
+func NewTavilySearch(authToken string, httpClient *http.Client) *TavilySearch {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: webSearchTimeout}
+	}
+
+	return &TavilySearch{
+		BaseUrl:    TavilyBaseUrl,
+		AuthToken:  authToken,
+		HTTPClient: httpClient,
+	}
+}
+
+func (t *TavilySearch) Search(ctx context.Context, query string, maxResults int) ([]SearchResult, error) {
+	var body struct {
+		Results []struct {
+			Title   string `json:"title"`
+			Url     string `json:"url"`
+			Content string `json:"content"`
+		} `json:"results"`
+	}
+
+	headers := map[string]string{"Authorization": "Bearer " + t.AuthToken}
+	payload := map[string]interface{}{"query": query, "max_results": maxResults}
+
+	if err := postSearch(ctx, t.HTTPClient, t.BaseUrl, headers, payload, &body); err != nil {
+		return nil, fmt.Errorf("tavily search failed: %w", err)
+	}
+
+	results := make([]SearchResult, 0, len(body.Results))
+	for _, r := range body.Results {
+		results = append(results, SearchResult{Title: r.Title, Url: r.Url, Snippet: r.Content})
+	}
+
+	return results, nil
+}
+
+type SerperSearch struct {
+	BaseUrl    string
+	AuthToken  string
+	HTTPClient *http.Client
+}
+
+func NewSerperSearch(authToken string, httpClient *http.Client) *SerperSearch {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: webSearchTimeout}
+	}
+
+	return &SerperSearch{
+		BaseUrl:    SerperBaseUrl,
+		AuthToken:  authToken,
+		HTTPClient: httpClient,
+	}
+}
+
+func (s *SerperSearch) Search(ctx context.Context, query string, maxResults int) ([]SearchResult, error) {
+	var body struct {
+		Organic []struct {
+			Title   string `json:"title"`
+			Link    string `json:"link"`
+			Snippet string `json:"snippet"`
+		} `json:"organic"`
+	}
+
+	headers := map[string]string{"X-API-KEY": s.AuthToken}
+	payload := map[string]interface{}{"q": query, "num": maxResults}
+
+	if err := postSearch(ctx, s.HTTPClient, s.BaseUrl, headers, payload, &body); err != nil {
+		return nil, fmt.Errorf("serper search failed: %w", err)
+	}
+
+	results := make([]SearchResult, 0, len(body.Organic))
+	for _, r := range body.Organic {
+		results = append(results, SearchResult{Title: r.Title, Url: r.Link, Snippet: r.Snippet})
+	}
+
+	if len(results) > maxResults {
+		results = results[:maxResults]
+	}
+
+	return results, nil
+}
+
+func postSearch(ctx context.Context, client *http.Client, url string, headers map[string]string, payload interface{}, out interface{}) error {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(encoded))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// NewWebSearchTool returns a "web_search" tool backed by the named provider
+// ("tavily" or "serper").
+func NewWebSearchTool(apiKey string, provider string) AgentTool {
+	switch provider {
+	case WebSearchProviderTavily:
+		return NewWebSearchToolWithBackend(NewTavilySearch(apiKey, nil))
+	case WebSearchProviderSerper:
+		return NewWebSearchToolWithBackend(NewSerperSearch(apiKey, nil))
+	}
+
+	return newWebSearchTool(func(parameters map[string]string) string {
+		return toolError(fmt.Errorf("unsupported web search provider '%s'", provider))
+	})
+}
+
+// NewWebSearchToolWithBackend returns a "web_search" tool using a custom backend.
+func NewWebSearchToolWithBackend(backend SearchBackend) AgentTool {
+	return newWebSearchTool(func(parameters map[string]string) string {
+		query := parameters["query"]
+		if query == "" {
+			return toolError(fmt.Errorf("query is required"))
+		}
+
+		maxResults := webSearchDefaultResults
+		if n, err := strconv.Atoi(parameters["max_results"]); err == nil && n > 0 {
+			maxResults = n
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), webSearchTimeout)
+		defer cancel()
+
+		results, err := backend.Search(ctx, query, maxResults)
+		if err != nil {
+			return toolError(err)
+		}
+
+		encoded, err := json.Marshal(map[string]interface{}{"query": query, "results": results})
+		if err != nil {
+			return toolError(err)
+		}
+
+		return string(encoded)
+	})
+}
+
+func newWebSearchTool(funx AgentFunc) AgentTool {
+	return newAgentTool("web_search",
+		"Search the web and return the most relevant results with title, url and snippet",
+		map[string]jsonschema.Definition{
+			"query": {
+				Type:        jsonschema.String,
+				Description: "The search query",
+			},
+			"max_results": {
+				Type:        jsonschema.String,
+				Description: fmt.Sprintf("Maximum number of results to return (default %d)", webSearchDefaultResults),
+			},
+		},
+		[]string{"query"},
+		funx,
+	)
+}
+
+// toolError formats an error as a JSON tool response the model can read.
+func toolError(err error) string {
+	encoded, _ := json.Marshal(map[string]string{"error": err.Error()})
+	return string(encoded)
+}
@@ -0,0 +1,88 @@
+package sapiens
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebSearchToolTavily(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tavily-key" {
+			t.Errorf("unexpected authorization header: %q", r.Header.Get("Authorization"))
+		}
+
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		if payload["query"] != "golang generics" {
+			t.Errorf("unexpected query: %v", payload["query"])
+		}
+
+		w.Write([]byte(`{"results":[{"title":"Go generics","url":"https://go.dev/doc/tutorial/generics","content":"Tutorial"}]}`))
+	}))
+	defer server.Close()
+
+	backend := NewTavilySearch("tavily-key", server.Client())
+	backend.BaseUrl = server.URL
+
+	tool := NewWebSearchToolWithBackend(backend)
+	if tool.ToolDefinition.Function.Name != "web_search" {
+		t.Fatalf("unexpected tool name: %s", tool.ToolDefinition.Function.Name)
+	}
+
+	out := tool.ToolFunction(map[string]string{"query": "golang generics"})
+
+	var decoded struct {
+		Results []SearchResult `json:"results"`
+	}
+	if err := json.Unmarshal([]byte(out), &decoded); err != nil {
+		t.Fatalf("tool output is not JSON: %v (%s)", err, out)
+	}
+
+	if len(decoded.Results) != 1 || decoded.Results[0].Url != "https://go.dev/doc/tutorial/generics" {
+		t.Errorf("unexpected results: %+v", decoded.Results)
+	}
+}
+
+func TestWebSearchToolSerper(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-KEY") != "serper-key" {
+			t.Errorf("unexpected api key header: %q", r.Header.Get("X-API-KEY"))
+		}
+
+		w.Write([]byte(`{"organic":[{"title":"A","link":"https://a.example","snippet":"a"},{"title":"B","link":"https://b.example","snippet":"b"}]}`))
+	}))
+	defer server.Close()
+
+	backend := NewSerperSearch("serper-key", server.Client())
+	backend.BaseUrl = server.URL
+
+	results, err := backend.Search(context.Background(), "anything", 1)
+	if err != nil {
+		t.Fatalf("search error: %v", err)
+	}
+
+	if len(results) != 1 || results[0].Title != "A" {
+		t.Errorf("unexpected results: %+v", results)
+	}
+}
+
+func TestWebSearchToolUnsupportedProvider(t *testing.T) {
+	tool := NewWebSearchTool("key", "altavista")
+
+	out := tool.ToolFunction(map[string]string{"query": "anything"})
+	if !strings.Contains(out, "unsupported web search provider") {
+		t.Errorf("expected unsupported provider error, got %s", out)
+	}
+
+	agent := NewAgent(context.Background(), nil, "test-model", "")
+	if err := agent.AddTools(tool, tool); err == nil {
+		t.Error("expected AddTools to reject repeated tool names")
+	}
+	if len(agent.Tools) != 0 {
+		t.Errorf("expected no tools to be registered, got %d", len(agent.Tools))
+	}
+}